@@ -0,0 +1,318 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	responseTypeCode          = "code"
+	codeChallengeMethodS256   = "S256"
+	authorizationCodeSize     = 32
+	authorizationCodeDuration = time.Minute
+	// RFC 7636 section 4.1, a S256 challenge is always 43 characters
+	minCodeVerifierSize = 43
+	maxCodeVerifierSize = 128
+)
+
+var errInvalidCredentials = fmt.Errorf("invalid username or password !")
+
+// AuthorizeRequest holds the RFC 6749 section 4.1.1 parameters with PKCE (RFC 7636),
+// nonce is kept with the code for the id token
+type AuthorizeRequest struct {
+	ResponseType        string `form:"response_type"`
+	ClientID            string `form:"client_id"`
+	RedirectURI         string `form:"redirect_uri"`
+	Scope               string `form:"scope"`
+	State               string `form:"state"`
+	Nonce               string `form:"nonce"`
+	CodeChallenge       string `form:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method"`
+}
+
+// AuthorizeLoginRequest is posted by the login page with the authorize parameters
+type AuthorizeLoginRequest struct {
+	AuthorizeRequest
+	Username string `form:"username" binding:"required"`
+	Password string `form:"password" binding:"required"`
+}
+
+// AuthorizeResponse describe a valid authorization request so the login page can show
+// which client asks for what
+type AuthorizeResponse struct {
+	ClientID    string `json:"client_id"`
+	ClientName  string `json:"client_name"`
+	RedirectURI string `json:"redirect_uri"`
+	Scope       string `json:"scope,omitempty"`
+	State       string `json:"state,omitempty"`
+}
+
+// Authorize validates an authorization request, the user agent then posts the
+// same parameters with the user credentials to AuthorizeLogin
+func (server *Server) Authorize(ctx *gin.Context) {
+	var req AuthorizeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, err.Error()))
+		return
+	}
+
+	client, redirectURI, ok := server.authorizeClient(ctx, req)
+	if !ok {
+		return
+	}
+	if code, description := server.validateAuthorize(client, req); code != "" {
+		authorizeRedirect(ctx, redirectURI, req.State, url.Values{"error": {code}, "error_description": {description}})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, AuthorizeResponse{
+		ClientID:    client.ID,
+		ClientName:  client.Name,
+		RedirectURI: redirectURI,
+		Scope:       req.Scope,
+		State:       req.State,
+	})
+}
+
+// AuthorizeLogin authenticates the user and redirects back to the client with
+// a single use authorization code
+func (server *Server) AuthorizeLogin(ctx *gin.Context) {
+	var req AuthorizeLoginRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, err.Error()))
+		return
+	}
+
+	client, redirectURI, ok := server.authorizeClient(ctx, req.AuthorizeRequest)
+	if !ok {
+		return
+	}
+	if code, description := server.validateAuthorize(client, req.AuthorizeRequest); code != "" {
+		authorizeRedirect(ctx, redirectURI, req.State, url.Values{"error": {code}, "error_description": {description}})
+		return
+	}
+
+	// the login page shows the error, nothing goes back to the client
+	user, err := server.store.GetUser(ctx, req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusUnauthorized, errResponse(errInvalidCredentials))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	if utils.VerifyPassword(user.Password, req.Password) != nil {
+		ctx.JSON(http.StatusUnauthorized, errResponse(errInvalidCredentials))
+		return
+	}
+
+	// clients get what they ask for and nothing by default, never more than the user
+	// or the client has, validateAuthorize already checked the client
+	scopes := strings.Fields(req.Scope)
+	userScopes := server.userScopes(user)
+	for _, scope := range scopes {
+		if !hasScope(userScopes, scope) {
+			authorizeRedirect(ctx, redirectURI, req.State, url.Values{"error": {oauthInvalidScope}, "error_description": {"requested scope exceeds the user scope"}})
+			return
+		}
+	}
+
+	code, err := utils.RandomSecureToken(authorizationCodeSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	_, err = server.store.CreateAuthorizationCode(ctx, db.CreateAuthorizationCodeParams{
+		CodeHash:      utils.HashToken(code),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		Nonce:         req.Nonce,
+		ExpiresAt:     time.Now().Add(authorizationCodeDuration),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	authorizeRedirect(ctx, redirectURI, req.State, url.Values{"code": {code}})
+}
+
+// authorizeClient check client_id and redirect_uri, until both are known good errors
+// are shown to the user and never redirected (RFC 6749 section 4.1.2.1)
+func (server *Server) authorizeClient(ctx *gin.Context, req AuthorizeRequest) (db.Client, string, bool) {
+	if req.ClientID == "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "client_id is required"))
+		return db.Client{}, "", false
+	}
+	client, err := server.store.GetClient(ctx, req.ClientID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidClient, "unknown client"))
+			return db.Client{}, "", false
+		}
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return db.Client{}, "", false
+	}
+
+	// registered uris are compared as exact strings, no prefix or wildcard matching
+	redirectURI := req.RedirectURI
+	if redirectURI == "" && len(client.RedirectUris) == 1 {
+		redirectURI = client.RedirectUris[0]
+	}
+	if redirectURI == "" || !hasScope(client.RedirectUris, redirectURI) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "redirect_uri is not registered for this client"))
+		return db.Client{}, "", false
+	}
+	return client, redirectURI, true
+}
+
+// validateAuthorize returns the error code to redirect with, empty when the request is valid
+func (server *Server) validateAuthorize(client db.Client, req AuthorizeRequest) (string, string) {
+	if req.ResponseType != responseTypeCode {
+		return oauthUnsupportedResponseType, "only the code response type is supported"
+	}
+	if !clientAllowsGrant(client, grantTypeAuthorizationCode) {
+		return oauthUnauthorizedClient, "the client isn't registered for the authorization code grant"
+	}
+	if scope := server.unallowedScope(client, strings.Fields(req.Scope)); scope != "" {
+		return oauthInvalidScope, "client isn't allowed the scope " + scope
+	}
+	if req.CodeChallenge == "" {
+		if client.Public {
+			return oauthInvalidRequest, "public clients must use PKCE"
+		}
+		return "", ""
+	}
+	// plain challenges would leak the verifier with the authorization request
+	if req.CodeChallengeMethod != codeChallengeMethodS256 {
+		return oauthInvalidRequest, "code_challenge_method must be S256"
+	}
+	if len(req.CodeChallenge) != base64.RawURLEncoding.EncodedLen(sha256.Size) {
+		return oauthInvalidRequest, "invalid code_challenge"
+	}
+	return "", ""
+}
+
+// authorizeRedirect send the user agent back to the client with params and state
+func authorizeRedirect(ctx *gin.Context, redirectURI string, state string, params url.Values) {
+	location, err := url.Parse(redirectURI)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	query := location.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	location.RawQuery = query.Encode()
+
+	status := http.StatusFound
+	if ctx.Request.Method == http.MethodPost {
+		status = http.StatusSeeOther
+	}
+	ctx.Redirect(status, location.String())
+}
+
+type AuthorizationCodeRequest struct {
+	Code         string `form:"code" binding:"required"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+}
+
+// redeemAuthorizationCode implements the RFC 6749 section 4.1.3 grant, a code is
+// marked used before anything else so it can only be redeemed once
+func (server *Server) redeemAuthorizationCode(ctx *gin.Context, client db.Client) {
+	var req AuthorizationCodeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, err.Error()))
+		return
+	}
+
+	code, err := server.store.UseAuthorizationCode(ctx, utils.HashToken(req.Code))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid or already used authorization code"))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	if code.ClientID != client.ID || time.Now().After(code.ExpiresAt) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid or already used authorization code"))
+		return
+	}
+	if code.RedirectUri != req.RedirectURI {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "redirect_uri doesn't match the authorization request"))
+		return
+	}
+	if !verifyCodeChallenge(code.CodeChallenge, req.CodeVerifier) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid code_verifier"))
+		return
+	}
+
+	response, err := server.newTokenResponse(ctx, client, code.UserID, code.Scopes)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	if server.idTokenMaker != nil && hasScope(code.Scopes, scopeOpenID) {
+		response.IDToken, err = server.newIDToken(client, code)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+			return
+		}
+		// the client gets a back-channel logout token when the user logs out
+		err = server.store.CreateClientLogin(ctx, db.CreateClientLoginParams{UserID: code.UserID, ClientID: client.ID})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// verifyCodeChallenge check the PKCE verifier, a verifier without a challenge is rejected
+// too so an attacker can't downgrade a PKCE flow (RFC 9700 section 2.1.1)
+func verifyCodeChallenge(challenge string, verifier string) bool {
+	if challenge == "" {
+		return verifier == ""
+	}
+	if len(verifier) < minCodeVerifierSize || len(verifier) > maxCodeVerifierSize {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// expiredCodes lets RunRevocationCleanup delete the authorization and device codes past their expiration
+type expiredCodes struct {
+	store db.Store
+}
+
+func (codes expiredCodes) DeleteExpired(ctx context.Context) error {
+	err := codes.store.DeleteExpiredAuthorizationCodes(ctx)
+	if err != nil {
+		return err
+	}
+	return codes.store.DeleteExpiredDeviceCodes(ctx)
+}
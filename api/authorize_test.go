@@ -0,0 +1,621 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedirectURI = "https://app.example.com/callback"
+
+// newPKCE returns a code verifier and its S256 challenge
+func newPKCE(t *testing.T) (string, string) {
+	verifier, err := utils.RandomSecureToken(32)
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(verifier))
+	return verifier, base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// createPublicClient is a spa or mobile app client, it has no secret
+func createPublicClient(t *testing.T, scopes ...string) db.Client {
+	client, _ := createClient(t, scopes...)
+	client.SecretHash = ""
+	client.Public = true
+	client.RedirectUris = []string{testRedirectURI}
+	return client
+}
+
+func requireRedirect(t *testing.T, recorder *httptest.ResponseRecorder, status int) url.Values {
+	require.Equal(t, status, recorder.Code)
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, testRedirectURI, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func TestAuthorize(t *testing.T) {
+
+	client := createPublicClient(t)
+	_, challenge := newPKCE(t)
+
+	testCases := []struct {
+		name          string
+		buildQuery    func(query url.Values)
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:       "OK",
+			buildQuery: func(query url.Values) {},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res AuthorizeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, client.Name, res.ClientName)
+				require.Equal(t, testRedirectURI, res.RedirectURI)
+				require.Equal(t, "xyz", res.State)
+			},
+		},
+		{
+			name: "UnknownClient",
+			buildQuery: func(query url.Values) {
+				query.Set("client_id", "unknown")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("unknown")).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClient)
+			},
+		},
+		{
+			name: "UnregisteredRedirectURI",
+			buildQuery: func(query url.Values) {
+				query.Set("redirect_uri", "https://evil.example.com/callback")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				// never redirect to a uri that wasn't registered
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Empty(t, recorder.Header().Get("Location"))
+			},
+		},
+		{
+			name: "RedirectURIPrefix",
+			buildQuery: func(query url.Values) {
+				query.Set("redirect_uri", testRedirectURI+"/../../evil")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnsupportedResponseType",
+			buildQuery: func(query url.Values) {
+				query.Set("response_type", "token")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusFound)
+				require.Equal(t, oauthUnsupportedResponseType, query.Get("error"))
+				require.Equal(t, "xyz", query.Get("state"))
+			},
+		},
+		{
+			name: "ScopeExceedsClient",
+			buildQuery: func(query url.Values) {
+				query.Set("scope", scopeAdminUsersRead)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusFound)
+				require.Equal(t, oauthInvalidScope, query.Get("error"))
+				require.Equal(t, "xyz", query.Get("state"))
+			},
+		},
+		{
+			name: "PublicClientWithoutPKCE",
+			buildQuery: func(query url.Values) {
+				query.Del("code_challenge")
+				query.Del("code_challenge_method")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusFound)
+				require.Equal(t, oauthInvalidRequest, query.Get("error"))
+			},
+		},
+		{
+			name: "PlainChallenge",
+			buildQuery: func(query url.Values) {
+				query.Set("code_challenge_method", "plain")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusFound)
+				require.Equal(t, oauthInvalidRequest, query.Get("error"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			query := url.Values{
+				"response_type":         {responseTypeCode},
+				"client_id":             {client.ID},
+				"redirect_uri":          {testRedirectURI},
+				"state":                 {"xyz"},
+				"code_challenge":        {challenge},
+				"code_challenge_method": {codeChallengeMethodS256},
+			}
+			tc.buildQuery(query)
+
+			request, err := http.NewRequest(http.MethodGet, "/authorize?"+query.Encode(), nil)
+			require.NoError(t, err)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestAuthorizeLogin(t *testing.T) {
+
+	user, password := CreateUser(t)
+	user.Roles = []string{"support"}
+	client := createPublicClient(t, scopeAdminUsersRead, scopeAdminUsersWrite)
+	_, challenge := newPKCE(t)
+
+	testCases := []struct {
+		name          string
+		buildForm     func(form url.Values)
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:      "OK",
+			buildForm: func(form url.Values) {},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateAuthorizationCodeParams) (db.AuthorizationCode, error) {
+						require.Equal(t, client.ID, arg.ClientID)
+						require.Equal(t, user.ID, arg.UserID)
+						require.Equal(t, testRedirectURI, arg.RedirectUri)
+						require.Equal(t, []string{scopeAdminUsersRead}, arg.Scopes)
+						require.Equal(t, challenge, arg.CodeChallenge)
+						require.Equal(t, "n-0S6_WzA2Mj", arg.Nonce)
+						require.WithinDuration(t, time.Now().Add(authorizationCodeDuration), arg.ExpiresAt, time.Second)
+						return db.AuthorizationCode{}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusSeeOther)
+				require.NotEmpty(t, query.Get("code"))
+				require.Equal(t, "xyz", query.Get("state"))
+			},
+		},
+		{
+			name: "WrongPassword",
+			buildForm: func(form url.Values) {
+				form.Set("password", "wrong-password")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Empty(t, recorder.Header().Get("Location"))
+			},
+		},
+		{
+			name: "UnknownUser",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			buildForm: func(form url.Values) {},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errInvalidCredentials.Error())
+			},
+		},
+		{
+			name: "ScopeExceedsUser",
+			buildForm: func(form url.Values) {
+				form.Set("scope", scopeAdminUsersWrite)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusSeeOther)
+				require.Equal(t, oauthInvalidScope, query.Get("error"))
+				require.Empty(t, query.Get("code"))
+			},
+		},
+		{
+			name: "ScopeExceedsClient",
+			buildForm: func(form url.Values) {
+				form.Set("scope", scopeAdminUsersRead+" "+scopeAdminMetricsRead)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusSeeOther)
+				require.Equal(t, oauthInvalidScope, query.Get("error"))
+				require.Empty(t, query.Get("code"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			form := url.Values{
+				"response_type":         {responseTypeCode},
+				"client_id":             {client.ID},
+				"redirect_uri":          {testRedirectURI},
+				"scope":                 {scopeAdminUsersRead},
+				"state":                 {"xyz"},
+				"nonce":                 {"n-0S6_WzA2Mj"},
+				"code_challenge":        {challenge},
+				"code_challenge_method": {codeChallengeMethodS256},
+				"username":              {user.Username},
+				"password":              {password},
+			}
+			tc.buildForm(form)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, newFormRequest(t, "/authorize", form))
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestTokenAuthorizationCode(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client := createPublicClient(t)
+	confidential, secret := createClient(t)
+	confidential.RedirectUris = []string{testRedirectURI}
+	verifier, challenge := newPKCE(t)
+	code := "authorization-code"
+
+	newAuthorizationCode := func(clientId string) db.AuthorizationCode {
+		return db.AuthorizationCode{
+			CodeHash:      utils.HashToken(code),
+			ClientID:      clientId,
+			UserID:        user.ID,
+			RedirectUri:   testRedirectURI,
+			Scopes:        []string{scopeAdminUsersRead},
+			CodeChallenge: challenge,
+			ExpiresAt:     time.Now().Add(time.Minute),
+		}
+	}
+
+	testCases := []struct {
+		name          string
+		buildRequest  func(form url.Values) *http.Request
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			buildRequest: func(form url.Values) *http.Request {
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Eq(utils.HashToken(code))).
+					Times(1).
+					Return(newAuthorizationCode(client.ID), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "Bearer", res.TokenType)
+				require.Equal(t, scopeAdminUsersRead, res.Scope)
+
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.UserId)
+				require.Equal(t, client.ID, payload.ClientID)
+				require.Equal(t, []string{scopeAdminUsersRead}, payload.Scopes)
+			},
+		},
+		{
+			name: "ConfidentialClient",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Del("client_id")
+				request := newFormRequest(t, "/token", form)
+				request.SetBasicAuth(confidential.ID, secret)
+				return request
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(confidential.ID)).
+					Times(1).
+					Return(confidential, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newAuthorizationCode(confidential.ID), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ConfidentialClientWithoutSecret",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("client_id", confidential.ID)
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(confidential.ID)).
+					Times(1).
+					Return(confidential, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClient)
+			},
+		},
+		{
+			name: "WrongVerifier",
+			buildRequest: func(form url.Values) *http.Request {
+				otherVerifier, _ := newPKCE(t)
+				form.Set("code_verifier", otherVerifier)
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newAuthorizationCode(client.ID), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "VerifierWithoutChallenge",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Del("client_id")
+				request := newFormRequest(t, "/token", form)
+				request.SetBasicAuth(confidential.ID, secret)
+				return request
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				authorizationCode := newAuthorizationCode(confidential.ID)
+				authorizationCode.CodeChallenge = ""
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(confidential.ID)).
+					Times(1).
+					Return(confidential, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "UsedCode",
+			buildRequest: func(form url.Values) *http.Request {
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AuthorizationCode{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "OtherClientCode",
+			buildRequest: func(form url.Values) *http.Request {
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newAuthorizationCode(confidential.ID), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "ExpiredCode",
+			buildRequest: func(form url.Values) *http.Request {
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				authorizationCode := newAuthorizationCode(client.ID)
+				authorizationCode.ExpiresAt = time.Now().Add(-time.Second)
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(authorizationCode, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "RedirectURIMismatch",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("redirect_uri", "https://app.example.com/other")
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newAuthorizationCode(client.ID), nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "PublicClientTokenExchange",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("grant_type", grantTypeTokenExchange)
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			form := url.Values{
+				"grant_type":    {grantTypeAuthorizationCode},
+				"client_id":     {client.ID},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+			}
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, tc.buildRequest(form))
+			tc.checkResponse(t, recorder, server)
+		})
+	}
+}
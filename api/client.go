@@ -0,0 +1,75 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var errInvalidClient = errors.New("invalid client credentials !")
+
+// authenticateClient check the calling client credentials, sent with http basic auth
+// or as client_id and client_secret form fields, when allowPublic is set public clients
+// are identified by client_id alone
+func (server *Server) authenticateClient(ctx *gin.Context, allowPublic bool) (db.Client, error) {
+	clientId, clientSecret, ok := ctx.Request.BasicAuth()
+	if !ok {
+		clientId = ctx.PostForm("client_id")
+		clientSecret = ctx.PostForm("client_secret")
+	}
+	if clientId == "" || (clientSecret == "" && !allowPublic) {
+		return db.Client{}, errInvalidClient
+	}
+
+	client, err := server.store.GetClient(ctx, clientId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return db.Client{}, errInvalidClient
+		}
+		return db.Client{}, err
+	}
+
+	// a public client has no secret, a confidential one always needs it
+	if client.Public {
+		if !allowPublic || clientSecret != "" {
+			return db.Client{}, errInvalidClient
+		}
+		return client, nil
+	}
+	// registered clients only authenticate the way they registered
+	if (client.TokenEndpointAuthMethod == authMethodClientSecretBasic && !ok) ||
+		(client.TokenEndpointAuthMethod == authMethodClientSecretPost && ok) {
+		return db.Client{}, errInvalidClient
+	}
+	if clientSecret == "" || utils.VerifyPassword(client.SecretHash, clientSecret) != nil {
+		return db.Client{}, errInvalidClient
+	}
+	return client, nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// unallowedScope returns the first of scopes the client wasn't registered with, empty when it may
+// ask for all of them, the OpenID scopes are allowed to every client once id tokens are issued
+func (server *Server) unallowedScope(client db.Client, scopes []string) string {
+	for _, scope := range scopes {
+		if hasScope(client.Scopes, scope) {
+			continue
+		}
+		if server.idTokenMaker != nil && hasScope(oidcScopes, scope) {
+			continue
+		}
+		return scope
+	}
+	return ""
+}
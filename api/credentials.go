@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+// ClientResponse is what /me returns when the token was issued to a client
+type ClientResponse struct {
+	ClientID string   `json:"client_id"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+}
+
+// clientCredentials implements the RFC 6749 section 4.4 grant, the token subject is
+// the client itself and its scopes are at most the ones the client was registered with
+func (server *Server) clientCredentials(ctx *gin.Context, client db.Client) {
+	scopes := client.Scopes
+	if scope := ctx.PostForm("scope"); scope != "" {
+		scopes = strings.Fields(scope)
+		for _, s := range scopes {
+			if !hasScope(client.Scopes, s) {
+				ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidScope, "client isn't allowed the scope "+s))
+				return
+			}
+		}
+	}
+
+	// no user id, the client is the principal
+	server.issueToken(ctx, client, "", scopes, token.WithSubject(client.ID))
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenClientCredentials(t *testing.T) {
+
+	client, secret := createClient(t, "reports:read", "reports:write")
+
+	testCases := []struct {
+		name          string
+		buildRequest  func(form url.Values) *http.Request
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			buildRequest: func(form url.Values) *http.Request {
+				request := newFormRequest(t, "/token", form)
+				request.SetBasicAuth(client.ID, secret)
+				return request
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "Bearer", res.TokenType)
+				require.Equal(t, "reports:read reports:write", res.Scope)
+				require.Empty(t, res.RefreshToken)
+
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.True(t, payload.IsClient())
+				require.Empty(t, payload.UserId)
+				require.Equal(t, client.ID, payload.Subject)
+				require.Equal(t, client.ID, payload.ClientID)
+				require.Equal(t, client.Scopes, payload.Scopes)
+			},
+		},
+		{
+			name: "RequestedScope",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("client_id", client.ID)
+				form.Set("client_secret", secret)
+				form.Set("scope", "reports:read")
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, []string{"reports:read"}, payload.Scopes)
+			},
+		},
+		{
+			name: "ScopeNotAllowed",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("scope", "reports:read "+scopeAdminUsersRead)
+				request := newFormRequest(t, "/token", form)
+				request.SetBasicAuth(client.ID, secret)
+				return request
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidScope)
+			},
+		},
+		{
+			name: "WrongSecret",
+			buildRequest: func(form url.Values) *http.Request {
+				request := newFormRequest(t, "/token", form)
+				request.SetBasicAuth(client.ID, "wrong-secret")
+				return request
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClient)
+			},
+		},
+		{
+			name: "PublicClient",
+			buildRequest: func(form url.Values) *http.Request {
+				form.Set("client_id", client.ID)
+				return newFormRequest(t, "/token", form)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			form := url.Values{"grant_type": {grantTypeClientCredentials}}
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, tc.buildRequest(form))
+			tc.checkResponse(t, recorder, server)
+		})
+	}
+}
+
+func TestClientPrincipal(t *testing.T) {
+
+	client, _ := createClient(t, "reports:read")
+
+	testCases := []struct {
+		name          string
+		method        string
+		url           string
+		clientId      string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "Me",
+			method:   http.MethodGet,
+			url:      "/me",
+			clientId: client.ID,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res ClientResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, ClientResponse{ClientID: client.ID, Name: client.Name, Scopes: client.Scopes}, res)
+			},
+		},
+		{
+			name:     "MeDeletedClient",
+			method:   http.MethodGet,
+			url:      "/me",
+			clientId: client.ID,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:     "LogoutAll",
+			method:   http.MethodPost,
+			url:      "/logout/all",
+			clientId: client.ID,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "NoPrincipal",
+			method: http.MethodGet,
+			url:    "/me",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().Me(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			accessToken, err := server.tokenMaker.CreateToken("", time.Minute,
+				token.WithSubject(tc.clientId), token.WithClientID(tc.clientId), token.WithScopes(client.Scopes...))
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
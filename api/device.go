@@ -0,0 +1,346 @@
+package api
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+	deviceCodeSize      = 32
+	deviceCodeDuration  = 10 * time.Minute
+	// devicePollInterval is the initial polling interval, slow_down adds deviceSlowDown to it
+	devicePollInterval = 5 * time.Second
+	deviceSlowDown     = 5 * time.Second
+	// no vowels so user codes never spell words, no look-alike characters (RFC 8628 section 6.1)
+	userCodeCharset = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeSize    = 8
+	// a few retries if a user code happens to collide with a pending one
+	userCodeAttempts = 3
+)
+
+// RFC 8628 section 3.5 polling errors
+const (
+	oauthAuthorizationPending = "authorization_pending"
+	oauthSlowDown             = "slow_down"
+	oauthAccessDenied         = "access_denied"
+	oauthExpiredToken         = "expired_token"
+)
+
+const (
+	deviceCodePending  = "pending"
+	deviceCodeApproved = "approved"
+	deviceCodeDenied   = "denied"
+	deviceCodeUsed     = "used"
+)
+
+var errDeviceCodeNotFound = fmt.Errorf("unknown or expired user code !")
+
+// DeviceCodeResponse is the RFC 8628 section 3.2 device authorization response
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceCode starts a device authorization, the device shows the user code and polls
+// the token endpoint while the user approves it from another device
+func (server *Server) DeviceCode(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	client, err := server.authenticateClient(ctx, true)
+	if err == errInvalidClient {
+		ctx.JSON(http.StatusUnauthorized, oauthError(oauthInvalidClient, err.Error()))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	if !clientAllowsGrant(client, grantTypeDeviceCode) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthUnauthorizedClient, "the client isn't registered for the device grant"))
+		return
+	}
+	scopes := strings.Fields(ctx.PostForm("scope"))
+	if scope := server.unallowedScope(client, scopes); scope != "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidScope, "client isn't allowed the scope "+scope))
+		return
+	}
+
+	deviceCode, err := utils.RandomSecureToken(deviceCodeSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	var code db.DeviceCode
+	for attempt := 0; attempt < userCodeAttempts; attempt++ {
+		var userCode string
+		userCode, err = newUserCode()
+		if err != nil {
+			break
+		}
+		code, err = server.store.CreateDeviceCode(ctx, db.CreateDeviceCodeParams{
+			DeviceCodeHash: utils.HashToken(deviceCode),
+			UserCode:       userCode,
+			ClientID:       client.ID,
+			Scopes:         scopes,
+			PollInterval:   int32(devicePollInterval.Seconds()),
+			ExpiresAt:      time.Now().Add(deviceCodeDuration),
+		})
+		if pqError, ok := err.(*pq.Error); !ok || pqError.Code.Name() != "unique_violation" {
+			break
+		}
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	verificationURI := server.requestOrigin(ctx) + "/device"
+	ctx.JSON(http.StatusOK, DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                code.UserCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + code.UserCode,
+		ExpiresIn:               int64(deviceCodeDuration.Seconds()),
+		Interval:                int64(code.PollInterval),
+	})
+}
+
+// newUserCode returns a random XXXX-XXXX code, short enough to type on a phone
+func newUserCode() (string, error) {
+	code := make([]byte, userCodeSize)
+	max := big.NewInt(int64(len(userCodeCharset)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeCharset[n.Int64()]
+	}
+	return string(code[:userCodeSize/2]) + "-" + string(code[userCodeSize/2:]), nil
+}
+
+// normalizeUserCode accept user codes typed in lower case, without or with extra dashes and spaces
+func normalizeUserCode(userCode string) string {
+	userCode = strings.ToUpper(userCode)
+	userCode = strings.NewReplacer("-", "", " ", "").Replace(userCode)
+	if len(userCode) != userCodeSize {
+		return userCode
+	}
+	return userCode[:userCodeSize/2] + "-" + userCode[userCodeSize/2:]
+}
+
+type DeviceRequest struct {
+	UserCode string `form:"user_code" json:"user_code" binding:"required"`
+}
+
+// DeviceInfoResponse tells the user which client asks for what before they approve it
+type DeviceInfoResponse struct {
+	UserCode   string   `json:"user_code"`
+	ClientID   string   `json:"client_id"`
+	ClientName string   `json:"client_name"`
+	Scopes     []string `json:"scopes"`
+}
+
+// DeviceInfo describe the pending authorization of a user code
+func (server *Server) DeviceInfo(ctx *gin.Context) {
+	var req DeviceRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	code, err := server.store.GetDeviceCodeByUserCode(ctx, normalizeUserCode(req.UserCode))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errResponse(errDeviceCodeNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	if code.Status != deviceCodePending || time.Now().After(code.ExpiresAt) {
+		ctx.JSON(http.StatusNotFound, errResponse(errDeviceCodeNotFound))
+		return
+	}
+
+	client, err := server.store.GetClient(ctx, code.ClientID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, DeviceInfoResponse{
+		UserCode:   code.UserCode,
+		ClientID:   client.ID,
+		ClientName: client.Name,
+		Scopes:     code.Scopes,
+	})
+}
+
+type DeviceApproveRequest struct {
+	UserCode string `json:"user_code" binding:"required"`
+	Action   string `json:"action" binding:"required,oneof=approve deny"`
+}
+
+// DeviceApprove let the logged in user approve or deny a user code, the device gets its
+// token on the next poll
+func (server *Server) DeviceApprove(ctx *gin.Context) {
+	payload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !ok {
+		err := fmt.Errorf("something went wrong checking token payload !")
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+	// only the user themselves, not a client acting for them, can hand out a new token
+	if payload.ClientID != "" {
+		err := fmt.Errorf("devices can only be approved with a login token !")
+		ctx.JSON(http.StatusForbidden, errResponse(err))
+		return
+	}
+
+	var req DeviceApproveRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+	userCode := normalizeUserCode(req.UserCode)
+
+	if req.Action == "deny" {
+		_, err := server.store.DenyDeviceCode(ctx, userCode)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(http.StatusNotFound, errResponse(errDeviceCodeNotFound))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{})
+		return
+	}
+
+	code, err := server.store.GetDeviceCodeByUserCode(ctx, userCode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errResponse(errDeviceCodeNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	// like AuthorizeLogin the device never gets more than the user, whatever the login token has
+	user, ok := server.currentUser(ctx, payload)
+	if !ok {
+		return
+	}
+	userScopes := server.userScopes(user)
+	for _, scope := range code.Scopes {
+		if !hasScope(userScopes, scope) {
+			err := fmt.Errorf("the device asks for more than your scopes : %s", strings.Join(code.Scopes, " "))
+			ctx.JSON(http.StatusForbidden, errResponse(err))
+			return
+		}
+	}
+
+	_, err = server.store.ApproveDeviceCode(ctx, db.ApproveDeviceCodeParams{
+		UserCode: userCode,
+		UserID:   sql.NullString{String: payload.UserId, Valid: true},
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errResponse(errDeviceCodeNotFound))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// redeemDeviceCode implements the RFC 8628 section 3.4 grant polled by the device
+func (server *Server) redeemDeviceCode(ctx *gin.Context, client db.Client) {
+	deviceCode := ctx.PostForm("device_code")
+	if deviceCode == "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "device_code is required"))
+		return
+	}
+
+	code, err := server.store.GetDeviceCode(ctx, utils.HashToken(deviceCode))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid device_code"))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	if code.ClientID != client.ID {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid device_code"))
+		return
+	}
+	if time.Now().After(code.ExpiresAt) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthExpiredToken, "the device code has expired"))
+		return
+	}
+	switch code.Status {
+	case deviceCodeDenied:
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthAccessDenied, "the user denied the authorization"))
+		return
+	case deviceCodeUsed:
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "the device code has already been used"))
+		return
+	}
+
+	// a device polling faster than its interval has to wait 5 more seconds from now on
+	interval := time.Duration(code.PollInterval) * time.Second
+	tooFast := code.PolledAt.Valid && time.Since(code.PolledAt.Time) < interval
+	if tooFast {
+		interval += deviceSlowDown
+	}
+	err = server.store.PollDeviceCode(ctx, db.PollDeviceCodeParams{
+		DeviceCodeHash: code.DeviceCodeHash,
+		PollInterval:   int32(interval.Seconds()),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	if tooFast {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthSlowDown, fmt.Sprintf("poll at most every %d seconds", int64(interval.Seconds()))))
+		return
+	}
+	if code.Status == deviceCodePending {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthAuthorizationPending, "the user hasn't approved the device yet"))
+		return
+	}
+
+	// only one concurrent poll gets the token
+	code, err = server.store.UseDeviceCode(ctx, code.DeviceCodeHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "the device code has already been used"))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	server.issueToken(ctx, client, code.UserID.String, code.Scopes)
+}
@@ -0,0 +1,589 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserCode(t *testing.T) {
+	pattern := regexp.MustCompile(`^[` + userCodeCharset + `]{4}-[` + userCodeCharset + `]{4}$`)
+	for i := 0; i < 20; i++ {
+		userCode, err := newUserCode()
+		require.NoError(t, err)
+		require.Regexp(t, pattern, userCode)
+	}
+
+	require.Equal(t, "WDJB-MJHT", normalizeUserCode("wdjb mjht"))
+	require.Equal(t, "WDJB-MJHT", normalizeUserCode("WDJBMJHT"))
+	require.Equal(t, "WDJB-MJHT", normalizeUserCode("wdjb-mjht"))
+}
+
+func TestDeviceCode(t *testing.T) {
+
+	client := createPublicClient(t, scopeAdminUsersRead)
+
+	testCases := []struct {
+		name          string
+		clientId      string
+		scope         string
+		publicURL     string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:     "OK",
+			clientId: client.ID,
+			scope:    scopeAdminUsersRead,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().CreateDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateDeviceCodeParams) (db.DeviceCode, error) {
+						require.Equal(t, client.ID, arg.ClientID)
+						require.Equal(t, []string{scopeAdminUsersRead}, arg.Scopes)
+						require.Equal(t, int32(5), arg.PollInterval)
+						require.WithinDuration(t, time.Now().Add(deviceCodeDuration), arg.ExpiresAt, time.Second)
+						return db.DeviceCode{UserCode: arg.UserCode, PollInterval: arg.PollInterval}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res DeviceCodeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.NotEmpty(t, res.DeviceCode)
+				require.Len(t, res.UserCode, userCodeSize+1)
+				require.Equal(t, "http://auth.example.com/device", res.VerificationURI)
+				require.Equal(t, res.VerificationURI+"?user_code="+res.UserCode, res.VerificationURIComplete)
+				require.Equal(t, int64(600), res.ExpiresIn)
+				require.Equal(t, int64(5), res.Interval)
+			},
+		},
+		{
+			name:      "BehindProxy",
+			clientId:  client.ID,
+			scope:     scopeAdminUsersRead,
+			publicURL: "https://login.example.com",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().CreateDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DeviceCode{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res DeviceCodeResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "https://login.example.com/device", res.VerificationURI)
+			},
+		},
+		{
+			name:     "ScopeExceedsClient",
+			clientId: client.ID,
+			scope:    scopeAdminUsersRead + " " + scopeAdminUsersWrite,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().CreateDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidScope)
+			},
+		},
+		{
+			name:     "OpenIDDisabled",
+			clientId: client.ID,
+			scope:    scopeOpenID,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().CreateDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidScope)
+			},
+		},
+		{
+			name:     "UnknownClient",
+			clientId: "unknown",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq("unknown")).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+				store.EXPECT().CreateDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			form := url.Values{"client_id": {tc.clientId}, "scope": {tc.scope}}
+			request := newFormRequest(t, "http://auth.example.com/device/code", form)
+
+			server := newTestServer(t, store)
+			server.config.PublicURL = tc.publicURL
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeviceApprove(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	user.Roles = []string{"support"}
+	userCode := "WDJB-MJHT"
+	pending := db.DeviceCode{UserCode: userCode, Scopes: []string{scopeAdminUsersRead}, Status: deviceCodePending}
+	openID := db.DeviceCode{UserCode: userCode, Scopes: []string{scopeOpenID, scopeProfile, scopeEmail}, Status: deviceCodePending}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		opts          []token.PayloadOption
+		oidc          bool
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Approve",
+			body: gin.H{"user_code": "wdjb-mjht", "action": "approve"},
+			opts: []token.PayloadOption{token.WithScopes(scopeAdminUsersRead)},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(pending, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				arg := db.ApproveDeviceCodeParams{UserCode: userCode, UserID: sql.NullString{String: user.ID, Valid: true}}
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(db.DeviceCode{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ApproveOpenID",
+			body: gin.H{"user_code": userCode, "action": "approve"},
+			oidc: true,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(openID, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DeviceCode{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "OpenIDDisabled",
+			body: gin.H{"user_code": userCode, "action": "approve"},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(openID, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "Deny",
+			body: gin.H{"user_code": userCode, "action": "deny"},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().DenyDeviceCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(db.DeviceCode{}, nil)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "ScopeExceedsUser",
+			body: gin.H{"user_code": userCode, "action": "approve"},
+			// the scopes come from the user's current roles, not the login token
+			opts: []token.PayloadOption{token.WithScopes(scopeAdminUsersRead)},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(pending, nil)
+				demoted := user
+				demoted.Roles = []string{}
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(demoted, nil)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "ClientToken",
+			body: gin.H{"user_code": userCode, "action": "approve"},
+			opts: []token.PayloadOption{token.WithClientID("third-party"), token.WithScopes(scopeAdminUsersRead)},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "NotPending",
+			body: gin.H{"user_code": userCode, "action": "approve"},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(userCode)).
+					Times(1).
+					Return(db.DeviceCode{UserCode: userCode, Status: deviceCodeDenied}, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DeviceCode{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name: "InvalidAction",
+			body: gin.H{"user_code": userCode, "action": "maybe"},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().ApproveDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			if tc.oidc {
+				server = newOIDCTestServer(t, store)
+			}
+			accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute, tc.opts...)
+			require.NoError(t, err)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+			request, err := http.NewRequest(http.MethodPost, "/device", bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestDeviceInfo(t *testing.T) {
+
+	client := createPublicClient(t)
+	code := db.DeviceCode{
+		UserCode:  "WDJB-MJHT",
+		ClientID:  client.ID,
+		Scopes:    []string{scopeAdminUsersRead},
+		Status:    deviceCodePending,
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetDeviceCodeByUserCode(gomock.Any(), gomock.Eq(code.UserCode)).
+		Times(1).
+		Return(code, nil)
+	store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+		Times(1).
+		Return(client, nil)
+
+	server := newTestServer(t, store)
+	accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodGet, "/device?user_code=wdjbmjht", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var res DeviceInfoResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Equal(t, DeviceInfoResponse{UserCode: code.UserCode, ClientID: client.ID, ClientName: client.Name, Scopes: code.Scopes}, res)
+}
+
+func TestTokenDeviceCode(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client := createPublicClient(t)
+	deviceCode := "device-code"
+
+	newDeviceCode := func(status string) db.DeviceCode {
+		code := db.DeviceCode{
+			DeviceCodeHash: utils.HashToken(deviceCode),
+			UserCode:       "WDJB-MJHT",
+			ClientID:       client.ID,
+			Scopes:         []string{scopeAdminUsersRead},
+			Status:         status,
+			PollInterval:   5,
+			ExpiresAt:      time.Now().Add(time.Minute),
+		}
+		if status == deviceCodeApproved {
+			code.UserID = sql.NullString{String: user.ID, Valid: true}
+		}
+		return code
+	}
+
+	testCases := []struct {
+		name          string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			buildStabs: func(store *mockdb.MockStore) {
+				code := newDeviceCode(deviceCodeApproved)
+				code.PolledAt = sql.NullTime{Time: time.Now().Add(-6 * time.Second), Valid: true}
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Eq(utils.HashToken(deviceCode))).
+					Times(1).
+					Return(code, nil)
+				store.EXPECT().PollDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Eq(code.DeviceCodeHash)).
+					Times(1).
+					Return(code, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.UserId)
+				require.Equal(t, client.ID, payload.ClientID)
+				require.Equal(t, []string{scopeAdminUsersRead}, payload.Scopes)
+			},
+		},
+		{
+			name: "AuthorizationPending",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newDeviceCode(deviceCodePending), nil)
+				arg := db.PollDeviceCodeParams{DeviceCodeHash: utils.HashToken(deviceCode), PollInterval: 5}
+				store.EXPECT().PollDeviceCode(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthAuthorizationPending)
+			},
+		},
+		{
+			name: "SlowDown",
+			buildStabs: func(store *mockdb.MockStore) {
+				code := newDeviceCode(deviceCodeApproved)
+				code.PolledAt = sql.NullTime{Time: time.Now().Add(-time.Second), Valid: true}
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(code, nil)
+				// the interval grows by 5 seconds for every poll that came too early
+				arg := db.PollDeviceCodeParams{DeviceCodeHash: code.DeviceCodeHash, PollInterval: 10}
+				store.EXPECT().PollDeviceCode(gomock.Any(), gomock.Eq(arg)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthSlowDown)
+			},
+		},
+		{
+			name: "AccessDenied",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newDeviceCode(deviceCodeDenied), nil)
+				store.EXPECT().PollDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthAccessDenied)
+			},
+		},
+		{
+			name: "ExpiredToken",
+			buildStabs: func(store *mockdb.MockStore) {
+				code := newDeviceCode(deviceCodeApproved)
+				code.ExpiresAt = time.Now().Add(-time.Second)
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(code, nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthExpiredToken)
+			},
+		},
+		{
+			name: "AlreadyUsed",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newDeviceCode(deviceCodeUsed), nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "ConcurrentPoll",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(newDeviceCode(deviceCodeApproved), nil)
+				store.EXPECT().PollDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.DeviceCode{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "OtherClient",
+			buildStabs: func(store *mockdb.MockStore) {
+				code := newDeviceCode(deviceCodeApproved)
+				code.ClientID = "other-client"
+				store.EXPECT().GetDeviceCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(code, nil)
+				store.EXPECT().UseDeviceCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+				Times(1).
+				Return(client, nil)
+			tc.buildStabs(store)
+
+			form := url.Values{
+				"grant_type":  {grantTypeDeviceCode},
+				"client_id":   {client.ID},
+				"device_code": {deviceCode},
+			}
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, newFormRequest(t, "/token", form))
+			tc.checkResponse(t, recorder, server)
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	dpopHeaderKey         = "DPoP"
+	authorizationTypeDPoP = "dpop"
+	dpopKeyKey            = "dpop_jkt"
+	// dpopProofMaxAge is how old (or how far in the future) a proof iat may be
+	dpopProofMaxAge = time.Minute
+)
+
+var errDPoPProofReused = fmt.Errorf("DPoP proof has already been used !")
+
+// requestURL is the htu a proof for this request must carry
+func (server *Server) requestURL(ctx *gin.Context) string {
+	return server.requestOrigin(ctx) + ctx.Request.URL.Path
+}
+
+// requestOrigin is the scheme and host the request was sent to, the configured public url
+// when a proxy terminates TLS in front of the server
+func (server *Server) requestOrigin(ctx *gin.Context) string {
+	if server.config.PublicURL != "" {
+		return server.config.PublicURL
+	}
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + ctx.Request.Host
+}
+
+// verifyDPoP check the DPoP header of the request, accessToken is empty on token requests
+func (server *Server) verifyDPoP(ctx *gin.Context, accessToken string) (*token.DPoPProof, error) {
+	maxAge := dpopProofMaxAge + server.config.TokenLeeway
+
+	proof, err := token.VerifyDPoPProof(ctx.GetHeader(dpopHeaderKey), ctx.Request.Method, server.requestURL(ctx), accessToken, maxAge)
+	if err != nil {
+		return nil, err
+	}
+	if server.dpopReplays.Seen(proof.JKT+":"+proof.ID, proof.IssuedAt.Add(maxAge)) {
+		return nil, errDPoPProofReused
+	}
+	return proof, nil
+}
+
+// dpopBinding is used on routes issuing tokens, a request with a DPoP proof gets
+// tokens bound to the proof key, one without gets bearer tokens
+func (server *Server) dpopBinding() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader(dpopHeaderKey) == "" {
+			ctx.Next()
+			return
+		}
+
+		proof, err := server.verifyDPoP(ctx, "")
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errResponse(err))
+			return
+		}
+		ctx.Set(dpopKeyKey, proof.JKT)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,337 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const dpopTestHost = "http://auth.example.com"
+
+// newDPoPKey returns a client key and its thumbprint
+func newDPoPKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, err := token.NewJWK(token.PublicKey{Key: &key.PublicKey})
+	require.NoError(t, err)
+	jkt, err := jwk.Thumbprint()
+	require.NoError(t, err)
+	return key, jkt
+}
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, method string, url string, accessToken string) string {
+	jwk, err := token.NewJWK(token.PublicKey{Key: &key.PublicKey})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{
+		"jti": uuid.New().String(),
+		"iat": time.Now().Unix(),
+		"htm": method,
+		"htu": url,
+	}
+	if accessToken != "" {
+		claims["ath"] = token.AccessTokenHash(accessToken)
+	}
+	proof := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	proof.Header["typ"] = "dpop+jwt"
+	proof.Header["jwk"] = jwk
+
+	signed, err := proof.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestAuthMiddlewareDPoP(t *testing.T) {
+
+	url := dpopTestHost + "/auth"
+	key, jkt := newDPoPKey(t)
+	otherKey, _ := newDPoPKey(t)
+
+	testCases := []struct {
+		name          string
+		setAuth       func(t *testing.T, request *http.Request, server *Server)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+				request.Header.Add(dpopHeaderKey, newDPoPProof(t, key, http.MethodGet, url, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "BearerStillAccepted",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "BoundTokenAsBearer",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+				request.Header.Add(dpopHeaderKey, newDPoPProof(t, key, http.MethodGet, url, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "MissingProof",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Header().Get("WWW-Authenticate"), "invalid_dpop_proof")
+			},
+		},
+		{
+			name: "OtherKey",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+				request.Header.Add(dpopHeaderKey, newDPoPProof(t, otherKey, http.MethodGet, url, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "WrongMethod",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+				request.Header.Add(dpopHeaderKey, newDPoPProof(t, key, http.MethodPost, url, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UnboundTokenWithDPoPScheme",
+			setAuth: func(t *testing.T, request *http.Request, server *Server) {
+				accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+				require.NoError(t, err)
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+				request.Header.Add(dpopHeaderKey, newDPoPProof(t, key, http.MethodGet, url, accessToken))
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			server.router.GET("/auth", server.authMiddleware(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			tc.setAuth(t, request, server)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestAuthMiddlewareDPoPReplay(t *testing.T) {
+
+	url := dpopTestHost + "/auth"
+	key, jkt := newDPoPKey(t)
+
+	server := newTestServer(t, nil)
+	server.router.GET("/auth", server.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+	require.NoError(t, err)
+	proof := newDPoPProof(t, key, http.MethodGet, url, accessToken)
+
+	for _, code := range []int{http.StatusOK, http.StatusUnauthorized} {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+		request.Header.Add(dpopHeaderKey, proof)
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, code, recorder.Code)
+	}
+}
+
+func TestAuthMiddlewareDPoPBehindProxy(t *testing.T) {
+
+	key, jkt := newDPoPKey(t)
+
+	server := newTestServer(t, nil)
+	server.config.PublicURL = "https://auth.example.com"
+	server.router.GET("/auth", server.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithDPoPKey(jkt))
+	require.NoError(t, err)
+
+	// the proxy terminated TLS and forwards plain http to the internal address
+	for url, code := range map[string]int{
+		"https://auth.example.com/auth": http.StatusOK,
+		"http://10.0.0.2:8080/auth":     http.StatusUnauthorized,
+	} {
+		request, err := http.NewRequest(http.MethodGet, "http://10.0.0.2:8080/auth", nil)
+		require.NoError(t, err)
+		request.Header.Add(authorizationHeaderKey, fmt.Sprintf("DPoP %s", accessToken))
+		request.Header.Add(dpopHeaderKey, newDPoPProof(t, key, http.MethodGet, url, accessToken))
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, code, recorder.Code, url)
+	}
+}
+
+func TestLoginDPoP(t *testing.T) {
+
+	user, password := CreateUser(t)
+	url := dpopTestHost + "/login"
+	key, jkt := newDPoPKey(t)
+
+	testCases := []struct {
+		name          string
+		proof         func(t *testing.T) string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			proof: func(t *testing.T) string {
+				return newDPoPProof(t, key, http.MethodPost, url, "")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+						require.Equal(t, jkt, arg.DpopJkt)
+						return db.Session{}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var response AuthResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+				require.Equal(t, "DPoP", response.TokenType)
+
+				payload, err := server.tokenMaker.VerifyToken(response.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, jkt, payload.DPoPKey())
+				require.Empty(t, payload.Fingerprint)
+				require.Empty(t, recorder.Result().Cookies())
+			},
+		},
+		{
+			name: "InvalidProof",
+			proof: func(t *testing.T) string {
+				return newDPoPProof(t, key, http.MethodGet, url, "")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			request.Header.Add(dpopHeaderKey, tc.proof(t))
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder, server)
+		})
+	}
+}
+
+func TestRefreshTokenDPoPBound(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	refreshToken := "bound-refresh-token"
+	session := createSession(user.ID, refreshToken)
+	_, session.DpopJkt = newDPoPKey(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(session.RefreshTokenHash)).
+		Times(1).
+		Return(session, nil)
+	store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	data, err := json.Marshal(gin.H{"refresh_token": refreshToken})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/token/refresh", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+const tokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+type TokenExchangeRequest struct {
+	SubjectToken       string   `form:"subject_token" binding:"required"`
+	SubjectTokenType   string   `form:"subject_token_type" binding:"required"`
+	ActorToken         string   `form:"actor_token"`
+	ActorTokenType     string   `form:"actor_token_type"`
+	Audience           []string `form:"audience"`
+	Scope              string   `form:"scope"`
+	RequestedTokenType string   `form:"requested_token_type"`
+}
+
+// verifyExchangedToken check a subject or actor token, their audience is whoever
+// calls us so only issuer, expiry and revocation matter
+func (server *Server) verifyExchangedToken(ctx *gin.Context, exchanged string) (*token.Payload, error) {
+	opts := append(server.verifyOptions(), token.ExpectAudience())
+	payload, err := server.tokenMaker.VerifyToken(exchanged, opts...)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, token.ErrRevokedToken
+	}
+	// the new token couldn't carry the caveats, exchanging would drop them
+	if len(payload.Caveats) > 0 {
+		return nil, token.ErrCaveatNotSatisfied
+	}
+	return payload, nil
+}
+
+// exchangeToken implements RFC 8693, the new token is for the requested audience only,
+// with at most the subject scopes, and its act claim records who is acting
+func (server *Server) exchangeToken(ctx *gin.Context, client db.Client) {
+	var req TokenExchangeRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, err.Error()))
+		return
+	}
+	if req.SubjectTokenType != tokenTypeAccessToken || (req.ActorToken != "" && req.ActorTokenType != tokenTypeAccessToken) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "only access tokens can be exchanged"))
+		return
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != tokenTypeAccessToken {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "only access tokens can be requested"))
+		return
+	}
+
+	// policy : a client may only get tokens for the audiences it was registered for
+	if len(req.Audience) == 0 {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidTarget, "audience is required"))
+		return
+	}
+	for _, audience := range req.Audience {
+		if !hasScope(client.ExchangeAudiences, audience) {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidTarget, fmt.Sprintf("client can't exchange tokens for audience %s", audience)))
+			return
+		}
+	}
+
+	subject, err := server.verifyExchangedToken(ctx, req.SubjectToken)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid subject token"))
+		return
+	}
+
+	scopes := subject.Scopes
+	if req.Scope != "" {
+		scopes = strings.Fields(req.Scope)
+		if !subject.HasScopes(scopes...) {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidScope, "requested scope exceeds the subject token scope"))
+			return
+		}
+	}
+
+	// without an actor token the calling client is the one acting
+	actor := &token.Actor{Subject: client.ID, ClientID: client.ID, Actor: subject.Actor}
+	if req.ActorToken != "" {
+		actorPayload, err := server.verifyExchangedToken(ctx, req.ActorToken)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidGrant, "invalid actor token"))
+			return
+		}
+		actor.Subject = actorPayload.Subject
+	}
+
+	// never outlive the subject token
+	duration := server.config.TokenDuration
+	if remaining := time.Until(subject.ExpiredAt); remaining < duration {
+		duration = remaining
+	}
+
+	opts := []token.PayloadOption{
+		token.WithSubject(subject.Subject),
+		token.WithAudience(req.Audience...),
+		token.WithRoles(subject.Roles...),
+		token.WithScopes(scopes...),
+		token.WithClientID(client.ID),
+		token.WithActor(actor),
+	}
+	if server.config.TokenIssuer != "" {
+		opts = append(opts, token.WithIssuer(server.config.TokenIssuer))
+	}
+	binding, tokenType := server.tokenBinding(ctx)
+	opts = append(opts, binding...)
+
+	accessToken, err := server.tokenMaker.CreateToken(subject.UserId, duration, opts...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, TokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       tokenType,
+		ExpiresIn:       int64(duration.Seconds()),
+		Scope:           strings.Join(scopes, " "),
+		IssuedTokenType: tokenTypeAccessToken,
+	})
+}
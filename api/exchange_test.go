@@ -0,0 +1,226 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenExchange(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client, secret := createClient(t)
+	client.ExchangeAudiences = []string{"billing"}
+
+	newSubjectToken := func(t *testing.T, server *Server) string {
+		subjectToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute,
+			token.WithAudience("gateway"), token.WithScopes("orders:read", "billing:read"))
+		require.NoError(t, err)
+		return subjectToken
+	}
+
+	testCases := []struct {
+		name          string
+		buildForm     func(t *testing.T, server *Server, form url.Values)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("scope", "billing:read")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, tokenTypeAccessToken, res.IssuedTokenType)
+				require.Equal(t, "Bearer", res.TokenType)
+				require.Equal(t, "billing:read", res.Scope)
+				require.LessOrEqual(t, res.ExpiresIn, int64(60))
+
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.UserId)
+				require.Equal(t, []string{"billing"}, payload.Audience)
+				require.Equal(t, []string{"billing:read"}, payload.Scopes)
+				require.Equal(t, client.ID, payload.ClientID)
+				require.Equal(t, &token.Actor{Subject: client.ID, ClientID: client.ID}, payload.Actor)
+			},
+		},
+		{
+			name: "ActorToken",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				actorToken, err := server.tokenMaker.CreateToken("orders-service", time.Minute)
+				require.NoError(t, err)
+				form.Set("actor_token", actorToken)
+				form.Set("actor_token_type", tokenTypeAccessToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				// no scope requested keeps the subject ones
+				require.Equal(t, []string{"orders:read", "billing:read"}, payload.Scopes)
+				require.Equal(t, "orders-service", payload.Actor.Subject)
+			},
+		},
+		{
+			name: "DelegationChain",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				subjectToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute,
+					token.WithActor(&token.Actor{Subject: "gateway"}))
+				require.NoError(t, err)
+				form.Set("subject_token", subjectToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res TokenResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, client.ID, payload.Actor.Subject)
+				require.Equal(t, "gateway", payload.Actor.Actor.Subject)
+			},
+		},
+		{
+			name: "ScopeEscalation",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("scope", "billing:write")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidScope)
+			},
+		},
+		{
+			name: "AudienceNotAllowed",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("audience", "payroll")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidTarget)
+			},
+		},
+		{
+			name: "RevokedSubjectToken",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				payload, err := server.tokenMaker.VerifyToken(form.Get("subject_token"))
+				require.NoError(t, err)
+				require.NoError(t, server.revocations.Revoke(context.Background(), payload))
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "InvalidActorToken",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("actor_token", "invalid")
+				form.Set("actor_token_type", tokenTypeAccessToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "UnsupportedTokenType",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("subject_token_type", "urn:ietf:params:oauth:token-type:id_token")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidRequest)
+			},
+		},
+		{
+			name: "AttenuatedSubjectToken",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				maker, err := token.NewMacaroonMaker(utils.RandomString(32))
+				require.NoError(t, err)
+				server.tokenMaker = maker
+
+				subjectToken, err := token.AttenuateMacaroon(newSubjectToken(t, server), token.ReadOnly())
+				require.NoError(t, err)
+				form.Set("subject_token", subjectToken)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidGrant)
+			},
+		},
+		{
+			name: "UnsupportedGrant",
+			buildForm: func(t *testing.T, server *Server, form url.Values) {
+				form.Set("grant_type", "password")
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthUnsupportedGrantType)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+				Times(1).
+				Return(client, nil)
+
+			server := newTestServer(t, store)
+			form := url.Values{}
+			form.Set("grant_type", grantTypeTokenExchange)
+			form.Set("subject_token", newSubjectToken(t, server))
+			form.Set("subject_token_type", tokenTypeAccessToken)
+			form.Set("audience", "billing")
+			tc.buildForm(t, server, form)
+
+			request := newFormRequest(t, "/token", form)
+			request.SetBasicAuth(client.ID, secret)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server)
+		})
+	}
+}
+
+func TestTokenInvalidClient(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	server := newTestServer(t, store)
+	request := newFormRequest(t, "/token", url.Values{"grant_type": {grantTypeTokenExchange}})
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+	require.Contains(t, recorder.Body.String(), oauthInvalidClient)
+}
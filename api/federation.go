@@ -0,0 +1,440 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	upstreamCookieName = "__Host-Upstream"
+	upstreamSecretSize = 32
+	// the user has that long to log in at the provider
+	upstreamLoginDuration = 10 * time.Minute
+	upstreamTimeout       = 10 * time.Second
+	// clock skew tolerated with the provider when checking its id tokens
+	upstreamLeeway          = time.Minute
+	maxUpstreamResponseSize = 1 << 20
+)
+
+// upstream id token claims users are created from
+var (
+	emailClaim         = token.NewClaim[string]("email")
+	emailVerifiedClaim = token.NewClaim[bool]("email_verified")
+	nameClaim          = token.NewClaim[string]("name")
+	azpClaim           = token.NewClaim[string]("azp")
+)
+
+var (
+	errUnknownProvider       = fmt.Errorf("unknown login provider !")
+	errUpstreamState         = fmt.Errorf("login state is missing or doesn't match, start the login again !")
+	errUpstreamEmail         = fmt.Errorf("the login provider didn't share a verified email !")
+	errUpstreamAccountExists = fmt.Errorf("an account already uses this email, log in with your password !")
+)
+
+// upstreamMetadata is the part of the provider discovery document the login needs
+type upstreamMetadata struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// upstreamProvider is an external OpenID provider users can log in with, its
+// discovery document is fetched on the first login
+type upstreamProvider struct {
+	config utils.UpstreamProvider
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *upstreamMetadata
+	keys     *token.JWKSVerifier
+}
+
+func newUpstreamClient() *http.Client {
+	return &http.Client{Timeout: upstreamTimeout}
+}
+
+func newUpstreamProviders(configs []utils.UpstreamProvider, client *http.Client) map[string]*upstreamProvider {
+	providers := make(map[string]*upstreamProvider, len(configs))
+	for _, config := range configs {
+		providers[config.Name] = &upstreamProvider{config: config, client: client}
+	}
+	return providers
+}
+
+// decodeUpstreamResponse decode a provider response, anything but 200 is an error
+func decodeUpstreamResponse(response *http.Response, v interface{}) error {
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s answered %s", response.Request.URL, response.Status)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, maxUpstreamResponseSize)).Decode(v)
+}
+
+// discover fetch the discovery document once, a failure is retried on the next login
+func (provider *upstreamProvider) discover(ctx context.Context) (*upstreamMetadata, error) {
+	provider.mu.Lock()
+	defer provider.mu.Unlock()
+	if provider.metadata != nil {
+		return provider.metadata, nil
+	}
+
+	configurationURL := strings.TrimSuffix(provider.config.Issuer, "/") + "/.well-known/openid-configuration"
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, configurationURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	var metadata upstreamMetadata
+	err = decodeUpstreamResponse(response, &metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	// OpenID Connect Discovery section 4.3, the document can't speak for another issuer
+	if metadata.Issuer != provider.config.Issuer {
+		return nil, fmt.Errorf("%s discovery document is for issuer %q", provider.config.Name, metadata.Issuer)
+	}
+	for _, endpoint := range []string{metadata.AuthorizationEndpoint, metadata.TokenEndpoint, metadata.JWKSURI} {
+		if !strings.HasPrefix(endpoint, "https://") {
+			return nil, fmt.Errorf("%s endpoints must be https urls", provider.config.Name)
+		}
+	}
+
+	provider.metadata = &metadata
+	provider.keys = token.NewJWKSVerifier(metadata.JWKSURI, provider.client)
+	return provider.metadata, nil
+}
+
+// exchange redeem the authorization code at the provider token endpoint for an id token
+func (provider *upstreamProvider) exchange(ctx context.Context, metadata *upstreamMetadata, code string, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {grantTypeAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {provider.config.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	// client_secret_basic is the default, unless the provider only takes client_secret_post
+	methods := metadata.TokenEndpointAuthMethodsSupported
+	postOnly := hasScope(methods, authMethodClientSecretPost) && !hasScope(methods, authMethodClientSecretBasic)
+	basic := provider.config.ClientSecret != "" && !postOnly
+	if !basic {
+		form.Set("client_id", provider.config.ClientID)
+		if provider.config.ClientSecret != "" {
+			form.Set("client_secret", provider.config.ClientSecret)
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if basic {
+		// RFC 6749 section 2.3.1, the credentials are form encoded before going in the header
+		request.SetBasicAuth(url.QueryEscape(provider.config.ClientID), url.QueryEscape(provider.config.ClientSecret))
+	}
+
+	response, err := provider.client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	var res struct {
+		IDToken string `json:"id_token"`
+	}
+	err = decodeUpstreamResponse(response, &res)
+	if err != nil {
+		return "", err
+	}
+	if res.IDToken == "" {
+		return "", fmt.Errorf("%s token response has no id_token", provider.config.Name)
+	}
+	return res.IDToken, nil
+}
+
+// verifyIDToken check the id token was issued by the provider to this server for the login of nonce
+func (provider *upstreamProvider) verifyIDToken(metadata *upstreamMetadata, idToken string, nonce string) (*token.Payload, error) {
+	payload, err := provider.keys.VerifyToken(idToken,
+		token.ExpectIssuer(metadata.Issuer),
+		token.ExpectAudience(provider.config.ClientID),
+		token.AllowLeeway(upstreamLeeway),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tokenNonce, err := nonceClaim.Get(payload)
+	if err != nil || subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("id token nonce doesn't match the login !")
+	}
+	// OpenID Connect Core section 3.1.3.7, a token for several audiences names the one it was issued to
+	if len(payload.Audience) > 1 {
+		azp, _ := azpClaim.Get(payload)
+		if azp != provider.config.ClientID {
+			return nil, token.ErrInvalidAudience
+		}
+	}
+	if payload.Subject == "" {
+		return nil, token.ErrInvalidToken
+	}
+	return payload, nil
+}
+
+// upstreamLogin is what the cookie remembers between the redirect to the provider and the callback
+type upstreamLogin struct {
+	provider string
+	state    string
+	nonce    string
+	verifier string
+}
+
+func newUpstreamLogin(provider string) (upstreamLogin, error) {
+	login := upstreamLogin{provider: provider}
+	for _, secret := range []*string{&login.state, &login.nonce, &login.verifier} {
+		var err error
+		*secret, err = utils.RandomSecureToken(upstreamSecretSize)
+		if err != nil {
+			return upstreamLogin{}, err
+		}
+	}
+	return login, nil
+}
+
+// provider names and base64url secrets never contain dots
+func (login upstreamLogin) String() string {
+	return strings.Join([]string{login.provider, login.state, login.nonce, login.verifier}, ".")
+}
+
+func parseUpstreamLogin(value string) (upstreamLogin, bool) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 4 {
+		return upstreamLogin{}, false
+	}
+	return upstreamLogin{provider: parts[0], state: parts[1], nonce: parts[2], verifier: parts[3]}, true
+}
+
+func setUpstreamCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     upstreamCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		// Lax so the cookie comes back with the provider redirect
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+type UpstreamProviderRequest struct {
+	Provider string `uri:"provider" binding:"required"`
+}
+
+// lookupUpstream find the provider of the route, answering 404 when it isn't configured
+func (server *Server) lookupUpstream(ctx *gin.Context) (*upstreamProvider, bool) {
+	var req UpstreamProviderRequest
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return nil, false
+	}
+	provider, ok := server.upstreams[req.Provider]
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errResponse(errUnknownProvider))
+		return nil, false
+	}
+	return provider, true
+}
+
+// UpstreamLogin send the browser to the provider authorization endpoint, the state, nonce and
+// PKCE verifier wait for the callback in a cookie
+func (server *Server) UpstreamLogin(ctx *gin.Context) {
+	provider, ok := server.lookupUpstream(ctx)
+	if !ok {
+		return
+	}
+	metadata, err := provider.discover(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errResponse(err))
+		return
+	}
+
+	login, err := newUpstreamLogin(provider.config.Name)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	location, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errResponse(err))
+		return
+	}
+
+	scopes := []string{scopeOpenID}
+	for _, scope := range provider.config.Scopes {
+		if !hasScope(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	challenge := sha256.Sum256([]byte(login.verifier))
+	query := location.Query()
+	query.Set("response_type", responseTypeCode)
+	query.Set("client_id", provider.config.ClientID)
+	query.Set("redirect_uri", provider.config.RedirectURL)
+	query.Set("scope", strings.Join(scopes, " "))
+	query.Set("state", login.state)
+	query.Set("nonce", login.nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", codeChallengeMethodS256)
+	location.RawQuery = query.Encode()
+
+	setUpstreamCookie(ctx, login.String(), int(upstreamLoginDuration.Seconds()))
+	ctx.Redirect(http.StatusFound, location.String())
+}
+
+type UpstreamCallbackRequest struct {
+	Code             string `form:"code"`
+	State            string `form:"state"`
+	Error            string `form:"error"`
+	ErrorDescription string `form:"error_description"`
+}
+
+// UpstreamCallback finish the login the provider redirected back, the user of the id token
+// subject gets a session like after Login and is created at their first login
+func (server *Server) UpstreamCallback(ctx *gin.Context) {
+	provider, ok := server.lookupUpstream(ctx)
+	if !ok {
+		return
+	}
+
+	var req UpstreamCallbackRequest
+	err := ctx.ShouldBindQuery(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	// the cookie is good for a single callback whatever happens next, and binds it to
+	// the browser that started the login so nobody can log a victim into their account
+	cookie, _ := ctx.Cookie(upstreamCookieName)
+	setUpstreamCookie(ctx, "", -1)
+	login, ok := parseUpstreamLogin(cookie)
+	if !ok || login.provider != provider.config.Name || req.State == "" ||
+		subtle.ConstantTimeCompare([]byte(login.state), []byte(req.State)) != 1 {
+		ctx.JSON(http.StatusBadRequest, errResponse(errUpstreamState))
+		return
+	}
+	if req.Error != "" {
+		err := fmt.Errorf("%s login failed : %s %s", provider.config.Name, req.Error, req.ErrorDescription)
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+	if req.Code == "" {
+		ctx.JSON(http.StatusBadRequest, errResponse(fmt.Errorf("code is required !")))
+		return
+	}
+
+	metadata, err := provider.discover(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errResponse(err))
+		return
+	}
+	idToken, err := provider.exchange(ctx, metadata, req.Code, login.verifier)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errResponse(err))
+		return
+	}
+	payload, err := provider.verifyIDToken(metadata, idToken, login.nonce)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+
+	user, status, err := server.upstreamUser(ctx, provider, payload)
+	if err != nil {
+		ctx.JSON(status, errResponse(err))
+		return
+	}
+
+	response, err := server.createSession(ctx, user, "")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// upstreamUser returns the user of the provider subject, creating it at the first login. users
+// are never linked to an existing account by email, whoever controls an upstream account claiming
+// an address must not get into the password account using it
+func (server *Server) upstreamUser(ctx *gin.Context, provider *upstreamProvider, payload *token.Payload) (db.User, int, error) {
+	identity := db.GetUserByUpstreamIdentityParams{
+		UpstreamProvider: provider.config.Name,
+		UpstreamSubject:  payload.Subject,
+	}
+	user, err := server.store.GetUserByUpstreamIdentity(ctx, identity)
+	if err == nil {
+		return user, 0, nil
+	}
+	if err != sql.ErrNoRows {
+		return db.User{}, http.StatusInternalServerError, err
+	}
+
+	email, err := emailClaim.Get(payload)
+	verified, verifiedErr := emailVerifiedClaim.Get(payload)
+	// email_verified is optional, only an email the provider says it didn't verify is refused
+	if err != nil || email == "" || (verifiedErr == nil && !verified) {
+		return db.User{}, http.StatusForbidden, errUpstreamEmail
+	}
+	name, err := nameClaim.Get(payload)
+	if err != nil || name == "" {
+		name = email
+	}
+
+	user, err = server.store.CreateUser(ctx, db.CreateUserParams{
+		ID: uuid.New().String(),
+		// unique without depending on anything the upstream user can change
+		Username: provider.config.Name + ":" + payload.Subject,
+		Email:    email,
+		Name:     name,
+		// no password hash, password logins always fail
+		Password:         "",
+		UpstreamProvider: identity.UpstreamProvider,
+		UpstreamSubject:  identity.UpstreamSubject,
+	})
+	if err != nil {
+		pqError, ok := err.(*pq.Error)
+		if ok && pqError.Code.Name() == "unique_violation" {
+			// a concurrent callback of the same user may have created it first
+			user, getErr := server.store.GetUserByUpstreamIdentity(ctx, identity)
+			if getErr == nil {
+				return user, 0, nil
+			}
+			return db.User{}, http.StatusConflict, errUpstreamAccountExists
+		}
+		return db.User{}, http.StatusInternalServerError, err
+	}
+	return user, 0, nil
+}
@@ -0,0 +1,550 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUpstreamName        = "corp"
+	testUpstreamClientID    = "go-auth"
+	testUpstreamSecret      = "upstream secret"
+	testUpstreamRedirectURL = "https://auth.example.com/login/corp/callback"
+	testUpstreamSubject     = "248289761001"
+	testUpstreamEmail       = "jane@corp.example.com"
+)
+
+// fakeProvider is an in-process OpenID provider, its authorize endpoint logs in
+// testUpstreamSubject without asking anything
+type fakeProvider struct {
+	*httptest.Server
+	// signer signs the id tokens, the jwks only publish the keys of maker
+	maker  token.Maker
+	signer token.Maker
+	// idTokenOptions are applied after the standard claims so they can override them
+	idTokenOptions []token.PayloadOption
+
+	mu sync.Mutex
+	// codes are the authorize requests waiting to be redeemed
+	codes map[string]url.Values
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	maker, err := token.NewJWTMaker("ES256", key)
+	require.NoError(t, err)
+
+	provider := &fakeProvider{maker: maker, signer: maker, codes: make(map[string]url.Values)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", provider.configuration)
+	mux.HandleFunc("/jwks", provider.jwks)
+	mux.HandleFunc("/authorize", provider.authorize)
+	mux.HandleFunc("/token", provider.token)
+	provider.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(provider.Close)
+	return provider
+}
+
+func (provider *fakeProvider) configuration(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(upstreamMetadata{
+		Issuer:                            provider.URL,
+		AuthorizationEndpoint:             provider.URL + "/authorize",
+		TokenEndpoint:                     provider.URL + "/token",
+		JWKSURI:                           provider.URL + "/jwks",
+		TokenEndpointAuthMethodsSupported: []string{authMethodClientSecretBasic},
+	})
+}
+
+func (provider *fakeProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	set := token.JWKSet{}
+	for _, key := range provider.maker.(token.KeyPublisher).PublicKeys(time.Now()) {
+		jwk, err := token.NewJWK(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	json.NewEncoder(w).Encode(set)
+}
+
+func (provider *fakeProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != testUpstreamClientID || query.Get("redirect_uri") != testUpstreamRedirectURL ||
+		query.Get("response_type") != responseTypeCode || query.Get("code_challenge_method") != codeChallengeMethodS256 ||
+		!hasScope(strings.Fields(query.Get("scope")), scopeOpenID) {
+		http.Error(w, "invalid authorize request", http.StatusBadRequest)
+		return
+	}
+
+	code := utils.RandomString(16)
+	provider.mu.Lock()
+	provider.codes[code] = query
+	provider.mu.Unlock()
+
+	location, _ := url.Parse(testUpstreamRedirectURL)
+	location.RawQuery = url.Values{"code": {code}, "state": {query.Get("state")}}.Encode()
+	http.Redirect(w, r, location.String(), http.StatusFound)
+}
+
+func (provider *fakeProvider) token(w http.ResponseWriter, r *http.Request) {
+	clientID, secret, _ := r.BasicAuth()
+	clientID, _ = url.QueryUnescape(clientID)
+	secret, _ = url.QueryUnescape(secret)
+	if clientID != testUpstreamClientID || secret != testUpstreamSecret {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	provider.mu.Lock()
+	authorize, ok := provider.codes[r.PostFormValue("code")]
+	delete(provider.codes, r.PostFormValue("code"))
+	provider.mu.Unlock()
+	if !ok || r.PostFormValue("grant_type") != grantTypeAuthorizationCode ||
+		r.PostFormValue("redirect_uri") != authorize.Get("redirect_uri") ||
+		!verifyCodeChallenge(authorize.Get("code_challenge"), r.PostFormValue("code_verifier")) {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts := append([]token.PayloadOption{
+		token.WithIssuer(provider.URL),
+		token.WithAudience(testUpstreamClientID),
+		token.WithSubject(testUpstreamSubject),
+		nonceClaim.With(authorize.Get("nonce")),
+		emailClaim.With(testUpstreamEmail),
+		emailVerifiedClaim.With(true),
+		nameClaim.With("Jane Doe"),
+	}, provider.idTokenOptions...)
+	idToken, err := provider.signer.CreateToken("", time.Minute, opts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "upstream-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+func newUpstreamTestServer(t *testing.T, store db.Store, provider *fakeProvider) *Server {
+	server := newTestServer(t, store)
+	server.config.UpstreamProviders = []utils.UpstreamProvider{{
+		Name:         testUpstreamName,
+		Issuer:       provider.URL,
+		ClientID:     testUpstreamClientID,
+		ClientSecret: testUpstreamSecret,
+		RedirectURL:  testUpstreamRedirectURL,
+		Scopes:       []string{"email", "profile"},
+	}}
+	server.upstreams = newUpstreamProviders(server.config.UpstreamProviders, provider.Client())
+	return server
+}
+
+// followUpstreamLogin start the login at /login/corp, follows it through the fake provider and
+// returns the callback request carrying the cookie of the first step
+func followUpstreamLogin(t *testing.T, server *Server, provider *fakeProvider) *http.Request {
+	request, err := http.NewRequest(http.MethodGet, "/login/"+testUpstreamName, nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	browser := &http.Client{
+		Transport: provider.Client().Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	response, err := browser.Get(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	response.Body.Close()
+	require.Equal(t, http.StatusFound, response.StatusCode)
+	callback, err := url.Parse(response.Header.Get("Location"))
+	require.NoError(t, err)
+
+	request, err = http.NewRequest(http.MethodGet, "/login/"+testUpstreamName+"/callback?"+callback.RawQuery, nil)
+	require.NoError(t, err)
+	for _, cookie := range recorder.Result().Cookies() {
+		request.AddCookie(cookie)
+	}
+	return request
+}
+
+func TestUpstreamLoginRedirect(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	provider := newFakeProvider(t)
+	server := newUpstreamTestServer(t, mockdb.NewMockStore(ctrl), provider)
+
+	request, err := http.NewRequest(http.MethodGet, "/login/"+testUpstreamName, nil)
+	require.NoError(t, err)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusFound, recorder.Code)
+
+	location, err := url.Parse(recorder.Header().Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, provider.URL+"/authorize", location.Scheme+"://"+location.Host+location.Path)
+	query := location.Query()
+	require.Equal(t, testUpstreamClientID, query.Get("client_id"))
+	require.Equal(t, testUpstreamRedirectURL, query.Get("redirect_uri"))
+	require.Equal(t, "openid email profile", query.Get("scope"))
+	require.Equal(t, codeChallengeMethodS256, query.Get("code_challenge_method"))
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, upstreamCookieName, cookies[0].Name)
+	require.True(t, cookies[0].HttpOnly)
+	require.True(t, cookies[0].Secure)
+	require.Equal(t, http.SameSiteLaxMode, cookies[0].SameSite)
+
+	// the secrets stay in the cookie, only their proofs go to the provider
+	login, ok := parseUpstreamLogin(cookies[0].Value)
+	require.True(t, ok)
+	require.Equal(t, login.state, query.Get("state"))
+	require.Equal(t, login.nonce, query.Get("nonce"))
+	require.True(t, verifyCodeChallenge(query.Get("code_challenge"), login.verifier))
+	require.NotContains(t, location.RawQuery, login.verifier)
+
+	t.Run("UnknownProvider", func(t *testing.T) {
+		request, err := http.NewRequest(http.MethodGet, "/login/unknown", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("ProviderDown", func(t *testing.T) {
+		down := newFakeProvider(t)
+		server := newUpstreamTestServer(t, mockdb.NewMockStore(ctrl), down)
+		down.Close()
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusBadGateway, recorder.Code)
+	})
+}
+
+func TestUpstreamCallback(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	user.UpstreamProvider = testUpstreamName
+	user.UpstreamSubject = testUpstreamSubject
+	identity := db.GetUserByUpstreamIdentityParams{UpstreamProvider: testUpstreamName, UpstreamSubject: testUpstreamSubject}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherMaker, err := token.NewJWTMaker("ES256", otherKey)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		idTokenOptions []token.PayloadOption
+		signer         token.Maker
+		buildStabs     func(store *mockdb.MockStore)
+		checkResponse  func(recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "NewUser",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.CreateUserParams) (db.User, error) {
+						require.NotEmpty(t, arg.ID)
+						require.Equal(t, "corp:"+testUpstreamSubject, arg.Username)
+						require.Equal(t, testUpstreamEmail, arg.Email)
+						require.Equal(t, "Jane Doe", arg.Name)
+						require.Empty(t, arg.Password)
+						require.Equal(t, testUpstreamName, arg.UpstreamProvider)
+						require.Equal(t, testUpstreamSubject, arg.UpstreamSubject)
+						return user, nil
+					})
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res AuthResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.NotEmpty(t, res.RefreshToken)
+				payload, err := server.tokenMaker.VerifyToken(res.AccessToken)
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.UserId)
+			},
+		},
+		{
+			name: "ExistingUser",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:           "UnverifiedEmail",
+			idTokenOptions: []token.PayloadOption{emailVerifiedClaim.With(false)},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "EmailTaken",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+					Times(2).
+					Return(db.User{}, sql.ErrNoRows)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pq.Error{Code: "23505"})
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusConflict, recorder.Code)
+			},
+		},
+		{
+			name: "ConcurrentFirstLogin",
+			buildStabs: func(store *mockdb.MockStore) {
+				gomock.InOrder(
+					store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+						Times(1).
+						Return(db.User{}, sql.ErrNoRows),
+					store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Eq(identity)).
+						Times(1).
+						Return(user, nil),
+				)
+				store.EXPECT().CreateUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, &pq.Error{Code: "23505"})
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:           "WrongAudience",
+			idTokenOptions: []token.PayloadOption{token.WithAudience("another-client")},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:           "SharedAudienceWithoutAzp",
+			idTokenOptions: []token.PayloadOption{token.WithAudience(testUpstreamClientID, "another-client")},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:           "WrongIssuer",
+			idTokenOptions: []token.PayloadOption{token.WithIssuer("https://evil.example.com")},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:           "WrongNonce",
+			idTokenOptions: []token.PayloadOption{nonceClaim.With("replayed-nonce")},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "UnpublishedKey",
+			signer: otherMaker,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUserByUpstreamIdentity(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			provider := newFakeProvider(t)
+			provider.idTokenOptions = tc.idTokenOptions
+			if tc.signer != nil {
+				provider.signer = tc.signer
+			}
+			server := newUpstreamTestServer(t, store, provider)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, followUpstreamLogin(t, server, provider))
+			tc.checkResponse(recorder, server)
+		})
+	}
+}
+
+func TestUpstreamCallbackState(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		tamper        func(request *http.Request) *http.Request
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "MissingCookie",
+			tamper: func(request *http.Request) *http.Request {
+				request.Header.Del("Cookie")
+				return request
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "WrongState",
+			tamper: func(request *http.Request) *http.Request {
+				query := request.URL.Query()
+				query.Set("state", "attacker-state")
+				request.URL.RawQuery = query.Encode()
+				return request
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "OtherProvider",
+			tamper: func(request *http.Request) *http.Request {
+				request.URL.Path = "/login/other/callback"
+				return request
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ProviderError",
+			tamper: func(request *http.Request) *http.Request {
+				query := request.URL.Query()
+				query.Del("code")
+				query.Set("error", "access_denied")
+				request.URL.RawQuery = query.Encode()
+				return request
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), "access_denied")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			// nothing gets to the store without a valid callback
+			store := mockdb.NewMockStore(ctrl)
+			provider := newFakeProvider(t)
+			server := newUpstreamTestServer(t, store, provider)
+			server.upstreams["other"] = newUpstreamProviders([]utils.UpstreamProvider{{Name: "other", Issuer: provider.URL}}, provider.Client())["other"]
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, tc.tamper(followUpstreamLogin(t, server, provider)))
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestUpstreamUserPasswordLogin(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user, _ := CreateUser(t)
+	user.Password = ""
+	user.UpstreamProvider = testUpstreamName
+	user.UpstreamSubject = testUpstreamSubject
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(0)
+
+	// users created by an upstream login have no password to log in with
+	server := newTestServer(t, store)
+	request := newJSONRequest(t, http.MethodPost, "/login", gin.H{"username": user.Username, "password": "password"})
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// the __Host- prefix makes browsers refuse the cookie unless it's Secure, on / and without Domain
+	fingerprintCookieName = "__Host-Fgp"
+	fingerprintSize       = 32
+)
+
+var errFingerprintMismatch = fmt.Errorf("token fingerprint cookie is missing or doesn't match !")
+
+// newFingerprint returns a random fingerprint and its hash to bind the tokens to,
+// the cookie is set with setFingerprint once the tokens are stored
+func newFingerprint() (string, string, error) {
+	fingerprint, err := utils.RandomSecureToken(fingerprintSize)
+	if err != nil {
+		return "", "", err
+	}
+	return fingerprint, utils.HashToken(fingerprint), nil
+}
+
+// setFingerprint send the fingerprint cookie of a new session, scripts can't read it
+// so a token stolen through XSS can't be replayed
+func setFingerprint(ctx *gin.Context, response *AuthResponse) {
+	if response.fingerprint != "" {
+		setFingerprintCookie(ctx, response.fingerprint, 0)
+	}
+}
+
+// clearFingerprint removes the fingerprint cookie from the browser
+func clearFingerprint(ctx *gin.Context) {
+	setFingerprintCookie(ctx, "", -1)
+}
+
+func setFingerprintCookie(ctx *gin.Context, value string, maxAge int) {
+	http.SetCookie(ctx.Writer, &http.Cookie{
+		Name:     fingerprintCookieName,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// checkFingerprint compare the request cookie with the token fingerprint, tokens without one pass
+func checkFingerprint(ctx *gin.Context, payload *token.Payload) error {
+	return matchFingerprint(ctx, payload.Fingerprint)
+}
+
+// matchFingerprint compare the request cookie with a fingerprint hash, an empty hash always matches
+func matchFingerprint(ctx *gin.Context, fingerprintHash string) error {
+	if fingerprintHash == "" {
+		return nil
+	}
+	fingerprint, err := ctx.Cookie(fingerprintCookieName)
+	if err != nil {
+		return errFingerprintMismatch
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(fingerprint)), []byte(fingerprintHash)) != 1 {
+		return errFingerprintMismatch
+	}
+	return nil
+}
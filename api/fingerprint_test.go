@@ -0,0 +1,197 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginFingerprint(t *testing.T) {
+
+	user, password := CreateUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	var session db.CreateSessionParams
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+			session = arg
+			return db.Session{}, nil
+		})
+
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	cookie := cookies[0]
+	require.Equal(t, fingerprintCookieName, cookie.Name)
+	require.Equal(t, "/", cookie.Path)
+	require.True(t, cookie.HttpOnly)
+	require.True(t, cookie.Secure)
+	require.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+
+	var response AuthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	payload, err := server.tokenMaker.VerifyToken(response.AccessToken)
+	require.NoError(t, err)
+	require.Equal(t, utils.HashToken(cookie.Value), payload.Fingerprint)
+	// the refresh token is bound to the same cookie
+	require.Equal(t, payload.Fingerprint, session.FingerprintHash)
+}
+
+func TestLoginFingerprintNotStored(t *testing.T) {
+
+	user, password := CreateUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.Session{}, sql.ErrConnDone)
+
+	server := newTestServer(t, store)
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusInternalServerError, recorder.Code)
+	// no session was stored so the cookie of a previous one must be kept
+	require.Empty(t, recorder.Result().Cookies())
+}
+
+func TestAuthMiddlewareFingerprint(t *testing.T) {
+
+	fingerprint, err := utils.RandomSecureToken(fingerprintSize)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		fingerprint   string
+		setupCookie   func(request *http.Request)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:        "OK",
+			fingerprint: fingerprint,
+			setupCookie: func(request *http.Request) {
+				request.AddCookie(&http.Cookie{Name: fingerprintCookieName, Value: fingerprint})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:        "MissingCookie",
+			fingerprint: fingerprint,
+			setupCookie: func(request *http.Request) {},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errFingerprintMismatch.Error())
+			},
+		},
+		{
+			name:        "WrongCookie",
+			fingerprint: fingerprint,
+			setupCookie: func(request *http.Request) {
+				request.AddCookie(&http.Cookie{Name: fingerprintCookieName, Value: "stolen"})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:        "NoFingerprint",
+			setupCookie: func(request *http.Request) {},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url := "/fingerprinted"
+
+			server := newTestServer(t, nil)
+			server.router.GET(url, server.authMiddleware(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			var opts []token.PayloadOption
+			if tc.fingerprint != "" {
+				opts = append(opts, token.WithFingerprint(utils.HashToken(tc.fingerprint)))
+			}
+			accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, opts...)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			tc.setupCookie(request)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestLogoutClearsFingerprint(t *testing.T) {
+
+	server := newTestServer(t, nil)
+	accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/logout", bytes.NewReader([]byte("{}")))
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	cookies := recorder.Result().Cookies()
+	require.Len(t, cookies, 1)
+	require.Equal(t, fingerprintCookieName, cookies[0].Name)
+	require.Empty(t, cookies[0].Value)
+	require.Negative(t, cookies[0].MaxAge)
+}
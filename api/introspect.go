@@ -0,0 +1,109 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+const scopeTokenIntrospect = "token:introspect"
+
+type IntrospectRequest struct {
+	Token         string `form:"token" binding:"required"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// IntrospectResponse follow RFC 7662, an inactive token only has active set
+type IntrospectResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	// Confirmation is set for bound tokens so the resource server can check the proof or certificate
+	Confirmation *token.Confirmation `json:"cnf,omitempty"`
+	Actor        *token.Actor        `json:"act,omitempty"`
+	// Caveats of an attenuated macaroon, the resource server has to enforce them
+	Caveats []token.Caveat `json:"caveats,omitempty"`
+}
+
+// Introspect let an authenticated resource server ask whether an access token is active,
+// browser tokens bound to the fingerprint cookie are always reported inactive
+func (server *Server) Introspect(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	client, err := server.authenticateClient(ctx, false)
+	if err == errInvalidClient {
+		ctx.Header("WWW-Authenticate", `Basic realm="introspect"`)
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	if !hasScope(client.Scopes, scopeTokenIntrospect) {
+		err := fmt.Errorf("insufficient scope, required : %s", scopeTokenIntrospect)
+		ctx.JSON(http.StatusForbidden, errResponse(err))
+		return
+	}
+
+	var req IntrospectRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	// tokens of every audience can be introspected, the caller decide what to accept
+	opts := append(server.verifyOptions(), token.ExpectAudience())
+	payload, err := server.tokenMaker.VerifyToken(req.Token, opts...)
+	if err != nil {
+		ctx.JSON(http.StatusOK, IntrospectResponse{Active: false})
+		return
+	}
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	if revoked {
+		ctx.JSON(http.StatusOK, IntrospectResponse{Active: false})
+		return
+	}
+	// the __Host- cookie never reaches a resource server so it can't check the fingerprint,
+	// such a token only works against this server and was likely taken out of a browser
+	if payload.Fingerprint != "" {
+		ctx.JSON(http.StatusOK, IntrospectResponse{Active: false})
+		return
+	}
+
+	response := IntrospectResponse{
+		Active:    true,
+		Scope:     strings.Join(payload.Scopes, " "),
+		ClientID:  payload.ClientID,
+		Subject:   payload.Subject,
+		ExpiresAt: payload.ExpiredAt.Unix(),
+		IssuedAt:  payload.IssuedAt.Unix(),
+		NotBefore: payload.NotBefore.Unix(),
+		Issuer:    payload.Issuer,
+		Audience:  payload.Audience,
+		ID:        payload.ID,
+		TokenType: "Bearer",
+		Actor:     payload.Actor,
+		Caveats:   payload.Caveats,
+	}
+	if payload.DPoPKey() != "" {
+		response.TokenType = "DPoP"
+	}
+	response.Confirmation = payload.Confirmation
+	ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func createClient(t *testing.T, scopes ...string) (db.Client, string) {
+	secret := utils.RandomString(32)
+	hash, err := utils.HashPassword(secret)
+	require.NoError(t, err)
+
+	client := db.Client{
+		ID:         utils.RandomString(12),
+		Name:       utils.RandomString(8),
+		SecretHash: hash,
+		Scopes:     scopes,
+	}
+	return client, secret
+}
+
+func TestIntrospect(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client, secret := createClient(t, scopeTokenIntrospect)
+	other, otherSecret := createClient(t)
+
+	testCases := []struct {
+		name          string
+		setupRequest  func(t *testing.T, server *Server, request *http.Request, form url.Values)
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "Active",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var res IntrospectResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.True(t, res.Active)
+				require.Equal(t, user.ID, res.Subject)
+				require.Equal(t, "admin:users:read", res.Scope)
+				require.Equal(t, "web-app", res.ClientID)
+				require.NotZero(t, res.ExpiresAt)
+				require.NotZero(t, res.IssuedAt)
+			},
+		},
+		{
+			name: "FormCredentials",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				form.Set("client_id", client.ID)
+				form.Set("client_secret", secret)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireActive(t, recorder, true)
+			},
+		},
+		{
+			name: "InvalidToken",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+				form.Set("token", "v2.local.invalid")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"active":false}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "ExpiredToken",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+				accessToken, err := server.tokenMaker.CreateToken(user.ID, -time.Minute)
+				require.NoError(t, err)
+				form.Set("token", accessToken)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"active":false}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "FingerprintedToken",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+				accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute,
+					token.WithFingerprint(utils.HashToken(utils.RandomString(43))))
+				require.NoError(t, err)
+				form.Set("token", accessToken)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"active":false}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "RevokedToken",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+				payload, err := server.tokenMaker.VerifyToken(form.Get("token"))
+				require.NoError(t, err)
+				require.NoError(t, server.revocations.Revoke(context.Background(), payload))
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"active":false}`, recorder.Body.String())
+			},
+		},
+		{
+			name: "WrongSecret",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, "wrong-secret")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.NotEmpty(t, recorder.Header().Get("WWW-Authenticate"))
+			},
+		},
+		{
+			name: "UnknownClient",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NoCredentials",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InsufficientScope",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(other.ID, otherSecret)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(other.ID)).
+					Times(1).
+					Return(other, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "MissingToken",
+			setupRequest: func(t *testing.T, server *Server, request *http.Request, form url.Values) {
+				request.SetBasicAuth(client.ID, secret)
+				form.Del("token")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute,
+				token.WithScopes(scopeAdminUsersRead), token.WithClientID("web-app"))
+			require.NoError(t, err)
+
+			form := url.Values{}
+			form.Set("token", accessToken)
+			request, err := http.NewRequest(http.MethodPost, "/introspect", nil)
+			require.NoError(t, err)
+			tc.setupRequest(t, server, request, form)
+
+			body := form.Encode()
+			request.Body = io.NopCloser(strings.NewReader(body))
+			request.ContentLength = int64(len(body))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
+
+func requireActive(t *testing.T, recorder *httptest.ResponseRecorder, active bool) {
+	var res IntrospectResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+	require.Equal(t, active, res.Active)
+}
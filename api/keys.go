@@ -0,0 +1,58 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+// publishedKeysMaxAge is how long verifiers may cache the key sets, keys are published
+// before they become active so it only has to be shorter than the rotation schedule
+const publishedKeysMaxAge = 5 * time.Minute
+
+func (server *Server) publicKeys(ctx *gin.Context) []token.PublicKey {
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(publishedKeysMaxAge.Seconds())))
+
+	var keys []token.PublicKey
+	// symmetric tokens have nothing to publish
+	for _, maker := range []token.Maker{server.tokenMaker, server.idTokenMaker} {
+		if publisher, ok := maker.(token.KeyPublisher); ok {
+			keys = append(keys, publisher.PublicKeys(time.Now())...)
+		}
+	}
+	return keys
+}
+
+// JWKS serve the public keys verifying our tokens as a RFC 7517 key set
+func (server *Server) JWKS(ctx *gin.Context) {
+	set := token.JWKSet{Keys: []token.JWK{}}
+	for _, key := range server.publicKeys(ctx) {
+		jwk, err := token.NewJWK(key)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errResponse(err))
+			return
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	ctx.JSON(http.StatusOK, set)
+}
+
+// PASERKS serve the v4.public keys as PASERK, kid is the id found in token footers
+func (server *Server) PASERKS(ctx *gin.Context) {
+	keys := []token.PASERK{}
+	for _, key := range server.publicKeys(ctx) {
+		if key.Algorithm != token.PasetoV4Public {
+			continue
+		}
+		paserk, err := token.NewPASERK(key)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errResponse(err))
+			return
+		}
+		keys = append(keys, paserk)
+	}
+	ctx.JSON(http.StatusOK, gin.H{"keys": keys})
+}
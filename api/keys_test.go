@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	"github.com/brkss/go-auth/token"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishedKeys(t *testing.T) {
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	now := time.Now()
+	keyring, err := token.NewKeyring(
+		token.Key{ID: "retired", Material: privateKey.Seed(), ActiveAt: now.Add(-2 * time.Hour), RetireAt: now.Add(-time.Hour)},
+		token.Key{ID: "current", Material: privateKey.Seed(), ActiveAt: now.Add(-time.Hour)},
+	)
+	require.NoError(t, err)
+	publicMaker, err := token.NewPasetoPublicKeyringMaker(keyring)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		url           string
+		maker         token.Maker
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:  "JWKS",
+			url:   "/.well-known/jwks.json",
+			maker: publicMaker,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Cache-Control"), "max-age=")
+
+				var set token.JWKSet
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &set))
+				require.Len(t, set.Keys, 1)
+				require.Equal(t, "current", set.Keys[0].KeyID)
+				require.Equal(t, "OKP", set.Keys[0].KeyType)
+			},
+		},
+		{
+			name:  "PASERK",
+			url:   "/.well-known/paserk.json",
+			maker: publicMaker,
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Header().Get("Cache-Control"), "max-age=")
+
+				var res struct {
+					Keys []token.PASERK `json:"keys"`
+				}
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Len(t, res.Keys, 1)
+				require.Equal(t, "current", res.Keys[0].KeyID)
+				require.Contains(t, res.Keys[0].PASERK, "k4.public.")
+			},
+		},
+		{
+			name: "SymmetricMaker",
+			url:  "/.well-known/jwks.json",
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, `{"keys":[]}`, recorder.Body.String())
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			server := newTestServer(t, mockdb.NewMockStore(ctrl))
+			if tc.maker != nil {
+				server.tokenMaker = tc.maker
+			}
+
+			request, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder)
+		})
+	}
+}
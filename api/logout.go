@@ -0,0 +1,95 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+)
+
+type LogoutRequest struct {
+	// optional, the refresh token of the session to end with the access token
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revoke the access token used for the request and the given refresh token session
+func (server *Server) Logout(ctx *gin.Context) {
+
+	payload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !ok {
+		err := fmt.Errorf("something went wrong checking token payload !")
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+
+	var req LogoutRequest
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil && err != io.EOF {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	if req.RefreshToken != "" {
+		session, err := server.store.GetSessionByRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+		if err != nil && err != sql.ErrNoRows {
+			ctx.JSON(http.StatusInternalServerError, errResponse(err))
+			return
+		}
+		// never let a user end somebody else's session
+		if err == nil && session.UserID == payload.UserId {
+			err = matchFingerprint(ctx, session.FingerprintHash)
+			if err != nil {
+				ctx.JSON(http.StatusUnauthorized, errResponse(err))
+				return
+			}
+			err = server.store.RevokeSessionFamily(ctx, session.FamilyID)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errResponse(err))
+				return
+			}
+		}
+	}
+
+	err = server.revocations.Revoke(ctx, payload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	if server.tokenCache != nil {
+		server.tokenCache.Forget(payload.ID)
+	}
+
+	clearFingerprint(ctx)
+	ctx.JSON(http.StatusOK, gin.H{})
+}
+
+// LogoutAll revoke every access token and session of the user, relying parties included
+func (server *Server) LogoutAll(ctx *gin.Context) {
+
+	payload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !ok {
+		err := fmt.Errorf("something went wrong checking token payload !")
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+
+	// a client has no sessions, and revoking the empty user id would hit every client token
+	if payload.IsClient() {
+		err := fmt.Errorf("client tokens have no sessions to log out !")
+		ctx.JSON(http.StatusForbidden, errResponse(err))
+		return
+	}
+
+	err := server.logoutUser(ctx, payload.UserId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	clearFingerprint(ctx)
+	ctx.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,323 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogout(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	refreshToken := utils.RandomString(43)
+	session := createSession(user.ID, refreshToken)
+	fingerprint := utils.RandomString(43)
+	browserSession := createSession(user.ID, refreshToken)
+	browserSession.FingerprintHash = utils.HashToken(fingerprint)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		setAuth       bool
+		fingerprint   string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder, server *Server, accessToken string)
+	}{
+		{
+			name:    "OK",
+			setAuth: true,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name:    "WithRefreshToken",
+			setAuth: true,
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(session.RefreshTokenHash)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name:        "FingerprintedRefreshToken",
+			setAuth:     true,
+			fingerprint: fingerprint,
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(browserSession.RefreshTokenHash)).
+					Times(1).
+					Return(browserSession, nil)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Eq(browserSession.FamilyID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name:    "StolenRefreshToken",
+			setAuth: true,
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(browserSession.RefreshTokenHash)).
+					Times(1).
+					Return(browserSession, nil)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:    "OtherUserRefreshToken",
+			setAuth: true,
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(createSession(uuid.New().String(), refreshToken), nil)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "UnknownRefreshToken",
+			setAuth: true,
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:    "Unauthorized",
+			setAuth: false,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			var body []byte
+			if tc.body != nil {
+				data, err := json.Marshal(tc.body)
+				require.NoError(t, err)
+				body = data
+			}
+
+			url := "/logout"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+			require.NoError(t, err)
+
+			server := newTestServer(t, store)
+			accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+			require.NoError(t, err)
+			if tc.setAuth {
+				request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			}
+			if tc.fingerprint != "" {
+				request.AddCookie(&http.Cookie{Name: fingerprintCookieName, Value: tc.fingerprint})
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder, server, accessToken)
+		})
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+
+	user, _ := CreateUser(t)
+
+	testCases := []struct {
+		name          string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder, server *Server, accessTokens []string)
+	}{
+		{
+			name: "OK",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessTokens []string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				for _, accessToken := range accessTokens {
+					requireRevoked(t, server, accessToken)
+				}
+
+				// tokens issued after the logout are accepted
+				time.Sleep(time.Millisecond)
+				accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+				require.NoError(t, err)
+				payload, err := server.tokenMaker.VerifyToken(accessToken)
+				require.NoError(t, err)
+				revoked, err := server.revocations.IsRevoked(context.Background(), payload)
+				require.NoError(t, err)
+				require.False(t, revoked)
+			},
+		},
+		{
+			name: "InternalError",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server, accessTokens []string) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			var accessTokens []string
+			for i := 0; i < 2; i++ {
+				accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+				require.NoError(t, err)
+				accessTokens = append(accessTokens, accessToken)
+			}
+
+			url := "/logout/all"
+			request, err := http.NewRequest(http.MethodPost, url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessTokens[0]))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder, server, accessTokens)
+		})
+	}
+}
+
+func requireRevoked(t *testing.T, server *Server, accessToken string) {
+	request, err := http.NewRequest(http.MethodPost, "/logout", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+}
+
+func requireNotRevoked(t *testing.T, server *Server, accessToken string) {
+	payload, err := server.tokenMaker.VerifyToken(accessToken)
+	require.NoError(t, err)
+	revoked, err := server.revocations.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestLogoutTokenCache(t *testing.T) {
+
+	user, _ := CreateUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+		Times(1).
+		Return(nil)
+
+	server := newTestServer(t, store)
+	server.tokenCache = token.NewCachedMaker(server.tokenMaker, 10, time.Minute)
+	server.router.GET("/cached", server.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+	require.NoError(t, err)
+	authorization := fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken)
+
+	request, err := http.NewRequest(http.MethodGet, "/cached", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, authorization)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Equal(t, 1, server.tokenCache.Len())
+
+	request, err = http.NewRequest(http.MethodPost, "/logout/all", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, authorization)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	require.Zero(t, server.tokenCache.Len())
+	requireRevoked(t, server, accessToken)
+}
@@ -0,0 +1,218 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthMiddlewareCaveats(t *testing.T) {
+
+	testCases := []struct {
+		name           string
+		method         string
+		url            string
+		forwardedFor   string
+		trustedProxies []string
+		caveats        func(t *testing.T) []token.Caveat
+		checkResponse  func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			method: http.MethodGet,
+			url:    "/files/reports/q3",
+			caveats: func(t *testing.T) []token.Caveat {
+				return []token.Caveat{token.PathPrefix("/files/reports"), token.ReadOnly()}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "NoCaveat",
+			method: http.MethodPost,
+			url:    "/files/other",
+			caveats: func(t *testing.T) []token.Caveat {
+				return nil
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "WrongPath",
+			method: http.MethodGet,
+			url:    "/files/other",
+			caveats: func(t *testing.T) []token.Caveat {
+				return []token.Caveat{token.PathPrefix("/files/reports")}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "ReadOnly",
+			method: http.MethodPost,
+			url:    "/files/reports/q3",
+			caveats: func(t *testing.T) []token.Caveat {
+				return []token.Caveat{token.PathPrefix("/files/reports"), token.ReadOnly()}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "IPRange",
+			method: http.MethodGet,
+			url:    "/files/reports/q3",
+			caveats: func(t *testing.T) []token.Caveat {
+				caveat, err := token.IPRange("10.0.0.0/8")
+				require.NoError(t, err)
+				return []token.Caveat{caveat}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:         "SpoofedForwardedFor",
+			method:       http.MethodGet,
+			url:          "/files/reports/q3",
+			forwardedFor: "10.1.2.3",
+			caveats: func(t *testing.T) []token.Caveat {
+				caveat, err := token.IPRange("10.0.0.0/8")
+				require.NoError(t, err)
+				return []token.Caveat{caveat}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:           "TrustedProxy",
+			method:         http.MethodGet,
+			url:            "/files/reports/q3",
+			forwardedFor:   "10.1.2.3",
+			trustedProxies: []string{"192.0.2.0/24"},
+			caveats: func(t *testing.T) []token.Caveat {
+				caveat, err := token.IPRange("10.0.0.0/8")
+				require.NoError(t, err)
+				return []token.Caveat{caveat}
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			server := newTestServer(t, nil)
+			if tc.trustedProxies != nil {
+				require.NoError(t, server.router.SetTrustedProxies(tc.trustedProxies))
+			}
+			maker, err := token.NewMacaroonMaker(utils.RandomString(32))
+			require.NoError(t, err)
+			server.tokenMaker = maker
+
+			server.router.Any("/files/*path", server.authMiddleware(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			accessToken, err := maker.CreateToken(uuid.New().String(), time.Minute)
+			require.NoError(t, err)
+			accessToken, err = token.AttenuateMacaroon(accessToken, tc.caveats(t)...)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			request.RemoteAddr = "192.0.2.1:4242"
+			if tc.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestAttenuatedMacaroonWithoutFingerprint(t *testing.T) {
+
+	user, password := CreateUser(t)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	var session db.CreateSessionParams
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		DoAndReturn(func(_ context.Context, arg db.CreateSessionParams) (db.Session, error) {
+			session = arg
+			return db.Session{}, nil
+		})
+
+	server := newTestServer(t, store)
+	maker, err := token.NewMacaroonMaker(utils.RandomString(32))
+	require.NoError(t, err)
+	server.tokenMaker = maker
+
+	server.router.Any("/files/*path", server.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	// the refresh token is still bound to the browser
+	require.Len(t, recorder.Result().Cookies(), 1)
+	require.NotEmpty(t, session.FingerprintHash)
+
+	var response AuthResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+	accessToken, err := token.AttenuateMacaroon(response.AccessToken, token.PathPrefix("/files/reports"), token.ReadOnly())
+	require.NoError(t, err)
+
+	// the third party holding the attenuated macaroon never had the cookie
+	request, err = http.NewRequest(http.MethodGet, "/files/reports/q3", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	request, err = http.NewRequest(http.MethodPost, "/files/reports/q3", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -27,12 +30,22 @@ func newTestServer(t *testing.T, store db.Store)(*Server){
 		DBDriver: DBDriver,
 		TokenSymtricKey: utils.RandomString(32),
 		TokenDuration: time.Minute * 15,
+		RefreshTokenDuration: time.Hour * 24,
+		RevocationBackend: "memory",
 	}
 
-	server := NewServer(store, maker, config)
+	server := NewServer(store, maker, nil, config)
 	return (server)
 }
 
+// newFormRequest build a form encoded POST like oauth clients send
+func newFormRequest(t *testing.T, url string, form url.Values) *http.Request {
+	request, err := http.NewRequest(http.MethodPost, url, strings.NewReader(form.Encode()))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return request
+}
+
 func TestMain(m *testing.M) {
 
 	gin.SetMode(gin.TestMode)
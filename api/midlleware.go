@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strings"
 
@@ -15,39 +16,103 @@ const (
 	authorizationPayloadKey = "payload"
 )
 
-// authMiddleware check authorization header get token and check its validity 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc{
+// authMiddleware check authorization header get token and check its validity,
+// opts are added to the server checks so a route group can expect its own audience
+func (server *Server) authMiddleware(opts ...token.VerifyOption) gin.HandlerFunc{
+	opts = append(server.verifyOptions(), opts...)
 	return func(ctx *gin.Context){
-		// get authorization header from request 
-		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
-		if len(authorizationHeader) == 0 {
-			err := fmt.Errorf("authorization header not found !")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errResponse(err))
+		payload, status, err := server.authenticate(ctx, opts)
+		if err != nil {
+			ctx.AbortWithStatusJSON(status, errResponse(err))
 			return
 		}
+		// set payload in request context ! 
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
 
-		// check authorization header fields 
-		fields := strings.Split(authorizationHeader, " ")
-		if len(fields) < 2 {
-			err := fmt.Errorf("invalid authorization header !")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errResponse(err))
-		}
+// authenticate check the access token of the request, status goes with the error
+func (server *Server) authenticate(ctx *gin.Context, opts []token.VerifyOption) (*token.Payload, int, error) {
+	// get authorization header from request 
+	authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
+	if len(authorizationHeader) == 0 {
+		err := fmt.Errorf("authorization header not found !")
+		return nil, http.StatusUnauthorized, err
+	}
 
-		// check token type from authorization header 
-		if strings.ToLower(fields[0]) != authorizationTypeBearer {
-			err := fmt.Errorf("invalid token type !")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errResponse(err))
-			return 
-		}
+	// check authorization header fields 
+	fields := strings.Split(authorizationHeader, " ")
+	if len(fields) < 2 {
+		err := fmt.Errorf("invalid authorization header !")
+		return nil, http.StatusUnauthorized, err
+	}
+
+	// check token type from authorization header 
+	authorizationType := strings.ToLower(fields[0])
+	if authorizationType != authorizationTypeBearer && authorizationType != authorizationTypeDPoP {
+		err := fmt.Errorf("invalid token type !")
+		return nil, http.StatusUnauthorized, err
+	}
+
+	accessToken := fields[1]
+	payload, err := server.verifier().VerifyToken(accessToken, opts...)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	// the principal is either a user or a client, a token for nobody is a bug
+	if payload.UserId == "" && !payload.IsClient() {
+		return nil, http.StatusUnauthorized, token.ErrInvalidToken
+	}
 
-		token := fields[1]
-		payload, err := tokenMaker.VerifyToken(token)
+	// DPoP bound tokens are useless without a proof of the bound key
+	if payload.DPoPKey() != "" || authorizationType == authorizationTypeDPoP {
+		if authorizationType != authorizationTypeDPoP || payload.DPoPKey() == "" {
+			err := fmt.Errorf("DPoP bound tokens must use the DPoP authorization scheme !")
+			return nil, http.StatusUnauthorized, err
+		}
+		proof, err := server.verifyDPoP(ctx, accessToken)
+		if err == nil && proof.JKT != payload.DPoPKey() {
+			err = token.ErrInvalidDPoPProof
+		}
 		if err != nil {
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errResponse(err))
-			return
+			ctx.Header("WWW-Authenticate", `DPoP error="invalid_dpop_proof"`)
+			return nil, http.StatusUnauthorized, err
 		}
-		// set payload in request context ! 
-		ctx.Set(authorizationPayloadKey, payload)
-		ctx.Next()
 	}
+
+	// certificate bound tokens only work over a connection presenting that certificate
+	if thumbprint := payload.CertificateKey(); thumbprint != "" {
+		cert := clientCertificate(ctx)
+		if cert == nil || token.CertificateThumbprint(cert) != thumbprint {
+			return nil, http.StatusUnauthorized, errCertificateMismatch
+		}
+	}
+
+	// browser tokens are only accepted with the fingerprint cookie set at login
+	err = checkFingerprint(ctx, payload)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+
+	// attenuated macaroons only work for the requests their caveats allow
+	err = payload.CheckCaveats(token.CaveatRequest{
+		Method: ctx.Request.Method,
+		Path:   ctx.Request.URL.Path,
+		IP:     net.ParseIP(ctx.ClientIP()),
+	})
+	if err != nil {
+		return nil, http.StatusForbidden, err
+	}
+
+	// check the token wasn't revoked by a logout
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if revoked {
+		return nil, http.StatusUnauthorized, token.ErrRevokedToken
+	}
+	return payload, http.StatusOK, nil
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -93,7 +94,7 @@ func TestAuthMiddleware(t *testing.T){
 			url := "/auth"
 			
 			server := newTestServer(t, nil)
-			server.router.GET(url, authMiddleware(server.tokenMaker), func(ctx *gin.Context){
+			server.router.GET(url, server.authMiddleware(), func(ctx *gin.Context){
 				ctx.JSON(http.StatusOK, gin.H{})
 			});
 			
@@ -111,4 +112,95 @@ func TestAuthMiddleware(t *testing.T){
 
 }
 
+func TestAuthMiddlewareRevokedToken(t *testing.T) {
 
+	url := "/auth"
+	server := newTestServer(t, nil)
+	server.router.GET(url, server.authMiddleware(), func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{})
+	})
+
+	userId := uuid.New().String()
+	revoked, err := server.tokenMaker.CreateToken(userId, time.Minute)
+	require.NoError(t, err)
+	payload, err := server.tokenMaker.VerifyToken(revoked)
+	require.NoError(t, err)
+	require.NoError(t, server.revocations.Revoke(context.Background(), payload))
+
+	otherUser, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	for token, code := range map[string]int{revoked: http.StatusUnauthorized, otherUser: http.StatusOK} {
+		request, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, token))
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, code, recorder.Code)
+	}
+}
+
+func TestAuthMiddlewareAudience(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		opts          []token.PayloadOption
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			opts: []token.PayloadOption{token.WithIssuer("go-auth"), token.WithAudience("billing")},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "OtherAudience",
+			opts: []token.PayloadOption{token.WithIssuer("go-auth"), token.WithAudience("orders")},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), token.ErrInvalidAudience.Error())
+			},
+		},
+		{
+			name: "OtherIssuer",
+			opts: []token.PayloadOption{token.WithIssuer("someone"), token.WithAudience("billing")},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), token.ErrInvalidIssuer.Error())
+			},
+		},
+		{
+			name: "NotYetValid",
+			opts: []token.PayloadOption{token.WithIssuer("go-auth"), token.WithAudience("billing"), token.WithNotBefore(time.Now().Add(time.Minute))},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), token.ErrTokenNotYetValid.Error())
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url := "/billing"
+
+			server := newTestServer(t, nil)
+			server.config.TokenIssuer = "go-auth"
+			server.router.GET(url, server.authMiddleware(token.ExpectAudience("billing")), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, tc.opts...)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
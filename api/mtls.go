@@ -0,0 +1,59 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var errCertificateMismatch = fmt.Errorf("token is bound to another client certificate !")
+
+// clientCertificate returns the verified TLS client certificate of the request, nil without one
+func clientCertificate(ctx *gin.Context) *x509.Certificate {
+	if ctx.Request.TLS == nil || len(ctx.Request.TLS.VerifiedChains) == 0 {
+		return nil
+	}
+	return ctx.Request.TLS.VerifiedChains[0][0]
+}
+
+// tokenBinding returns the options binding a new token to the request DPoP key
+// or TLS client certificate, with the token type to report to the client
+func (server *Server) tokenBinding(ctx *gin.Context) ([]token.PayloadOption, string) {
+	var opts []token.PayloadOption
+	tokenType := "Bearer"
+	if jkt := ctx.GetString(dpopKeyKey); jkt != "" {
+		opts = append(opts, token.WithDPoPKey(jkt))
+		tokenType = "DPoP"
+	}
+	// certificate bound tokens are still sent as bearer tokens (RFC 8705 section 3)
+	if cert := clientCertificate(ctx); cert != nil {
+		opts = append(opts, token.WithCertificate(token.CertificateThumbprint(cert)))
+	}
+	return opts, tokenType
+}
+
+// newTLSConfig asks clients for a certificate signed by TLSClientCAFile without requiring one,
+// browsers keep working and machine clients get certificate bound tokens
+func newTLSConfig(config *utils.Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if config.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	data, err := os.ReadFile(config.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificate found in %s", config.TLSClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a self signed certificate usable as CA or client certificate
+func newTestCertificate(t *testing.T, name string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// withClientCertificate make the request look like it came over mTLS with cert
+func withClientCertificate(request *http.Request, cert *x509.Certificate) {
+	request.TLS = &tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{cert},
+		VerifiedChains:   [][]*x509.Certificate{{cert}},
+	}
+}
+
+func TestAuthMiddlewareCertificateBound(t *testing.T) {
+
+	cert := newTestCertificate(t, "billing-job")
+	otherCert := newTestCertificate(t, "other-job")
+	thumbprint := token.CertificateThumbprint(cert)
+
+	testCases := []struct {
+		name          string
+		opts          []token.PayloadOption
+		cert          *x509.Certificate
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			opts: []token.PayloadOption{token.WithCertificate(thumbprint)},
+			cert: cert,
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "NoCertificate",
+			opts: []token.PayloadOption{token.WithCertificate(thumbprint)},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errCertificateMismatch.Error())
+			},
+		},
+		{
+			name: "OtherCertificate",
+			opts: []token.PayloadOption{token.WithCertificate(thumbprint)},
+			cert: otherCert,
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "UnboundToken",
+			cert: otherCert,
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTestServer(t, nil)
+			server.router.GET("/auth", server.authMiddleware(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, tc.opts...)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodGet, "/auth", nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+			if tc.cert != nil {
+				withClientCertificate(request, tc.cert)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestLoginCertificateBound(t *testing.T) {
+
+	user, password := CreateUser(t)
+	cert := newTestCertificate(t, "kiosk")
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+		Times(1).
+		Return(user, nil)
+	store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+		Times(1).
+		Return(db.Session{}, nil)
+
+	data, err := json.Marshal(gin.H{"username": user.Username, "password": password})
+	require.NoError(t, err)
+	request, err := http.NewRequest(http.MethodPost, "/login", bytes.NewReader(data))
+	require.NoError(t, err)
+	withClientCertificate(request, cert)
+
+	server := newTestServer(t, store)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	payload := checkBodyMatch(t, recorder.Body, server, user.ID)
+	require.Equal(t, token.CertificateThumbprint(cert), payload.CertificateKey())
+}
+
+func TestNewTLSConfig(t *testing.T) {
+
+	server := newTestServer(t, nil)
+
+	tlsConfig, err := newTLSConfig(server.config)
+	require.NoError(t, err)
+	require.Equal(t, tls.NoClientCert, tlsConfig.ClientAuth)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := newTestCertificate(t, "clients-ca")
+	err = os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600)
+	require.NoError(t, err)
+
+	server.config.TLSClientCAFile = caFile
+	tlsConfig, err = newTLSConfig(server.config)
+	require.NoError(t, err)
+	require.Equal(t, tls.VerifyClientCertIfGiven, tlsConfig.ClientAuth)
+	require.NotNil(t, tlsConfig.ClientCAs)
+
+	server.config.TLSClientCAFile = filepath.Join(t.TempDir(), "missing.pem")
+	_, err = newTLSConfig(server.config)
+	require.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+// RFC 6749 section 5.2 error codes
+const (
+	oauthInvalidRequest       = "invalid_request"
+	oauthInvalidClient        = "invalid_client"
+	oauthInvalidGrant         = "invalid_grant"
+	oauthUnsupportedGrantType = "unsupported_grant_type"
+	oauthInvalidScope         = "invalid_scope"
+	oauthInvalidTarget        = "invalid_target"
+	oauthServerError          = "server_error"
+	// RFC 6749 section 4.1.2.1 authorization errors
+	oauthUnauthorizedClient      = "unauthorized_client"
+	oauthUnsupportedResponseType = "unsupported_response_type"
+)
+
+const (
+	grantTypeAuthorizationCode = "authorization_code"
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// oauthError is the error body of the oauth endpoints, they can't use errResponse
+// since clients expect the standard error codes
+func oauthError(code string, description string) gin.H {
+	return gin.H{
+		"error":             code,
+		"error_description": description,
+	}
+}
+
+// TokenResponse is the RFC 6749 section 5.1 successful token response
+type TokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scope           string `json:"scope,omitempty"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	// IDToken is the OpenID Connect id token of authorization codes with the openid scope
+	IDToken string `json:"id_token,omitempty"`
+}
+
+// Token is the oauth token endpoint, every grant authenticates the client first,
+// public clients can only redeem authorization codes
+func (server *Server) Token(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	grantType := ctx.PostForm("grant_type")
+	client, err := server.authenticateClient(ctx, grantType == grantTypeAuthorizationCode || grantType == grantTypeDeviceCode)
+	if err == errInvalidClient {
+		ctx.Header("WWW-Authenticate", `Basic realm="token"`)
+		ctx.JSON(http.StatusUnauthorized, oauthError(oauthInvalidClient, err.Error()))
+		return
+	}
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	if grantType != "" && !clientAllowsGrant(client, grantType) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthUnauthorizedClient, "the client isn't registered for the grant type "+grantType))
+		return
+	}
+
+	switch grantType {
+	case grantTypeAuthorizationCode:
+		server.redeemAuthorizationCode(ctx, client)
+	case grantTypeDeviceCode:
+		server.redeemDeviceCode(ctx, client)
+	case grantTypeClientCredentials:
+		server.clientCredentials(ctx, client)
+	case grantTypeTokenExchange:
+		server.exchangeToken(ctx, client)
+	case "":
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "grant_type is required"))
+	default:
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthUnsupportedGrantType, "unsupported grant type "+grantType))
+	}
+}
+
+// issueToken answers a grant with an access token of userId for client, bound to the
+// DPoP key or certificate of the request when there's one
+func (server *Server) issueToken(ctx *gin.Context, client db.Client, userId string, scopes []string, opts ...token.PayloadOption) {
+	response, err := server.newTokenResponse(ctx, client, userId, scopes, opts...)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	ctx.JSON(http.StatusOK, response)
+}
+
+// newTokenResponse creates the access token of issueToken
+func (server *Server) newTokenResponse(ctx *gin.Context, client db.Client, userId string, scopes []string, opts ...token.PayloadOption) (TokenResponse, error) {
+	opts = append(append(server.tokenOptions(), opts...),
+		token.WithScopes(scopes...),
+		token.WithClientID(client.ID),
+	)
+	binding, tokenType := server.tokenBinding(ctx)
+	opts = append(opts, binding...)
+
+	accessToken, err := server.tokenMaker.CreateToken(userId, server.config.TokenDuration, opts...)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenType,
+		ExpiresIn:   int64(server.config.TokenDuration.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
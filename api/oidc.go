@@ -0,0 +1,158 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+// OpenID Connect scopes, profile and email select the claims of UserInfo
+const (
+	scopeOpenID  = "openid"
+	scopeProfile = "profile"
+	scopeEmail   = "email"
+)
+
+const (
+	// RFC 8176 authentication method, users can only log in with their password
+	amrPassword = "pwd"
+	// ISO/IEC 29115 level 1, a single factor
+	acrPassword = "1"
+)
+
+// oidcScopes only give access to the user's own claims, any user can grant them
+var oidcScopes = []string{scopeOpenID, scopeProfile, scopeEmail}
+
+// id token claims of OpenID Connect Core section 2
+var (
+	nonceClaim    = token.NewClaim[string]("nonce")
+	authTimeClaim = token.NewClaim[int64]("auth_time")
+	amrClaim      = token.NewClaim[[]string]("amr")
+	acrClaim      = token.NewClaim[string]("acr")
+)
+
+var errOIDCDisabled = fmt.Errorf("OpenID Connect is not configured !")
+
+// newIDToken sign the id token of an authorization code, the user authenticated
+// when the code was created
+func (server *Server) newIDToken(client db.Client, code db.AuthorizationCode) (string, error) {
+	opts := []token.PayloadOption{
+		token.WithIssuer(server.config.OIDCIssuer),
+		token.WithAudience(client.ID),
+		authTimeClaim.With(code.CreatedAt.Unix()),
+		amrClaim.With([]string{amrPassword}),
+		acrClaim.With(acrPassword),
+	}
+	if code.Nonce != "" {
+		opts = append(opts, nonceClaim.With(code.Nonce))
+	}
+	return server.idTokenMaker.CreateToken(code.UserID, server.config.TokenDuration, opts...)
+}
+
+// UserInfoResponse holds the OpenID Connect standard claims allowed by the token scopes
+type UserInfoResponse struct {
+	Subject           string `json:"sub"`
+	Name              string `json:"name,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Email             string `json:"email,omitempty"`
+}
+
+// UserInfo is the OpenID Connect userinfo endpoint, Me filtered by the token scopes
+func (server *Server) UserInfo(ctx *gin.Context) {
+	payload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	if !ok {
+		err := fmt.Errorf("something went wrong checking token payload !")
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+	if payload.IsClient() {
+		err := fmt.Errorf("client tokens have no user info !")
+		ctx.JSON(http.StatusForbidden, errResponse(err))
+		return
+	}
+
+	user, ok := server.currentUser(ctx, payload)
+	if !ok {
+		return
+	}
+
+	me := CreateUserResponse(user)
+	info := UserInfoResponse{Subject: user.ID}
+	if payload.HasScopes(scopeProfile) {
+		info.Name = me.Name
+		info.PreferredUsername = me.Username
+	}
+	if payload.HasScopes(scopeEmail) {
+		info.Email = me.Email
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(http.StatusOK, info)
+}
+
+// OpenIDConfigurationResponse is the OpenID Connect Discovery provider metadata
+type OpenIDConfigurationResponse struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint"`
+	RegistrationEndpoint              string   `json:"registration_endpoint"`
+	EndSessionEndpoint                string   `json:"end_session_endpoint"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+	BackchannelLogoutSupported        bool     `json:"backchannel_logout_supported"`
+	BackchannelLogoutSessionSupported bool     `json:"backchannel_logout_session_supported"`
+}
+
+// OpenIDConfiguration serve the discovery document, every url is under the configured issuer
+func (server *Server) OpenIDConfiguration(ctx *gin.Context) {
+	publisher, ok := server.idTokenMaker.(token.KeyPublisher)
+	if !ok {
+		ctx.JSON(http.StatusNotFound, errResponse(errOIDCDisabled))
+		return
+	}
+	algorithms := []string{}
+	for _, key := range publisher.PublicKeys(time.Now()) {
+		if !hasScope(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+
+	issuer := strings.TrimSuffix(server.config.OIDCIssuer, "/")
+	ctx.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(publishedKeysMaxAge.Seconds())))
+	ctx.JSON(http.StatusOK, OpenIDConfigurationResponse{
+		Issuer:                            server.config.OIDCIssuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		DeviceAuthorizationEndpoint:       issuer + "/device/code",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		RegistrationEndpoint:              issuer + "/register-client",
+		EndSessionEndpoint:                issuer + "/end-session",
+		ScopesSupported:                   oidcScopes,
+		ResponseTypesSupported:            []string{responseTypeCode},
+		GrantTypesSupported:               []string{grantTypeAuthorizationCode, grantTypeDeviceCode, grantTypeClientCredentials, grantTypeTokenExchange},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{codeChallengeMethodS256},
+		ClaimsSupported:                   []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "amr", "acr", "name", "preferred_username", "email"},
+		// logout tokens have no sid, the user is logged out of every session
+		BackchannelLogoutSupported:        true,
+		BackchannelLogoutSessionSupported: false,
+	})
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	backchannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+	// logout tokens are signed for each attempt so they only have to live for the request
+	logoutTokenDuration    = 2 * time.Minute
+	logoutDeliveryInterval = 10 * time.Second
+	logoutDeliveryBatch    = 50
+	// a claimed delivery isn't picked up again before its request timed out
+	logoutDeliveryTimeout = 10 * time.Second
+	logoutDeliveryLease   = 2 * logoutDeliveryTimeout
+	// retried after 30s, 1m, 2m and 4m then given up
+	maxLogoutAttempts  = 5
+	logoutRetryBackoff = 30 * time.Second
+)
+
+// eventsClaim is the RFC 8417 events claim of logout tokens
+var eventsClaim = token.NewClaim[map[string]struct{}]("events")
+
+// newLogoutClient never follows redirects, a relying party must answer the logout itself
+func newLogoutClient() *http.Client {
+	return &http.Client{
+		Timeout: logoutDeliveryTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// logoutUser revoke every access token and session of the user, the relying parties
+// they signed in to are told with back-channel logout tokens
+func (server *Server) logoutUser(ctx *gin.Context, userId string) error {
+	// access tokens issued until now are all expired once TokenDuration has passed
+	now := time.Now()
+	err := server.revocations.RevokeUser(ctx, userId, now, now.Add(server.config.TokenDuration))
+	if err != nil {
+		return err
+	}
+	if server.tokenCache != nil {
+		server.tokenCache.ForgetUser(userId)
+	}
+
+	err = server.store.RevokeUserSessions(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if server.idTokenMaker == nil {
+		return nil
+	}
+	return server.store.QueueLogoutDeliveries(ctx, userId)
+}
+
+type EndSessionRequest struct {
+	IDTokenHint           string `form:"id_token_hint"`
+	ClientID              string `form:"client_id"`
+	PostLogoutRedirectURI string `form:"post_logout_redirect_uri"`
+	State                 string `form:"state"`
+}
+
+// EndSessionResponse is returned until the logout is confirmed, the logout page asks the user
+// and sends the same parameters again with their access token
+type EndSessionResponse struct {
+	ClientID              string `json:"client_id"`
+	PostLogoutRedirectURI string `json:"post_logout_redirect_uri,omitempty"`
+	State                 string `json:"state,omitempty"`
+}
+
+// EndSession is the OpenID Connect RP-Initiated Logout endpoint, the id token hint tells
+// which user to log out and which client may get them back
+func (server *Server) EndSession(ctx *gin.Context) {
+	if server.idTokenMaker == nil {
+		ctx.JSON(http.StatusNotFound, errResponse(errOIDCDisabled))
+		return
+	}
+
+	var req EndSessionRequest
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, err.Error()))
+		return
+	}
+	if req.IDTokenHint == "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "id_token_hint is required"))
+		return
+	}
+	// id tokens are short lived and RPs keep them, the hint only tells who to log out and the
+	// logout is confirmed by the access token below so expired hints are fine
+	hint, err := server.idTokenMaker.VerifyToken(req.IDTokenHint, token.ExpectIssuer(server.config.OIDCIssuer), token.IgnoreExpiration())
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "invalid id_token_hint"))
+		return
+	}
+	// id tokens are issued to a single client, client_id can only confirm it
+	if len(hint.Audience) != 1 || (req.ClientID != "" && req.ClientID != hint.Audience[0]) {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "client_id doesn't match the id_token_hint"))
+		return
+	}
+
+	// errors are shown to the user, only registered uris get redirected to
+	if req.PostLogoutRedirectURI != "" {
+		client, err := server.store.GetClient(ctx, hint.Audience[0])
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidClient, "unknown client"))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+			return
+		}
+		if !hasScope(client.PostLogoutRedirectUris, req.PostLogoutRedirectURI) {
+			ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidRequest, "post_logout_redirect_uri is not registered for this client"))
+			return
+		}
+	}
+
+	// id tokens end up in logs and with every relying party, a hint alone can't log the user out.
+	// The access token of the user confirms it, its fingerprint cookie included
+	payload, _, err := server.authenticate(ctx, server.verifyOptions())
+	if err != nil || payload.UserId != hint.Subject {
+		ctx.JSON(http.StatusOK, EndSessionResponse{
+			ClientID:              hint.Audience[0],
+			PostLogoutRedirectURI: req.PostLogoutRedirectURI,
+			State:                 req.State,
+		})
+		return
+	}
+
+	err = server.logoutUser(ctx, hint.Subject)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	clearFingerprint(ctx)
+
+	if req.PostLogoutRedirectURI == "" {
+		ctx.JSON(http.StatusOK, gin.H{})
+		return
+	}
+	authorizeRedirect(ctx, req.PostLogoutRedirectURI, req.State, nil)
+}
+
+// runLogoutDeliveries call deliverLogouts every interval until ctx is done
+func (server *Server) runLogoutDeliveries(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := server.deliverLogouts(ctx)
+			if err != nil {
+				log.Println("cannot deliver logout tokens :", err)
+			}
+		}
+	}
+}
+
+// deliverLogouts post the logout tokens of the due deliveries, failed deliveries are
+// retried with an exponential backoff until maxLogoutAttempts
+func (server *Server) deliverLogouts(ctx context.Context) error {
+	deliveries, err := server.store.ClaimLogoutDeliveries(ctx, db.ClaimLogoutDeliveriesParams{
+		LeaseUntil:    time.Now().Add(logoutDeliveryLease),
+		MaxDeliveries: logoutDeliveryBatch,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		err := server.deliverLogout(ctx, delivery)
+		if err != nil && delivery.Attempts < maxLogoutAttempts {
+			err = server.store.RetryLogoutDelivery(ctx, db.RetryLogoutDeliveryParams{
+				ID:            delivery.ID,
+				NextAttemptAt: time.Now().Add(logoutRetryBackoff << (delivery.Attempts - 1)),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			log.Printf("giving up back-channel logout of client %s : %v", delivery.ClientID, err)
+		}
+		err = server.store.DeleteLogoutDelivery(ctx, delivery.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverLogout post a logout token to the back-channel uri of the client
+func (server *Server) deliverLogout(ctx context.Context, delivery db.LogoutDelivery) error {
+	client, err := server.store.GetClient(ctx, delivery.ClientID)
+	// deleted clients or clients that stopped listening since the user logged in
+	if err == sql.ErrNoRows || (err == nil && client.BackchannelLogoutUri == "") {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	logoutToken, err := server.idTokenMaker.CreateToken(delivery.UserID, logoutTokenDuration,
+		token.WithIssuer(server.config.OIDCIssuer),
+		token.WithAudience(client.ID),
+		eventsClaim.With(map[string]struct{}{backchannelLogoutEvent: {}}),
+	)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{"logout_token": {logoutToken}}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, client.BackchannelLogoutUri, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := server.logoutClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("back-channel logout answered %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,489 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testPostLogoutRedirectURI = "https://client.example.com/logged-out"
+
+func newIDTokenHint(t *testing.T, server *Server, userId string, clientId string, duration time.Duration) string {
+	hint, err := server.idTokenMaker.CreateToken(userId, duration,
+		token.WithIssuer(testOIDCIssuer),
+		token.WithAudience(clientId),
+	)
+	require.NoError(t, err)
+	return hint
+}
+
+func addBearer(t *testing.T, server *Server, request *http.Request, accessToken string) {
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+}
+
+func TestEndSession(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client := createPublicClient(t)
+	client.PostLogoutRedirectUris = []string{testPostLogoutRedirectURI}
+
+	testCases := []struct {
+		name          string
+		oidc          bool
+		buildQuery    func(t *testing.T, server *Server, query url.Values)
+		setupAuth     func(t *testing.T, server *Server, request *http.Request, accessToken string)
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string)
+	}{
+		{
+			name: "OK",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+				query.Set("client_id", client.ID)
+				query.Set("post_logout_redirect_uri", testPostLogoutRedirectURI)
+				query.Set("state", "af0ifjsldkj")
+			},
+			setupAuth: addBearer,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().QueueLogoutDeliveries(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusFound, recorder.Code)
+				location, err := url.Parse(recorder.Header().Get("Location"))
+				require.NoError(t, err)
+				require.Equal(t, testPostLogoutRedirectURI, location.Scheme+"://"+location.Host+location.Path)
+				require.Equal(t, "af0ifjsldkj", location.Query().Get("state"))
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "WithoutRedirect",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+			},
+			setupAuth: addBearer,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().QueueLogoutDeliveries(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "Unconfirmed",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+				query.Set("post_logout_redirect_uri", testPostLogoutRedirectURI)
+				query.Set("state", "af0ifjsldkj")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Empty(t, recorder.Header().Get("Location"))
+
+				var res EndSessionResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, EndSessionResponse{
+					ClientID:              client.ID,
+					PostLogoutRedirectURI: testPostLogoutRedirectURI,
+					State:                 "af0ifjsldkj",
+				}, res)
+				requireNotRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "OtherUserAccessToken",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+			},
+			setupAuth: func(t *testing.T, server *Server, request *http.Request, accessToken string) {
+				other, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute)
+				require.NoError(t, err)
+				addBearer(t, server, request, other)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), client.ID)
+				requireNotRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "MissingFingerprint",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+			},
+			setupAuth: func(t *testing.T, server *Server, request *http.Request, accessToken string) {
+				fingerprinted, err := server.tokenMaker.CreateToken(user.ID, time.Minute, token.WithFingerprint(utils.HashToken("fingerprint")))
+				require.NoError(t, err)
+				addBearer(t, server, request, fingerprinted)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireNotRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "ExpiredHint",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				// issued at login, RPs send it back long after TokenDuration
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, -server.config.TokenDuration))
+			},
+			setupAuth: addBearer,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().QueueLogoutDeliveries(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				requireRevoked(t, server, accessToken)
+			},
+		},
+		{
+			name: "ForgedHint",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				other := newOIDCTestServer(t, nil)
+				query.Set("id_token_hint", newIDTokenHint(t, other, user.ID, client.ID, -server.config.TokenDuration))
+			},
+			setupAuth: addBearer,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "MissingHint",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("client_id", client.ID)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "AccessTokenAsHint",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				hint, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+				require.NoError(t, err)
+				query.Set("id_token_hint", hint)
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "ClientMismatch",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+				query.Set("client_id", "another-client")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "UnregisteredRedirect",
+			oidc: true,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", newIDTokenHint(t, server, user.ID, client.ID, time.Minute))
+				query.Set("post_logout_redirect_uri", "https://evil.example.com/logged-out")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Empty(t, recorder.Header().Get("Location"))
+			},
+		},
+		{
+			name: "OpenIDDisabled",
+			oidc: false,
+			buildQuery: func(t *testing.T, server *Server, query url.Values) {
+				query.Set("id_token_hint", "hint")
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(t *testing.T, recorder *httptest.ResponseRecorder, server *Server, accessToken string) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			if tc.oidc {
+				server = newOIDCTestServer(t, store)
+			}
+			accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+			require.NoError(t, err)
+
+			query := url.Values{}
+			tc.buildQuery(t, server, query)
+			request, err := http.NewRequest(http.MethodGet, "/end-session?"+query.Encode(), nil)
+			require.NoError(t, err)
+			if tc.setupAuth != nil {
+				tc.setupAuth(t, server, request, accessToken)
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(t, recorder, server, accessToken)
+		})
+	}
+}
+
+func TestLogoutAllBackchannel(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	user, _ := CreateUser(t)
+	store := mockdb.NewMockStore(ctrl)
+	store.EXPECT().RevokeUserSessions(gomock.Any(), gomock.Eq(user.ID)).
+		Times(1).
+		Return(nil)
+	// the clients the user signed in to are told too
+	store.EXPECT().QueueLogoutDeliveries(gomock.Any(), gomock.Eq(user.ID)).
+		Times(1).
+		Return(nil)
+
+	server := newOIDCTestServer(t, store)
+	accessToken, err := server.tokenMaker.CreateToken(user.ID, time.Minute)
+	require.NoError(t, err)
+
+	request, err := http.NewRequest(http.MethodPost, "/logout/all", nil)
+	require.NoError(t, err)
+	request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestDeliverLogouts(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client := createPublicClient(t)
+
+	testCases := []struct {
+		name       string
+		attempts   int32
+		status     int
+		delivered  int
+		buildStabs func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery)
+	}{
+		{
+			name:      "OK",
+			delivered: 1,
+			attempts:  1,
+			status:    http.StatusOK,
+			buildStabs: func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().DeleteLogoutDelivery(gomock.Any(), gomock.Eq(delivery.ID)).
+					Times(1).
+					Return(nil)
+			},
+		},
+		{
+			name:      "Failed",
+			delivered: 1,
+			attempts:  2,
+			status:    http.StatusInternalServerError,
+			buildStabs: func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RetryLogoutDelivery(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RetryLogoutDeliveryParams) error {
+						require.Equal(t, delivery.ID, arg.ID)
+						require.WithinDuration(t, time.Now().Add(2*logoutRetryBackoff), arg.NextAttemptAt, time.Second)
+						return nil
+					})
+				store.EXPECT().DeleteLogoutDelivery(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+		},
+		{
+			name:      "Redirected",
+			delivered: 1,
+			attempts:  1,
+			status:    http.StatusFound,
+			buildStabs: func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RetryLogoutDelivery(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(nil)
+			},
+		},
+		{
+			name:      "GivenUp",
+			delivered: 1,
+			attempts:  maxLogoutAttempts,
+			status:    http.StatusInternalServerError,
+			buildStabs: func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().RetryLogoutDelivery(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().DeleteLogoutDelivery(gomock.Any(), gomock.Eq(delivery.ID)).
+					Times(1).
+					Return(nil)
+			},
+		},
+		{
+			name:     "DeletedClient",
+			attempts: 1,
+			status:   http.StatusOK,
+			buildStabs: func(store *mockdb.MockStore, client db.Client, delivery db.LogoutDelivery) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+				store.EXPECT().DeleteLogoutDelivery(gomock.Any(), gomock.Eq(delivery.ID)).
+					Times(1).
+					Return(nil)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			server := newOIDCTestServer(t, store)
+
+			delivered := 0
+			relyingParty := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				delivered++
+				require.Equal(t, http.MethodPost, r.Method)
+
+				payload, err := server.idTokenMaker.VerifyToken(r.PostFormValue("logout_token"), token.ExpectIssuer(testOIDCIssuer), token.ExpectAudience(client.ID))
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.Subject)
+				events, err := eventsClaim.Get(payload)
+				require.NoError(t, err)
+				require.Contains(t, events, backchannelLogoutEvent)
+				_, err = nonceClaim.Get(payload)
+				require.ErrorIs(t, err, token.ErrMissingClaim)
+
+				if tc.status == http.StatusFound {
+					http.Redirect(w, r, "/elsewhere", tc.status)
+					return
+				}
+				w.WriteHeader(tc.status)
+			}))
+			defer relyingParty.Close()
+
+			client := client
+			client.BackchannelLogoutUri = relyingParty.URL
+			delivery := db.LogoutDelivery{
+				ID:       1,
+				ClientID: client.ID,
+				UserID:   user.ID,
+				Attempts: tc.attempts,
+			}
+			store.EXPECT().ClaimLogoutDeliveries(gomock.Any(), gomock.Any()).
+				Times(1).
+				Return([]db.LogoutDelivery{delivery}, nil)
+			tc.buildStabs(store, client, delivery)
+
+			require.NoError(t, server.deliverLogouts(context.Background()))
+			require.Equal(t, tc.delivered, delivered)
+		})
+	}
+}
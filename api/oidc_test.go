@@ -0,0 +1,416 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testOIDCIssuer = "https://auth.example.com"
+
+// newOIDCTestServer is newTestServer with id tokens signed by a ES256 key
+func newOIDCTestServer(t *testing.T, store db.Store) *Server {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	idTokenMaker, err := token.NewJWTMaker("ES256", key)
+	require.NoError(t, err)
+
+	server := newTestServer(t, store)
+	server.idTokenMaker = idTokenMaker
+	server.config.OIDCIssuer = testOIDCIssuer
+	return server
+}
+
+func TestAuthorizeLoginOpenID(t *testing.T) {
+
+	user, password := CreateUser(t)
+	client := createPublicClient(t)
+	_, challenge := newPKCE(t)
+
+	testCases := []struct {
+		name          string
+		oidc          bool
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			oidc: true,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.AuthorizationCode{}, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusSeeOther)
+				require.NotEmpty(t, query.Get("code"))
+			},
+		},
+		{
+			name: "OpenIDDisabled",
+			oidc: false,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+				store.EXPECT().CreateAuthorizationCode(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				query := requireRedirect(t, recorder, http.StatusSeeOther)
+				require.Equal(t, oauthInvalidScope, query.Get("error"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+				Times(1).
+				Return(client, nil)
+			tc.buildStabs(store)
+
+			form := url.Values{
+				"response_type":         {responseTypeCode},
+				"client_id":             {client.ID},
+				"redirect_uri":          {testRedirectURI},
+				"scope":                 {"openid profile email"},
+				"code_challenge":        {challenge},
+				"code_challenge_method": {codeChallengeMethodS256},
+				"username":              {user.Username},
+				"password":              {password},
+			}
+
+			server := newTestServer(t, store)
+			if tc.oidc {
+				server = newOIDCTestServer(t, store)
+			}
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, newFormRequest(t, "/authorize", form))
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestTokenIDToken(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	client := createPublicClient(t)
+	verifier, challenge := newPKCE(t)
+	code := "authorization-code"
+	authTime := time.Now().Add(-30 * time.Second).Truncate(time.Second)
+
+	testCases := []struct {
+		name          string
+		scopes        []string
+		nonce         string
+		oidc          bool
+		logins        int
+		checkResponse func(t *testing.T, res TokenResponse, server *Server)
+	}{
+		{
+			name:   "OK",
+			scopes: []string{scopeOpenID, scopeEmail},
+			nonce:  "n-0S6_WzA2Mj",
+			oidc:   true,
+			logins: 1,
+			checkResponse: func(t *testing.T, res TokenResponse, server *Server) {
+				require.NotEmpty(t, res.IDToken)
+
+				payload, err := server.idTokenMaker.VerifyToken(res.IDToken, token.ExpectIssuer(testOIDCIssuer), token.ExpectAudience(client.ID))
+				require.NoError(t, err)
+				require.Equal(t, user.ID, payload.Subject)
+
+				nonce, err := nonceClaim.Get(payload)
+				require.NoError(t, err)
+				require.Equal(t, "n-0S6_WzA2Mj", nonce)
+				authTimeValue, err := authTimeClaim.Get(payload)
+				require.NoError(t, err)
+				require.Equal(t, authTime.Unix(), authTimeValue)
+				amr, err := amrClaim.Get(payload)
+				require.NoError(t, err)
+				require.Equal(t, []string{amrPassword}, amr)
+				acr, err := acrClaim.Get(payload)
+				require.NoError(t, err)
+				require.Equal(t, acrPassword, acr)
+
+				// id tokens are not access tokens
+				_, err = server.tokenMaker.VerifyToken(res.IDToken)
+				require.Error(t, err)
+			},
+		},
+		{
+			name:   "WithoutNonce",
+			scopes: []string{scopeOpenID},
+			oidc:   true,
+			logins: 1,
+			checkResponse: func(t *testing.T, res TokenResponse, server *Server) {
+				payload, err := server.idTokenMaker.VerifyToken(res.IDToken)
+				require.NoError(t, err)
+				_, err = nonceClaim.Get(payload)
+				require.ErrorIs(t, err, token.ErrMissingClaim)
+			},
+		},
+		{
+			name:   "WithoutOpenIDScope",
+			scopes: []string{scopeAdminUsersRead},
+			oidc:   true,
+			checkResponse: func(t *testing.T, res TokenResponse, server *Server) {
+				require.Empty(t, res.IDToken)
+			},
+		},
+		{
+			name:   "OpenIDDisabled",
+			scopes: []string{scopeOpenID},
+			oidc:   false,
+			checkResponse: func(t *testing.T, res TokenResponse, server *Server) {
+				require.Empty(t, res.IDToken)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+				Times(1).
+				Return(client, nil)
+			store.EXPECT().UseAuthorizationCode(gomock.Any(), gomock.Eq(utils.HashToken(code))).
+				Times(1).
+				Return(db.AuthorizationCode{
+					CodeHash:      utils.HashToken(code),
+					ClientID:      client.ID,
+					UserID:        user.ID,
+					RedirectUri:   testRedirectURI,
+					Scopes:        tc.scopes,
+					CodeChallenge: challenge,
+					Nonce:         tc.nonce,
+					ExpiresAt:     time.Now().Add(time.Minute),
+					CreatedAt:     authTime,
+				}, nil)
+			// only clients that got an id token are told when the user logs out
+			store.EXPECT().CreateClientLogin(gomock.Any(), gomock.Eq(db.CreateClientLoginParams{UserID: user.ID, ClientID: client.ID})).
+				Times(tc.logins).
+				Return(nil)
+
+			server := newTestServer(t, store)
+			if tc.oidc {
+				server = newOIDCTestServer(t, store)
+			}
+			form := url.Values{
+				"grant_type":    {grantTypeAuthorizationCode},
+				"client_id":     {client.ID},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+			}
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, newFormRequest(t, "/token", form))
+			require.Equal(t, http.StatusOK, recorder.Code)
+
+			var res TokenResponse
+			require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+			require.Equal(t, strings.Join(tc.scopes, " "), res.Scope)
+			tc.checkResponse(t, res, server)
+		})
+	}
+}
+
+func TestUserInfo(t *testing.T) {
+
+	user, _ := CreateUser(t)
+
+	testCases := []struct {
+		name          string
+		method        string
+		userId        string
+		scopes        []string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "ProfileAndEmail",
+			method: http.MethodGet,
+			userId: user.ID,
+			scopes: []string{scopeOpenID, scopeProfile, scopeEmail},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var res UserInfoResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, UserInfoResponse{
+					Subject:           user.ID,
+					Name:              user.Name,
+					PreferredUsername: user.Username,
+					Email:             user.Email,
+				}, res)
+			},
+		},
+		{
+			name:   "EmailOnlyWithPost",
+			method: http.MethodPost,
+			userId: user.ID,
+			scopes: []string{scopeOpenID, scopeEmail},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, fmt.Sprintf(`{"sub":%q,"email":%q}`, user.ID, user.Email), recorder.Body.String())
+			},
+		},
+		{
+			name:   "OpenIDOnly",
+			method: http.MethodGet,
+			userId: user.ID,
+			scopes: []string{scopeOpenID},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.JSONEq(t, fmt.Sprintf(`{"sub":%q}`, user.ID), recorder.Body.String())
+			},
+		},
+		{
+			name:   "NoOpenIDScope",
+			method: http.MethodGet,
+			userId: user.ID,
+			scopes: []string{scopeProfile, scopeEmail},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().Me(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "ClientToken",
+			method: http.MethodGet,
+			scopes: []string{scopeOpenID},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().Me(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newOIDCTestServer(t, store)
+			opts := []token.PayloadOption{token.WithScopes(tc.scopes...), token.WithClientID("client")}
+			if tc.userId == "" {
+				opts = append(opts, token.WithSubject("client"))
+			}
+			accessToken, err := server.tokenMaker.CreateToken(tc.userId, time.Minute, opts...)
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(tc.method, "/userinfo", nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestOpenIDConfiguration(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	t.Run("OK", func(t *testing.T) {
+		server := newOIDCTestServer(t, mockdb.NewMockStore(ctrl))
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var res OpenIDConfigurationResponse
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+		require.Equal(t, testOIDCIssuer, res.Issuer)
+		require.Equal(t, testOIDCIssuer+"/authorize", res.AuthorizationEndpoint)
+		require.Equal(t, testOIDCIssuer+"/token", res.TokenEndpoint)
+		require.Equal(t, testOIDCIssuer+"/userinfo", res.UserInfoEndpoint)
+		require.Equal(t, testOIDCIssuer+"/.well-known/jwks.json", res.JWKSURI)
+		require.Equal(t, []string{"ES256"}, res.IDTokenSigningAlgValuesSupported)
+		require.Equal(t, []string{codeChallengeMethodS256}, res.CodeChallengeMethodsSupported)
+		require.Contains(t, res.ScopesSupported, scopeOpenID)
+		require.Equal(t, testOIDCIssuer+"/end-session", res.EndSessionEndpoint)
+		require.True(t, res.BackchannelLogoutSupported)
+		require.False(t, res.BackchannelLogoutSessionSupported)
+
+		// the id token key is published next to the access token keys
+		request, err = http.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+		require.NoError(t, err)
+		recorder = httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusOK, recorder.Code)
+
+		var set token.JWKSet
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &set))
+		require.Len(t, set.Keys, 1)
+		require.Equal(t, "EC", set.Keys[0].KeyType)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		server := newTestServer(t, mockdb.NewMockStore(ctrl))
+
+		request, err := http.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil)
+		require.NoError(t, err)
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		require.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+}
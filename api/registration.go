@@ -0,0 +1,380 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RFC 7591 section 3.2.2 registration errors
+const (
+	oauthInvalidRedirectURI    = "invalid_redirect_uri"
+	oauthInvalidClientMetadata = "invalid_client_metadata"
+)
+
+// token endpoint authentication methods, none is a public client
+const (
+	authMethodNone              = "none"
+	authMethodClientSecretBasic = "client_secret_basic"
+	authMethodClientSecretPost  = "client_secret_post"
+)
+
+const (
+	clientSecretSize      = 32
+	registrationTokenSize = 32
+	// set by registrationAuth for the handlers
+	registrationClientKey = "registration_client"
+	registrationAdminKey  = "registration_admin"
+)
+
+var registrableGrantTypes = []string{grantTypeAuthorizationCode, grantTypeClientCredentials, grantTypeDeviceCode, grantTypeTokenExchange}
+
+// ClientMetadata is the subset of RFC 7591 section 2 metadata clients can register
+type ClientMetadata struct {
+	ClientName              string   `json:"client_name" binding:"required"`
+	RedirectURIs            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope"`
+	// OpenID Connect RP-Initiated and Back-Channel Logout metadata
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutURI   string   `json:"backchannel_logout_uri,omitempty"`
+}
+
+// ClientRegistrationResponse is the RFC 7591 section 3.2.1 client information, secret
+// and registration access token are only returned once when the client is created
+type ClientRegistrationResponse struct {
+	ClientID                string `json:"client_id"`
+	ClientSecret            string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt        int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt   int64  `json:"client_secret_expires_at"`
+	RegistrationAccessToken string `json:"registration_access_token,omitempty"`
+	RegistrationClientURI   string `json:"registration_client_uri"`
+	ClientMetadata
+}
+
+func newClientRegistrationResponse(origin string, client db.Client) ClientRegistrationResponse {
+	return ClientRegistrationResponse{
+		ClientID:              client.ID,
+		ClientIDIssuedAt:      client.CreatedAt.Unix(),
+		RegistrationClientURI: origin + "/register-client/" + client.ID,
+		ClientMetadata: ClientMetadata{
+			ClientName:              client.Name,
+			RedirectURIs:            client.RedirectUris,
+			GrantTypes:              client.GrantTypes,
+			TokenEndpointAuthMethod: client.TokenEndpointAuthMethod,
+			Scope:                   strings.Join(client.Scopes, " "),
+			PostLogoutRedirectURIs:  client.PostLogoutRedirectUris,
+			BackchannelLogoutURI:    client.BackchannelLogoutUri,
+		},
+	}
+}
+
+// registrationAuth accept the initial access token to register a client, the registration
+// access token of a client to manage it, or an access token with the admin:clients:write scope
+func (server *Server) registrationAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// the client of the path, kept so admin requests don't look it up again
+		var client *db.Client
+		fields := strings.Fields(ctx.GetHeader(authorizationHeaderKey))
+		if len(fields) == 2 && strings.ToLower(fields[0]) == authorizationTypeBearer {
+			bearer := fields[1]
+
+			clientId := ctx.Param("client_id")
+			if clientId == "" {
+				initial := server.config.ClientRegistrationToken
+				if initial != "" && subtle.ConstantTimeCompare([]byte(bearer), []byte(initial)) == 1 {
+					ctx.Next()
+					return
+				}
+			} else {
+				found, err := server.store.GetClient(ctx, clientId)
+				if err != nil && err != sql.ErrNoRows {
+					ctx.AbortWithStatusJSON(http.StatusInternalServerError, errResponse(err))
+					return
+				}
+				// unknown clients fall through so they can't be told apart from a bad token
+				if err == nil {
+					client = &found
+					if found.RegistrationTokenHash != "" &&
+						subtle.ConstantTimeCompare([]byte(utils.HashToken(bearer)), []byte(found.RegistrationTokenHash)) == 1 {
+						ctx.Set(registrationClientKey, found)
+						ctx.Next()
+						return
+					}
+				}
+			}
+		}
+
+		payload, status, err := server.authenticate(ctx, server.verifyOptions())
+		if err != nil {
+			ctx.AbortWithStatusJSON(status, errResponse(err))
+			return
+		}
+		if !payload.HasScopes(scopeAdminClientsWrite) {
+			err := fmt.Errorf("insufficient scope, required : %s", scopeAdminClientsWrite)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errResponse(err))
+			return
+		}
+		ctx.Set(registrationAdminKey, true)
+		if client != nil {
+			ctx.Set(registrationClientKey, *client)
+		}
+		ctx.Next()
+	}
+}
+
+// RegisterClient implements RFC 7591 dynamic client registration
+func (server *Server) RegisterClient(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	var req ClientMetadata
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidClientMetadata, err.Error()))
+		return
+	}
+	if code, description := validateClientMetadata(&req, ctx.GetBool(registrationAdminKey)); code != "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(code, description))
+		return
+	}
+
+	var secret, secretHash string
+	if req.TokenEndpointAuthMethod != authMethodNone {
+		var err error
+		secret, err = utils.RandomSecureToken(clientSecretSize)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+			return
+		}
+		secretHash, err = utils.HashPassword(secret)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+			return
+		}
+	}
+	registrationToken, err := utils.RandomSecureToken(registrationTokenSize)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	client, err := server.store.CreateClient(ctx, db.CreateClientParams{
+		ID:                      uuid.New().String(),
+		Name:                    req.ClientName,
+		SecretHash:              secretHash,
+		Scopes:                  strings.Fields(req.Scope),
+		ExchangeAudiences:       []string{},
+		RedirectUris:            req.RedirectURIs,
+		Public:                  req.TokenEndpointAuthMethod == authMethodNone,
+		GrantTypes:              req.GrantTypes,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		RegistrationTokenHash:   utils.HashToken(registrationToken),
+		PostLogoutRedirectUris:  req.PostLogoutRedirectURIs,
+		BackchannelLogoutUri:    req.BackchannelLogoutURI,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+
+	response := newClientRegistrationResponse(server.requestOrigin(ctx), client)
+	response.ClientSecret = secret
+	response.RegistrationAccessToken = registrationToken
+	ctx.JSON(http.StatusCreated, response)
+}
+
+// registeredClient returns the client of the path found by registrationAuth, or looks it up
+func (server *Server) registeredClient(ctx *gin.Context) (db.Client, bool) {
+	if client, ok := ctx.Get(registrationClientKey); ok {
+		return client.(db.Client), true
+	}
+	client, err := server.store.GetClient(ctx, ctx.Param("client_id"))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errResponse(err))
+			return db.Client{}, false
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return db.Client{}, false
+	}
+	return client, true
+}
+
+// GetRegisteredClient is the RFC 7592 client read request
+func (server *Server) GetRegisteredClient(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	client, ok := server.registeredClient(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, newClientRegistrationResponse(server.requestOrigin(ctx), client))
+}
+
+// UpdateRegisteredClient is the RFC 7592 client update request, the metadata is replaced as a whole
+func (server *Server) UpdateRegisteredClient(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "no-store")
+
+	client, ok := server.registeredClient(ctx)
+	if !ok {
+		return
+	}
+
+	var req ClientMetadata
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidClientMetadata, err.Error()))
+		return
+	}
+	if code, description := validateClientMetadata(&req, ctx.GetBool(registrationAdminKey)); code != "" {
+		ctx.JSON(http.StatusBadRequest, oauthError(code, description))
+		return
+	}
+	// a secret can't be added or dropped in place, register a new client instead
+	if (req.TokenEndpointAuthMethod == authMethodNone) != client.Public {
+		ctx.JSON(http.StatusBadRequest, oauthError(oauthInvalidClientMetadata, "public and confidential clients can't be switched"))
+		return
+	}
+
+	client, err := server.store.UpdateClient(ctx, db.UpdateClientParams{
+		ID:                      client.ID,
+		Name:                    req.ClientName,
+		Scopes:                  strings.Fields(req.Scope),
+		RedirectUris:            req.RedirectURIs,
+		GrantTypes:              req.GrantTypes,
+		TokenEndpointAuthMethod: req.TokenEndpointAuthMethod,
+		PostLogoutRedirectUris:  req.PostLogoutRedirectURIs,
+		BackchannelLogoutUri:    req.BackchannelLogoutURI,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, oauthError(oauthServerError, err.Error()))
+		return
+	}
+	ctx.JSON(http.StatusOK, newClientRegistrationResponse(server.requestOrigin(ctx), client))
+}
+
+// DeleteRegisteredClient is the RFC 7592 client delete request, pending codes of the client go with it
+func (server *Server) DeleteRegisteredClient(ctx *gin.Context) {
+	client, ok := server.registeredClient(ctx)
+	if !ok {
+		return
+	}
+
+	err := server.store.DeleteClient(ctx, client.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// validateClientMetadata fills the RFC 7591 defaults and returns the error code of invalid
+// metadata, scopes of roles can only be given to clients by admins
+func validateClientMetadata(metadata *ClientMetadata, admin bool) (string, string) {
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{grantTypeAuthorizationCode}
+	}
+	for _, grantType := range metadata.GrantTypes {
+		if !hasScope(registrableGrantTypes, grantType) {
+			return oauthInvalidClientMetadata, "unsupported grant type " + grantType
+		}
+	}
+
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = authMethodClientSecretBasic
+	}
+	switch metadata.TokenEndpointAuthMethod {
+	case authMethodNone:
+		// without a secret the client can't be trusted with grants acting as itself
+		if hasScope(metadata.GrantTypes, grantTypeClientCredentials) || hasScope(metadata.GrantTypes, grantTypeTokenExchange) {
+			return oauthInvalidClientMetadata, "public clients can only use the authorization code and device grants"
+		}
+	case authMethodClientSecretBasic, authMethodClientSecretPost:
+	default:
+		return oauthInvalidClientMetadata, "unsupported token endpoint auth method " + metadata.TokenEndpointAuthMethod
+	}
+
+	if metadata.RedirectURIs == nil {
+		metadata.RedirectURIs = []string{}
+	}
+	if hasScope(metadata.GrantTypes, grantTypeAuthorizationCode) && len(metadata.RedirectURIs) == 0 {
+		return oauthInvalidRedirectURI, "redirect_uris are required for the authorization code grant"
+	}
+	for _, redirectURI := range metadata.RedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return oauthInvalidRedirectURI, "invalid redirect uri " + redirectURI
+		}
+	}
+
+	if metadata.PostLogoutRedirectURIs == nil {
+		metadata.PostLogoutRedirectURIs = []string{}
+	}
+	for _, redirectURI := range metadata.PostLogoutRedirectURIs {
+		if !validRedirectURI(redirectURI) {
+			return oauthInvalidClientMetadata, "invalid post logout redirect uri " + redirectURI
+		}
+	}
+	// logout tokens are posted by the server, only ever over https
+	if metadata.BackchannelLogoutURI != "" {
+		uri, err := url.Parse(metadata.BackchannelLogoutURI)
+		if err != nil || uri.Scheme != "https" || uri.Host == "" || strings.Contains(metadata.BackchannelLogoutURI, "#") {
+			return oauthInvalidClientMetadata, "backchannel_logout_uri must be an https url"
+		}
+	}
+
+	if !admin {
+		for _, scope := range strings.Fields(metadata.Scope) {
+			if privilegedScope(scope) {
+				return oauthInvalidClientMetadata, "only admins can register clients with the scope " + scope
+			}
+		}
+	}
+	return "", ""
+}
+
+// adminOnlyScopes aren't granted by any role but still let a client act on other clients' tokens
+var adminOnlyScopes = []string{scopeTokenIntrospect}
+
+// privilegedScope reports whether only admins can register a client with scope
+func privilegedScope(scope string) bool {
+	if hasScope(adminOnlyScopes, scope) {
+		return true
+	}
+	for _, roleScope := range roleScopes {
+		if hasScope(roleScope, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// validRedirectURI accept https, http on the loopback for native apps (RFC 8252 section 7.3)
+// and private-use schemes like com.example.app:/callback (RFC 8252 section 7.1), never fragments
+func validRedirectURI(redirectURI string) bool {
+	uri, err := url.Parse(redirectURI)
+	if err != nil || !uri.IsAbs() || strings.Contains(redirectURI, "#") {
+		return false
+	}
+	switch uri.Scheme {
+	case "https":
+		return uri.Host != ""
+	case "http":
+		host := uri.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	default:
+		return strings.Contains(uri.Scheme, ".")
+	}
+}
+
+// clientAllowsGrant check the registered grant types, clients created before grant types
+// were registered have none and may use every grant
+func clientAllowsGrant(client db.Client, grantType string) bool {
+	return len(client.GrantTypes) == 0 || hasScope(client.GrantTypes, grantType)
+}
@@ -0,0 +1,682 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+)
+
+const testRegistrationToken = "initial-access-token"
+
+// createRegisteredClient is a client registered with a registration access token
+func createRegisteredClient(t *testing.T) (db.Client, string) {
+	client, _ := createClient(t, "reports:read")
+	client.RedirectUris = []string{testRedirectURI}
+	client.GrantTypes = []string{grantTypeAuthorizationCode}
+	client.TokenEndpointAuthMethod = authMethodClientSecretBasic
+	client.CreatedAt = time.Now()
+	registrationToken := utils.RandomString(43)
+	client.RegistrationTokenHash = utils.HashToken(registrationToken)
+	return client, registrationToken
+}
+
+func newJSONRequest(t *testing.T, method string, url string, body gin.H) *http.Request {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+	request, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	return request
+}
+
+func TestRegisterClient(t *testing.T) {
+
+	returnCreated := func(_ context.Context, arg db.CreateClientParams) (db.Client, error) {
+		return db.Client{
+			ID:                      arg.ID,
+			Name:                    arg.Name,
+			SecretHash:              arg.SecretHash,
+			Scopes:                  arg.Scopes,
+			ExchangeAudiences:       arg.ExchangeAudiences,
+			RedirectUris:            arg.RedirectUris,
+			Public:                  arg.Public,
+			GrantTypes:              arg.GrantTypes,
+			TokenEndpointAuthMethod: arg.TokenEndpointAuthMethod,
+			RegistrationTokenHash:   arg.RegistrationTokenHash,
+			PostLogoutRedirectUris:  arg.PostLogoutRedirectUris,
+			BackchannelLogoutUri:    arg.BackchannelLogoutUri,
+			CreatedAt:               time.Now(),
+		}, nil
+	}
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		authorization func(t *testing.T, server *Server) string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{testRedirectURI}, "scope": "reports:read"},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateClientParams) (db.Client, error) {
+						require.Equal(t, "reports", arg.Name)
+						require.Equal(t, []string{testRedirectURI}, arg.RedirectUris)
+						require.Equal(t, []string{"reports:read"}, arg.Scopes)
+						require.Equal(t, []string{grantTypeAuthorizationCode}, arg.GrantTypes)
+						require.Equal(t, authMethodClientSecretBasic, arg.TokenEndpointAuthMethod)
+						require.False(t, arg.Public)
+						require.NotEmpty(t, arg.SecretHash)
+						require.NotEmpty(t, arg.RegistrationTokenHash)
+						return returnCreated(ctx, arg)
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+				require.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+				var res ClientRegistrationResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.NotEmpty(t, res.ClientID)
+				require.NotEmpty(t, res.ClientSecret)
+				require.NotEmpty(t, res.RegistrationAccessToken)
+				require.NotZero(t, res.ClientIDIssuedAt)
+				require.Zero(t, res.ClientSecretExpiresAt)
+				require.Equal(t, "http://example.com/register-client/"+res.ClientID, res.RegistrationClientURI)
+				require.Equal(t, []string{grantTypeAuthorizationCode}, res.GrantTypes)
+				require.Equal(t, authMethodClientSecretBasic, res.TokenEndpointAuthMethod)
+			},
+		},
+		{
+			name: "PublicClient",
+			body: gin.H{"client_name": "spa", "redirect_uris": []string{"com.example.app:/callback"}, "token_endpoint_auth_method": authMethodNone},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(ctx context.Context, arg db.CreateClientParams) (db.Client, error) {
+						require.True(t, arg.Public)
+						require.Empty(t, arg.SecretHash)
+						return returnCreated(ctx, arg)
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res ClientRegistrationResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Empty(t, res.ClientSecret)
+				require.NotEmpty(t, res.RegistrationAccessToken)
+			},
+		},
+		{
+			name: "AdminRoleScope",
+			body: gin.H{"client_name": "backoffice", "grant_types": []string{grantTypeClientCredentials}, "scope": scopeAdminUsersRead},
+			authorization: func(t *testing.T, server *Server) string {
+				accessToken, err := server.tokenMaker.CreateToken(utils.RandomString(12), time.Minute, token.WithScopes(scopeAdminClientsWrite))
+				require.NoError(t, err)
+				return accessToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(returnCreated)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "RoleScopeWithInitialToken",
+			body: gin.H{"client_name": "backoffice", "grant_types": []string{grantTypeClientCredentials}, "scope": scopeAdminUsersRead},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name: "IntrospectScopeWithInitialToken",
+			body: gin.H{"client_name": "gateway", "grant_types": []string{grantTypeClientCredentials}, "scope": "reports:read " + scopeTokenIntrospect},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name: "IntrospectScopeWithAdminToken",
+			body: gin.H{"client_name": "gateway", "grant_types": []string{grantTypeClientCredentials}, "scope": scopeTokenIntrospect},
+			authorization: func(t *testing.T, server *Server) string {
+				accessToken, err := server.tokenMaker.CreateToken(utils.RandomString(12), time.Minute, token.WithScopes(scopeAdminClientsWrite))
+				require.NoError(t, err)
+				return accessToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(returnCreated)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+			},
+		},
+		{
+			name: "WrongInitialToken",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{testRedirectURI}},
+			authorization: func(t *testing.T, server *Server) string {
+				return "wrong-token"
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NoAuthorization",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{testRedirectURI}},
+			authorization: func(t *testing.T, server *Server) string {
+				return ""
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "NotAdmin",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{testRedirectURI}},
+			authorization: func(t *testing.T, server *Server) string {
+				accessToken, err := server.tokenMaker.CreateToken(utils.RandomString(12), time.Minute, token.WithScopes(scopeAdminUsersRead))
+				require.NoError(t, err)
+				return accessToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name: "MissingRedirectURIs",
+			body: gin.H{"client_name": "reports"},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidRedirectURI)
+			},
+		},
+		{
+			name: "InvalidRedirectURI",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{"http://app.example.com/callback"}},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidRedirectURI)
+			},
+		},
+		{
+			name: "LogoutURIs",
+			body: gin.H{
+				"client_name":               "reports",
+				"redirect_uris":             []string{testRedirectURI},
+				"post_logout_redirect_uris": []string{"https://app.example.com/logged-out"},
+				"backchannel_logout_uri":    "https://app.example.com/backchannel-logout",
+			},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(returnCreated)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusCreated, recorder.Code)
+
+				var res ClientRegistrationResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, []string{"https://app.example.com/logged-out"}, res.PostLogoutRedirectURIs)
+				require.Equal(t, "https://app.example.com/backchannel-logout", res.BackchannelLogoutURI)
+			},
+		},
+		{
+			name: "InvalidBackchannelLogoutURI",
+			body: gin.H{"client_name": "reports", "redirect_uris": []string{testRedirectURI}, "backchannel_logout_uri": "http://app.example.com/backchannel-logout"},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name: "PublicClientCredentials",
+			body: gin.H{"client_name": "spa", "grant_types": []string{grantTypeClientCredentials}, "token_endpoint_auth_method": authMethodNone},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name: "UnsupportedGrantType",
+			body: gin.H{"client_name": "legacy", "grant_types": []string{"password"}},
+			authorization: func(t *testing.T, server *Server) string {
+				return testRegistrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().CreateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			server.config.ClientRegistrationToken = testRegistrationToken
+
+			request := newJSONRequest(t, http.MethodPost, "http://example.com/register-client", tc.body)
+			if authorization := tc.authorization(t, server); authorization != "" {
+				request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, authorization))
+			}
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestManageRegisteredClient(t *testing.T) {
+
+	client, registrationToken := createRegisteredClient(t)
+	adminToken := func(t *testing.T, server *Server) string {
+		accessToken, err := server.tokenMaker.CreateToken(utils.RandomString(12), time.Minute, token.WithScopes(scopeAdminClientsWrite))
+		require.NoError(t, err)
+		return accessToken
+	}
+	update := gin.H{"client_name": "renamed", "redirect_uris": []string{testRedirectURI, "http://127.0.0.1:8080/callback"}, "scope": "reports:read reports:write"}
+
+	testCases := []struct {
+		name          string
+		method        string
+		body          gin.H
+		authorization func(t *testing.T, server *Server) string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "Read",
+			method: http.MethodGet,
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res ClientRegistrationResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, client.ID, res.ClientID)
+				require.Equal(t, client.Name, res.ClientName)
+				require.Equal(t, "reports:read", res.Scope)
+				// credentials are only shown once
+				require.Empty(t, res.ClientSecret)
+				require.Empty(t, res.RegistrationAccessToken)
+			},
+		},
+		{
+			name:          "ReadWithAdminToken",
+			method:        http.MethodGet,
+			authorization: adminToken,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:          "UnknownClientWithAdminToken",
+			method:        http.MethodGet,
+			authorization: adminToken,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(2).
+					Return(db.Client{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+		{
+			name:   "UnknownClient",
+			method: http.MethodGet,
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(db.Client{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "OtherClientToken",
+			method: http.MethodGet,
+			authorization: func(t *testing.T, server *Server) string {
+				_, otherToken := createRegisteredClient(t)
+				return otherToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name:   "Update",
+			method: http.MethodPut,
+			body:   update,
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UpdateClient(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.UpdateClientParams) (db.Client, error) {
+						require.Equal(t, client.ID, arg.ID)
+						require.Equal(t, "renamed", arg.Name)
+						require.Equal(t, []string{"reports:read", "reports:write"}, arg.Scopes)
+						require.Equal(t, []string{testRedirectURI, "http://127.0.0.1:8080/callback"}, arg.RedirectUris)
+						require.Equal(t, []string{grantTypeAuthorizationCode}, arg.GrantTypes)
+						require.Equal(t, authMethodClientSecretBasic, arg.TokenEndpointAuthMethod)
+
+						updated := client
+						updated.Name = arg.Name
+						updated.Scopes = arg.Scopes
+						updated.RedirectUris = arg.RedirectUris
+						return updated, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+
+				var res ClientRegistrationResponse
+				require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &res))
+				require.Equal(t, "renamed", res.ClientName)
+				require.Equal(t, "reports:read reports:write", res.Scope)
+			},
+		},
+		{
+			name:   "UpdateToIntrospectScope",
+			method: http.MethodPut,
+			body:   gin.H{"client_name": "renamed", "redirect_uris": []string{testRedirectURI}, "scope": scopeTokenIntrospect},
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UpdateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name:   "UpdateToPublic",
+			method: http.MethodPut,
+			body:   gin.H{"client_name": "renamed", "redirect_uris": []string{testRedirectURI}, "token_endpoint_auth_method": authMethodNone},
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().UpdateClient(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClientMetadata)
+			},
+		},
+		{
+			name:   "Delete",
+			method: http.MethodDelete,
+			authorization: func(t *testing.T, server *Server) string {
+				return registrationToken
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(client, nil)
+				store.EXPECT().DeleteClient(gomock.Any(), gomock.Eq(client.ID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNoContent, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			request := newJSONRequest(t, tc.method, "/register-client/"+client.ID, tc.body)
+			request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, tc.authorization(t, server)))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	valid := []string{
+		"https://app.example.com/callback",
+		"https://app.example.com/callback?tenant=acme",
+		"http://localhost:8080/callback",
+		"http://127.0.0.1/callback",
+		"http://[::1]:3000/callback",
+		"com.example.app:/callback",
+	}
+	for _, redirectURI := range valid {
+		require.True(t, validRedirectURI(redirectURI), redirectURI)
+	}
+
+	invalid := []string{
+		"",
+		"/callback",
+		"http://app.example.com/callback",
+		"https://app.example.com/callback#fragment",
+		"https:///callback",
+		"javascript:alert(1)",
+		"myapp:/callback",
+	}
+	for _, redirectURI := range invalid {
+		require.False(t, validRedirectURI(redirectURI), redirectURI)
+	}
+}
+
+func TestRegisteredClientRestrictions(t *testing.T) {
+
+	client, secret := createClient(t, "reports:read")
+	client.GrantTypes = []string{grantTypeClientCredentials}
+	client.TokenEndpointAuthMethod = authMethodClientSecretPost
+
+	testCases := []struct {
+		name          string
+		buildRequest  func() *http.Request
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name: "OK",
+			buildRequest: func() *http.Request {
+				return newFormRequest(t, "/token", url.Values{
+					"grant_type":    {grantTypeClientCredentials},
+					"client_id":     {client.ID},
+					"client_secret": {secret},
+				})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name: "UnregisteredGrantType",
+			buildRequest: func() *http.Request {
+				return newFormRequest(t, "/token", url.Values{
+					"grant_type":    {grantTypeTokenExchange},
+					"client_id":     {client.ID},
+					"client_secret": {secret},
+				})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthUnauthorizedClient)
+			},
+		},
+		{
+			name: "UnregisteredAuthMethod",
+			buildRequest: func() *http.Request {
+				request := newFormRequest(t, "/token", url.Values{"grant_type": {grantTypeClientCredentials}})
+				request.SetBasicAuth(client.ID, secret)
+				return request
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), oauthInvalidClient)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			store.EXPECT().GetClient(gomock.Any(), gomock.Eq(client.ID)).
+				Times(1).
+				Return(client, nil)
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, tc.buildRequest())
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scopeAdminUsersRead    = "admin:users:read"
+	scopeAdminUsersWrite   = "admin:users:write"
+	scopeAdminMetricsRead  = "admin:metrics:read"
+	scopeAdminClientsWrite = "admin:clients:write"
+)
+
+// roleScopes is what each role stored on a user grants in its tokens
+var roleScopes = map[string][]string{
+	"admin":   {scopeAdminUsersRead, scopeAdminUsersWrite, scopeAdminMetricsRead, scopeAdminClientsWrite},
+	"support": {scopeAdminUsersRead},
+}
+
+func scopesForRoles(roles []string) []string {
+	seen := make(map[string]bool)
+	scopes := []string{}
+	for _, role := range roles {
+		for _, scope := range roleScopes[role] {
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+	}
+	return scopes
+}
+
+// userScopes is everything user can grant to a client, the scopes of their roles and
+// the OpenID Connect ones when id tokens are issued
+func (server *Server) userScopes(user db.User) []string {
+	scopes := scopesForRoles(user.Roles)
+	if server.idTokenMaker != nil {
+		scopes = append(scopes, oidcScopes...)
+	}
+	return scopes
+}
+
+// requireScopes must be used after authMiddleware, it reject tokens missing one of scopes
+func requireScopes(scopes ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		payload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if !ok {
+			err := fmt.Errorf("something went wrong checking token payload !")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errResponse(err))
+			return
+		}
+
+		if !payload.HasScopes(scopes...) {
+			err := fmt.Errorf("insufficient scope, required : %s", strings.Join(scopes, " "))
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errResponse(err))
+			return
+		}
+		ctx.Next()
+	}
+}
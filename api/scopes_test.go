@@ -0,0 +1,149 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopesForRoles(t *testing.T) {
+	require.Empty(t, scopesForRoles(nil))
+	require.Empty(t, scopesForRoles([]string{"unknown"}))
+	require.Equal(t, []string{scopeAdminUsersRead, scopeAdminUsersWrite, scopeAdminMetricsRead, scopeAdminClientsWrite}, scopesForRoles([]string{"support", "admin"}))
+}
+
+func TestRequireScopes(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		scopes        []string
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			scopes: []string{scopeAdminUsersRead, scopeAdminUsersWrite},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+			},
+		},
+		{
+			name:   "MissingScope",
+			scopes: []string{scopeAdminUsersRead},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+				require.Contains(t, recorder.Body.String(), scopeAdminUsersWrite)
+			},
+		},
+		{
+			name: "NoScope",
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			url := "/scoped"
+
+			server := newTestServer(t, nil)
+			server.router.GET(url, server.authMiddleware(), requireScopes(scopeAdminUsersRead, scopeAdminUsersWrite), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, gin.H{})
+			})
+
+			accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithScopes(tc.scopes...))
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
+
+func TestGetUserEP(t *testing.T) {
+
+	user, _ := CreateUser(t)
+
+	testCases := []struct {
+		name          string
+		scopes        []string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder)
+	}{
+		{
+			name:   "OK",
+			scopes: []string{scopeAdminUsersRead},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Eq(user.Username)).
+					Times(1).
+					Return(user, nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				require.Contains(t, recorder.Body.String(), user.Email)
+			},
+		},
+		{
+			name: "Forbidden",
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusForbidden, recorder.Code)
+			},
+		},
+		{
+			name:   "NotFound",
+			scopes: []string{scopeAdminUsersRead},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetUser(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.User{}, sql.ErrNoRows)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder) {
+				require.Equal(t, http.StatusNotFound, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			server := newTestServer(t, store)
+			accessToken, err := server.tokenMaker.CreateToken(uuid.New().String(), time.Minute, token.WithScopes(tc.scopes...))
+			require.NoError(t, err)
+
+			url := fmt.Sprintf("/admin/users/%s", user.Username)
+			request, err := http.NewRequest(http.MethodGet, url, nil)
+			require.NoError(t, err)
+			request.Header.Add(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+
+			recorder := httptest.NewRecorder()
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder)
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
 import (
+	"context"
+	"expvar"
+	"net/http"
+
 	db "github.com/brkss/go-auth/db/sqlc"
 	token "github.com/brkss/go-auth/token"
 	"github.com/brkss/go-auth/utils"
@@ -8,29 +12,138 @@ import (
 )
 
 type Server struct {
-	router     *gin.Engine
-	store      db.Store
-	tokenMaker token.Maker
-	config     *utils.Config
+	router      *gin.Engine
+	store       db.Store
+	tokenMaker  token.Maker
+	revocations token.RevocationStore
+	dpopReplays *token.ReplayCache
+	// tokenCache is nil unless TokenCacheSize is set
+	tokenCache *token.CachedMaker
+	// idTokenMaker signs OpenID Connect id tokens, nil turns OpenID Connect off
+	idTokenMaker token.Maker
+	// logoutClient posts back-channel logout tokens to relying parties
+	logoutClient *http.Client
+	// upstreams are the external OpenID providers users can log in with, by name
+	upstreams map[string]*upstreamProvider
+	config    *utils.Config
 }
 
-func NewServer(store db.Store, tokenMaker token.Maker, config *utils.Config) *Server {
-	server := &Server{store: store, tokenMaker: tokenMaker, config: config}
+func NewServer(store db.Store, tokenMaker token.Maker, idTokenMaker token.Maker, config *utils.Config) *Server {
+	server := &Server{store: store, tokenMaker: tokenMaker, idTokenMaker: idTokenMaker, config: config, dpopReplays: token.NewReplayCache(), logoutClient: newLogoutClient()}
+	server.upstreams = newUpstreamProviders(config.UpstreamProviders, newUpstreamClient())
+
+	if config.TokenCacheSize > 0 {
+		server.tokenCache = token.NewCachedMaker(tokenMaker, config.TokenCacheSize, config.TokenCacheTTL)
+	}
+
+	if config.RevocationBackend == "memory" {
+		server.revocations = token.NewMemoryRevocationStore()
+	} else {
+		server.revocations = token.NewPostgresRevocationStore(store)
+	}
+
 	router := gin.Default()
+	// gin trusts X-Forwarded-For from anyone by default, ClientIP would then be whatever the client claims.
+	// LoadConfig already rejected invalid entries
+	router.SetTrustedProxies(config.TrustedProxies)
 
-	router.POST("/login", server.Login)
-	router.POST("/register", server.Register)
+	router.POST("/login", server.dpopBinding(), server.Login)
+	router.POST("/register", server.dpopBinding(), server.Register)
+	router.GET("/login/:provider", server.UpstreamLogin)
+	router.GET("/login/:provider/callback", server.UpstreamCallback)
+	router.POST("/token/refresh", server.dpopBinding(), server.RefreshToken)
+	router.GET("/authorize", server.Authorize)
+	router.POST("/authorize", server.AuthorizeLogin)
+	router.POST("/token", server.dpopBinding(), server.Token)
+	router.POST("/device/code", server.DeviceCode)
+	router.POST("/introspect", server.Introspect)
+	router.POST("/register-client", server.registrationAuth(), server.RegisterClient)
+	router.GET("/register-client/:client_id", server.registrationAuth(), server.GetRegisteredClient)
+	router.PUT("/register-client/:client_id", server.registrationAuth(), server.UpdateRegisteredClient)
+	router.DELETE("/register-client/:client_id", server.registrationAuth(), server.DeleteRegisteredClient)
+	router.GET("/.well-known/jwks.json", server.JWKS)
+	router.GET("/.well-known/paserk.json", server.PASERKS)
+	router.GET("/.well-known/openid-configuration", server.OpenIDConfiguration)
+	router.GET("/end-session", server.EndSession)
+	router.POST("/end-session", server.EndSession)
 
 	// -- Protected routes
-	authRoutes := router.Group("/").Use(authMiddleware(server.tokenMaker))
+	authRoutes := router.Group("/").Use(server.authMiddleware())
 	authRoutes.GET("/me", server.Me)
+	authRoutes.POST("/logout", server.Logout)
+	authRoutes.POST("/logout/all", server.LogoutAll)
+	authRoutes.GET("/device", server.DeviceInfo)
+	authRoutes.POST("/device", server.DeviceApprove)
+	authRoutes.GET("/userinfo", requireScopes(scopeOpenID), server.UserInfo)
+	authRoutes.POST("/userinfo", requireScopes(scopeOpenID), server.UserInfo)
+
+	adminRoutes := router.Group("/admin").Use(server.authMiddleware())
+	adminRoutes.GET("/users/:username", requireScopes(scopeAdminUsersRead), server.GetUser)
+	// expvar metrics, token_paseto_v2_* tell how many legacy tokens are still around
+	adminRoutes.GET("/metrics", requireScopes(scopeAdminMetricsRead), gin.WrapH(expvar.Handler()))
 
 	server.router = router
 	return server
 }
 
-func (server *Server) Start(address string) {
-	server.router.Run(address)
+// verifier is the maker used by authMiddleware, cached when TokenCacheSize is set
+func (server *Server) verifier() token.Maker {
+	if server.tokenCache != nil {
+		return server.tokenCache
+	}
+	return server.tokenMaker
+}
+
+// tokenOptions are the claims every token issued by the server carries
+func (server *Server) tokenOptions() []token.PayloadOption {
+	var opts []token.PayloadOption
+	if server.config.TokenIssuer != "" {
+		opts = append(opts, token.WithIssuer(server.config.TokenIssuer))
+	}
+	if len(server.config.TokenAudience) > 0 {
+		opts = append(opts, token.WithAudience(server.config.TokenAudience...))
+	}
+	return opts
+}
+
+// verifyOptions are the checks of every protected route, audience can be overridden per route
+func (server *Server) verifyOptions() []token.VerifyOption {
+	opts := []token.VerifyOption{token.AllowLeeway(server.config.TokenLeeway)}
+	if server.config.TokenIssuer != "" {
+		opts = append(opts, token.ExpectIssuer(server.config.TokenIssuer))
+	}
+	if len(server.config.TokenAudience) > 0 {
+		opts = append(opts, token.ExpectAudience(server.config.TokenAudience...))
+	}
+	return opts
+}
+
+// Start serve plain http, or https when TLSCertFile is set
+func (server *Server) Start(address string) error {
+	go token.RunRevocationCleanup(context.Background(), server.revocations, server.config.RevocationCleanupInterval)
+	go token.RunRevocationCleanup(context.Background(), expiredCodes{store: server.store}, server.config.RevocationCleanupInterval)
+	// opaque tokens keep a row per token
+	if deleter, ok := server.tokenMaker.(token.ExpiredDeleter); ok {
+		go token.RunRevocationCleanup(context.Background(), deleter, server.config.RevocationCleanupInterval)
+	}
+	if server.idTokenMaker != nil {
+		go server.runLogoutDeliveries(context.Background(), logoutDeliveryInterval)
+	}
+
+	if server.config.TLSCertFile == "" {
+		return server.router.Run(address)
+	}
+
+	tlsConfig, err := newTLSConfig(server.config)
+	if err != nil {
+		return err
+	}
+	httpServer := &http.Server{
+		Addr:      address,
+		Handler:   server.router,
+		TLSConfig: tlsConfig,
+	}
+	return httpServer.ListenAndServeTLS(server.config.TLSCertFile, server.config.TLSKeyFile)
 }
 
 func errResponse(err error) gin.H {
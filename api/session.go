@@ -0,0 +1,185 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/token"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const refreshTokenSize = 32
+
+var errRefreshTokenReused = fmt.Errorf("refresh token has already been used, session revoked !")
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// createSession issue a new access token and a refresh token stored in sessions,
+// an empty familyId starts a new token family (login, register)
+func (server *Server) createSession(ctx *gin.Context, user db.User, familyId string) (*AuthResponse, error) {
+	response, arg, err := server.newSession(ctx, user, familyId)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = server.store.CreateSession(ctx, arg)
+	if err != nil {
+		return nil, err
+	}
+	setFingerprint(ctx, response)
+	return response, nil
+}
+
+// newSession issue the tokens of a session, storing it and setting the fingerprint
+// cookie is left to the caller
+func (server *Server) newSession(ctx *gin.Context, user db.User, familyId string) (*AuthResponse, db.CreateSessionParams, error) {
+
+	opts := append(server.tokenOptions(),
+		token.WithRoles(user.Roles...),
+		token.WithScopes(scopesForRoles(user.Roles)...),
+	)
+	binding, tokenType := server.tokenBinding(ctx)
+	opts = append(opts, binding...)
+	// bearer tokens get a fingerprint cookie, sender constrained ones already need a key
+	var fingerprint, fingerprintHash string
+	if len(binding) == 0 {
+		var err error
+		fingerprint, fingerprintHash, err = newFingerprint()
+		if err != nil {
+			return nil, db.CreateSessionParams{}, err
+		}
+		// macaroons are meant to be attenuated and handed to services that never see the cookie,
+		// their caveats restrict them instead and only the refresh token stays bound to it
+		if _, macaroon := server.tokenMaker.(*token.MacaroonMaker); !macaroon {
+			opts = append(opts, token.WithFingerprint(fingerprintHash))
+		}
+	}
+	accessToken, err := server.tokenMaker.CreateToken(user.ID, server.config.TokenDuration, opts...)
+	if err != nil {
+		return nil, db.CreateSessionParams{}, err
+	}
+
+	refreshToken, err := utils.RandomSecureToken(refreshTokenSize)
+	if err != nil {
+		return nil, db.CreateSessionParams{}, err
+	}
+
+	if familyId == "" {
+		familyId = uuid.New().String()
+	}
+
+	arg := db.CreateSessionParams{
+		ID:               uuid.New().String(),
+		UserID:           user.ID,
+		FamilyID:         familyId,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		UserAgent:        ctx.Request.UserAgent(),
+		ClientIp:         ctx.ClientIP(),
+		ExpiresAt:        time.Now().Add(server.config.RefreshTokenDuration),
+		DpopJkt:          ctx.GetString(dpopKeyKey),
+		FingerprintHash:  fingerprintHash,
+	}
+	return &AuthResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    tokenType,
+		fingerprint:  fingerprint,
+	}, arg, nil
+}
+
+// RefreshToken rotate a refresh token, presenting an already rotated token
+// revoke the whole family since one of the copies must have been stolen
+func (server *Server) RefreshToken(ctx *gin.Context) {
+
+	var req RefreshTokenRequest
+
+	err := ctx.ShouldBindJSON(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	session, err := server.store.GetSessionByRefreshToken(ctx, utils.HashToken(req.RefreshToken))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusUnauthorized, errResponse(fmt.Errorf("invalid refresh token !")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	if session.IsRevoked {
+		ctx.JSON(http.StatusUnauthorized, errResponse(fmt.Errorf("session has been revoked !")))
+		return
+	}
+
+	if session.RotatedAt.Valid {
+		server.revokeFamily(ctx, session.FamilyID)
+		return
+	}
+
+	if time.Now().After(session.ExpiresAt) {
+		ctx.JSON(http.StatusUnauthorized, errResponse(fmt.Errorf("refresh token has been expired !")))
+		return
+	}
+
+	// a refresh token bound to a DPoP key can only be used with a proof of that key
+	if session.DpopJkt != "" && session.DpopJkt != ctx.GetString(dpopKeyKey) {
+		ctx.JSON(http.StatusUnauthorized, errResponse(fmt.Errorf("refresh token is bound to another DPoP key !")))
+		return
+	}
+
+	// like the access tokens, a refresh token issued to a browser only works along its fingerprint cookie
+	err = matchFingerprint(ctx, session.FingerprintHash)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errResponse(err))
+		return
+	}
+
+	// load the user again so roles changes are picked up on refresh
+	user, err := server.store.Me(ctx, session.UserID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	response, arg, err := server.newSession(ctx, user, session.FamilyID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+
+	// only one concurrent request can win the rotation, the others are reuses,
+	// the new session is only stored along with the rotation
+	_, err = server.store.RefreshSessionTx(ctx, db.RefreshSessionTxParams{
+		SessionID:  session.ID,
+		NewSession: arg,
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			server.revokeFamily(ctx, session.FamilyID)
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	setFingerprint(ctx, response)
+	ctx.JSON(http.StatusOK, response)
+}
+
+func (server *Server) revokeFamily(ctx *gin.Context, familyId string) {
+	err := server.store.RevokeSessionFamily(ctx, familyId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, errResponse(errRefreshTokenReused))
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func createSession(userId string, refreshToken string) db.Session {
+	return db.Session{
+		ID:               uuid.New().String(),
+		UserID:           userId,
+		FamilyID:         uuid.New().String(),
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(time.Hour),
+		CreatedAt:        time.Now(),
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+
+	user, _ := CreateUser(t)
+	user.Roles = []string{"admin"}
+	refreshToken := utils.RandomString(43)
+	session := createSession(user.ID, refreshToken)
+	fingerprint := utils.RandomString(43)
+	session.FingerprintHash = utils.HashToken(fingerprint)
+
+	testCases := []struct {
+		name          string
+		body          gin.H
+		fingerprint   string
+		buildStabs    func(store *mockdb.MockStore)
+		checkResponse func(recorder *httptest.ResponseRecorder, server *Server)
+	}{
+		{
+			name: "OK",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			fingerprint: fingerprint,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(session.RefreshTokenHash)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					DoAndReturn(func(_ context.Context, arg db.RefreshSessionTxParams) (db.Session, error) {
+						require.Equal(t, session.ID, arg.SessionID)
+						require.Equal(t, session.UserID, arg.NewSession.UserID)
+						require.Equal(t, session.FamilyID, arg.NewSession.FamilyID)
+						require.NotEqual(t, session.RefreshTokenHash, arg.NewSession.RefreshTokenHash)
+						require.NotEmpty(t, arg.NewSession.FingerprintHash)
+						require.NotEqual(t, session.FingerprintHash, arg.NewSession.FingerprintHash)
+						return db.Session{}, nil
+					})
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusOK, recorder.Code)
+				payload := checkBodyMatch(t, recorder.Body, server, user.ID)
+				require.Equal(t, []string{"admin"}, payload.Roles)
+				require.True(t, payload.HasScopes(scopeAdminUsersRead, scopeAdminUsersWrite))
+			},
+		},
+		{
+			name: "BadRequest",
+			body: gin.H{},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusBadRequest, recorder.Code)
+			},
+		},
+		{
+			name: "NotFound",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Revoked",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				revoked := session
+				revoked.IsRevoked = true
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(revoked, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Expired",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				expired := session
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(expired, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "Reused",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				rotated := session
+				rotated.RotatedAt = sql.NullTime{Time: time.Now(), Valid: true}
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(rotated, nil)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).
+					Times(1).
+					Return(nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "ConcurrentRotation",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			fingerprint: fingerprint,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrNoRows)
+				store.EXPECT().RevokeSessionFamily(gomock.Any(), gomock.Eq(session.FamilyID)).
+					Times(1).
+					Return(nil)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				// the browser keeps the cookie of the session that won the race
+				require.Empty(t, recorder.Result().Cookies())
+			},
+		},
+		{
+			name: "RotationFailed",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			fingerprint: fingerprint,
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().Me(gomock.Any(), gomock.Eq(user.ID)).
+					Times(1).
+					Return(user, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+				// the old session is still valid so its cookie must be kept
+				require.Empty(t, recorder.Result().Cookies())
+			},
+		},
+		{
+			name: "StolenWithoutFingerprint",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(session.RefreshTokenHash)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+				require.Contains(t, recorder.Body.String(), errFingerprintMismatch.Error())
+				require.Empty(t, recorder.Result().Cookies())
+			},
+		},
+		{
+			name: "WrongFingerprint",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			fingerprint: utils.RandomString(43),
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Eq(session.RefreshTokenHash)).
+					Times(1).
+					Return(session, nil)
+				store.EXPECT().RefreshSessionTx(gomock.Any(), gomock.Any()).
+					Times(0)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusUnauthorized, recorder.Code)
+			},
+		},
+		{
+			name: "InternalError",
+			body: gin.H{
+				"refresh_token": refreshToken,
+			},
+			buildStabs: func(store *mockdb.MockStore) {
+				store.EXPECT().GetSessionByRefreshToken(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, sql.ErrConnDone)
+			},
+			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
+				require.Equal(t, http.StatusInternalServerError, recorder.Code)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			store := mockdb.NewMockStore(ctrl)
+			tc.buildStabs(store)
+
+			data, err := json.Marshal(tc.body)
+			require.NoError(t, err)
+
+			url := "/token/refresh"
+			request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+			require.NoError(t, err)
+			if tc.fingerprint != "" {
+				request.AddCookie(&http.Cookie{Name: fingerprintCookieName, Value: tc.fingerprint})
+			}
+
+			server := newTestServer(t, store)
+			recorder := httptest.NewRecorder()
+
+			server.router.ServeHTTP(recorder, request)
+			tc.checkResponse(recorder, server)
+		})
+	}
+}
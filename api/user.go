@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"net/http"
-	"time"
 
 	db "github.com/brkss/go-auth/db/sqlc"
 	"github.com/brkss/go-auth/token"
@@ -27,7 +26,12 @@ type RegisterRequest struct {
 }
 
 type AuthResponse struct {
-	AccessToken string `json:"access_token"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// TokenType is "DPoP" when the tokens are bound to the request DPoP key, "Bearer" otherwise
+	TokenType string `json:"token_type"`
+	// fingerprint is the cookie of bearer tokens, only set once the session is stored
+	fingerprint string
 }
 
 type UserResponse struct {
@@ -62,14 +66,11 @@ func (server *Server) Login(ctx *gin.Context) {
 		return
 	}
 
-	token, err := server.tokenMaker.CreateToken(user.ID, time.Hour)
+	response, err := server.createSession(ctx, user, "")
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errResponse(err))
 		return
 	}
-	response := AuthResponse{
-		AccessToken: token,
-	}
 	ctx.JSON(http.StatusOK, response)
 }
 
@@ -112,13 +113,10 @@ func (server *Server) Register(ctx *gin.Context) {
 		return
 	}
 
-	token, err := server.tokenMaker.CreateToken(user.ID, server.config.TokenDuration)
+	response, err := server.createSession(ctx, user, "")
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errResponse(err))
-	}
-
-	response := AuthResponse{
-		AccessToken: token,
+		return
 	}
 	ctx.JSON(http.StatusOK, response)
 	return
@@ -141,8 +139,59 @@ func (server *Server) Me(ctx *gin.Context) {
 		return
 	}
 
+	if payload.IsClient() {
+		client, err := server.store.GetClient(ctx, payload.ClientID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				ctx.JSON(http.StatusNotFound, errResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, errResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusOK, ClientResponse{ClientID: client.ID, Name: client.Name, Scopes: client.Scopes})
+		return
+	}
+
+	user, ok := server.currentUser(ctx, payload)
+	if !ok {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, CreateUserResponse(user))
+}
+
+// currentUser look up the user the token of Me and UserInfo was issued for
+func (server *Server) currentUser(ctx *gin.Context, payload *token.Payload) (db.User, bool) {
 	user, err := server.store.Me(ctx, payload.UserId)
 	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errResponse(err))
+		return db.User{}, false
+	}
+	return user, true
+}
+
+type GetUserRequest struct {
+	Username string `uri:"username" binding:"required"`
+}
+
+// GetUser let admins look up any user by username or email
+func (server *Server) GetUser(ctx *gin.Context) {
+
+	var req GetUserRequest
+
+	err := ctx.ShouldBindUri(&req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errResponse(err))
+		return
+	}
+
+	user, err := server.store.GetUser(ctx, req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			ctx.JSON(http.StatusNotFound, errResponse(err))
+			return
+		}
 		ctx.JSON(http.StatusInternalServerError, errResponse(err))
 		return
 	}
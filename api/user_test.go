@@ -99,6 +99,10 @@ func TestRegisterUser(t *testing.T) {
 					CreateUser(gomock.Any(), EqCreateUserParams(arg, password)).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().
+					CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -188,6 +192,9 @@ func TestLoginUser(t *testing.T) {
 				store.EXPECT().GetUser(gomock.Any(), username).
 					Times(1).
 					Return(user, nil)
+				store.EXPECT().CreateSession(gomock.Any(), gomock.Any()).
+					Times(1).
+					Return(db.Session{}, nil)
 			},
 			checkResponse: func(recorder *httptest.ResponseRecorder, server *Server) {
 				require.Equal(t, http.StatusOK, recorder.Code)
@@ -383,7 +390,7 @@ func TestMeEP(t *testing.T) {
 
 }
 
-func checkBodyMatch(t *testing.T, body *bytes.Buffer, server *Server, userId string) {
+func checkBodyMatch(t *testing.T, body *bytes.Buffer, server *Server, userId string) *token.Payload {
 	data, err := ioutil.ReadAll(body)
 	require.NoError(t, err)
 
@@ -392,11 +399,12 @@ func checkBodyMatch(t *testing.T, body *bytes.Buffer, server *Server, userId str
 	require.NoError(t, err)
 
 	require.NotZero(t, response.AccessToken)
+	require.NotZero(t, response.RefreshToken)
 
 	payload, err := server.tokenMaker.VerifyToken(response.AccessToken)
 	require.NoError(t, err)
 	require.NotEmpty(t, payload)
 
 	require.Equal(t, payload.UserId, userId)
-
+	return payload
 }
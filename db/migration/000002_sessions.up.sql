@@ -0,0 +1,16 @@
+CREATE TABLE "sessions" (
+  "id" varchar PRIMARY KEY,
+  "user_id" varchar NOT NULL,
+  "family_id" varchar NOT NULL,
+  "refresh_token_hash" varchar UNIQUE NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "is_revoked" boolean NOT NULL DEFAULT false,
+  "rotated_at" timestamptz,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "sessions" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+CREATE INDEX ON "sessions" ("family_id");
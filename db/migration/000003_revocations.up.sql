@@ -0,0 +1,16 @@
+CREATE TABLE "revoked_tokens" (
+  "id" varchar PRIMARY KEY,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE "revoked_users" (
+  "user_id" varchar PRIMARY KEY,
+  "revoked_before" timestamptz NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "revoked_tokens" ("expires_at");
+
+CREATE INDEX ON "revoked_users" ("expires_at");
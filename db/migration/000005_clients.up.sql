@@ -0,0 +1,7 @@
+CREATE TABLE "clients" (
+  "id" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "secret_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
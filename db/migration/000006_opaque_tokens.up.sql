@@ -0,0 +1,8 @@
+CREATE TABLE "opaque_tokens" (
+  "token_hash" varchar PRIMARY KEY,
+  "payload" jsonb NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "opaque_tokens" ("expires_at");
@@ -0,0 +1 @@
+ALTER TABLE "sessions" ADD COLUMN "dpop_jkt" varchar NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE "clients" ADD COLUMN "exchange_audiences" varchar[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,20 @@
+ALTER TABLE "clients" ADD COLUMN "redirect_uris" varchar[] NOT NULL DEFAULT '{}';
+ALTER TABLE "clients" ADD COLUMN "public" boolean NOT NULL DEFAULT false;
+
+CREATE TABLE "authorization_codes" (
+  "code_hash" varchar PRIMARY KEY,
+  "client_id" varchar NOT NULL,
+  "user_id" varchar NOT NULL,
+  "redirect_uri" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "code_challenge" varchar NOT NULL,
+  "nonce" varchar NOT NULL,
+  "used_at" timestamptz,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id");
+ALTER TABLE "authorization_codes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+CREATE INDEX ON "authorization_codes" ("expires_at");
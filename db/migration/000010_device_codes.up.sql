@@ -0,0 +1,17 @@
+CREATE TABLE "device_codes" (
+  "device_code_hash" varchar PRIMARY KEY,
+  "user_code" varchar UNIQUE NOT NULL,
+  "client_id" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL DEFAULT '{}',
+  "status" varchar NOT NULL DEFAULT 'pending',
+  "user_id" varchar,
+  "poll_interval" integer NOT NULL,
+  "polled_at" timestamptz,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "device_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id");
+ALTER TABLE "device_codes" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id");
+
+CREATE INDEX ON "device_codes" ("expires_at");
@@ -0,0 +1,9 @@
+ALTER TABLE "device_codes" DROP CONSTRAINT "device_codes_client_id_fkey";
+ALTER TABLE "device_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id");
+ALTER TABLE "authorization_codes" DROP CONSTRAINT "authorization_codes_client_id_fkey";
+ALTER TABLE "authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id");
+
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "updated_at";
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "registration_token_hash";
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "token_endpoint_auth_method";
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "grant_types";
@@ -0,0 +1,10 @@
+ALTER TABLE "clients" ADD COLUMN "grant_types" varchar[] NOT NULL DEFAULT '{}';
+ALTER TABLE "clients" ADD COLUMN "token_endpoint_auth_method" varchar NOT NULL DEFAULT '';
+ALTER TABLE "clients" ADD COLUMN "registration_token_hash" varchar NOT NULL DEFAULT '';
+ALTER TABLE "clients" ADD COLUMN "updated_at" timestamptz NOT NULL DEFAULT (now());
+
+-- deleting a client drops its pending codes
+ALTER TABLE "authorization_codes" DROP CONSTRAINT "authorization_codes_client_id_fkey";
+ALTER TABLE "authorization_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id") ON DELETE CASCADE;
+ALTER TABLE "device_codes" DROP CONSTRAINT "device_codes_client_id_fkey";
+ALTER TABLE "device_codes" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id") ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS logout_deliveries;
+DROP TABLE IF EXISTS client_logins;
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "backchannel_logout_uri";
+ALTER TABLE "clients" DROP COLUMN IF EXISTS "post_logout_redirect_uris";
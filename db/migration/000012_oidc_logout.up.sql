@@ -0,0 +1,27 @@
+ALTER TABLE "clients" ADD COLUMN "post_logout_redirect_uris" varchar[] NOT NULL DEFAULT '{}';
+ALTER TABLE "clients" ADD COLUMN "backchannel_logout_uri" varchar NOT NULL DEFAULT '';
+
+-- relying parties a user signed in to, they are told when the user logs out
+CREATE TABLE "client_logins" (
+  "user_id" varchar NOT NULL,
+  "client_id" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("user_id", "client_id")
+);
+
+ALTER TABLE "client_logins" ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+ALTER TABLE "client_logins" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id") ON DELETE CASCADE;
+
+-- back-channel logout tokens waiting to be delivered, retried until attempts run out
+CREATE TABLE "logout_deliveries" (
+  "id" bigserial PRIMARY KEY,
+  "client_id" varchar NOT NULL,
+  "user_id" varchar NOT NULL,
+  "attempts" int NOT NULL DEFAULT 0,
+  "next_attempt_at" timestamptz NOT NULL DEFAULT (now()),
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+ALTER TABLE "logout_deliveries" ADD FOREIGN KEY ("client_id") REFERENCES "clients" ("id") ON DELETE CASCADE;
+
+CREATE INDEX ON "logout_deliveries" ("next_attempt_at");
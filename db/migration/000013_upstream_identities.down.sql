@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "users_upstream_identity_key";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "upstream_subject";
+ALTER TABLE "users" DROP COLUMN IF EXISTS "upstream_provider";
@@ -0,0 +1,5 @@
+-- users created at their first login with an upstream OpenID provider, password users have none
+ALTER TABLE "users" ADD COLUMN "upstream_provider" varchar NOT NULL DEFAULT '';
+ALTER TABLE "users" ADD COLUMN "upstream_subject" varchar NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX "users_upstream_identity_key" ON "users" ("upstream_provider", "upstream_subject") WHERE "upstream_provider" <> '';
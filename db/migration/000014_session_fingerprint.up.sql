@@ -0,0 +1 @@
+ALTER TABLE "sessions" ADD COLUMN "fingerprint_hash" varchar NOT NULL DEFAULT '';
@@ -35,6 +35,124 @@ func (m *MockStore) EXPECT() *MockStoreMockRecorder {
 	return m.recorder
 }
 
+// ApproveDeviceCode mocks base method.
+func (m *MockStore) ApproveDeviceCode(arg0 context.Context, arg1 db.ApproveDeviceCodeParams) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ApproveDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ApproveDeviceCode indicates an expected call of ApproveDeviceCode.
+func (mr *MockStoreMockRecorder) ApproveDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ApproveDeviceCode", reflect.TypeOf((*MockStore)(nil).ApproveDeviceCode), arg0, arg1)
+}
+
+// ClaimLogoutDeliveries mocks base method.
+func (m *MockStore) ClaimLogoutDeliveries(arg0 context.Context, arg1 db.ClaimLogoutDeliveriesParams) ([]db.LogoutDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimLogoutDeliveries", arg0, arg1)
+	ret0, _ := ret[0].([]db.LogoutDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimLogoutDeliveries indicates an expected call of ClaimLogoutDeliveries.
+func (mr *MockStoreMockRecorder) ClaimLogoutDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimLogoutDeliveries", reflect.TypeOf((*MockStore)(nil).ClaimLogoutDeliveries), arg0, arg1)
+}
+
+// CreateAuthorizationCode mocks base method.
+func (m *MockStore) CreateAuthorizationCode(arg0 context.Context, arg1 db.CreateAuthorizationCodeParams) (db.AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateAuthorizationCode", arg0, arg1)
+	ret0, _ := ret[0].(db.AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateAuthorizationCode indicates an expected call of CreateAuthorizationCode.
+func (mr *MockStoreMockRecorder) CreateAuthorizationCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateAuthorizationCode", reflect.TypeOf((*MockStore)(nil).CreateAuthorizationCode), arg0, arg1)
+}
+
+// CreateClient mocks base method.
+func (m *MockStore) CreateClient(arg0 context.Context, arg1 db.CreateClientParams) (db.Client, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateClient", arg0, arg1)
+	ret0, _ := ret[0].(db.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateClient indicates an expected call of CreateClient.
+func (mr *MockStoreMockRecorder) CreateClient(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateClient", reflect.TypeOf((*MockStore)(nil).CreateClient), arg0, arg1)
+}
+
+// CreateClientLogin mocks base method.
+func (m *MockStore) CreateClientLogin(arg0 context.Context, arg1 db.CreateClientLoginParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateClientLogin", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateClientLogin indicates an expected call of CreateClientLogin.
+func (mr *MockStoreMockRecorder) CreateClientLogin(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateClientLogin", reflect.TypeOf((*MockStore)(nil).CreateClientLogin), arg0, arg1)
+}
+
+// CreateDeviceCode mocks base method.
+func (m *MockStore) CreateDeviceCode(arg0 context.Context, arg1 db.CreateDeviceCodeParams) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateDeviceCode indicates an expected call of CreateDeviceCode.
+func (mr *MockStoreMockRecorder) CreateDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateDeviceCode", reflect.TypeOf((*MockStore)(nil).CreateDeviceCode), arg0, arg1)
+}
+
+// CreateOpaqueToken mocks base method.
+func (m *MockStore) CreateOpaqueToken(arg0 context.Context, arg1 db.CreateOpaqueTokenParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateOpaqueToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateOpaqueToken indicates an expected call of CreateOpaqueToken.
+func (mr *MockStoreMockRecorder) CreateOpaqueToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateOpaqueToken", reflect.TypeOf((*MockStore)(nil).CreateOpaqueToken), arg0, arg1)
+}
+
+// CreateSession mocks base method.
+func (m *MockStore) CreateSession(arg0 context.Context, arg1 db.CreateSessionParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSession indicates an expected call of CreateSession.
+func (mr *MockStoreMockRecorder) CreateSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSession", reflect.TypeOf((*MockStore)(nil).CreateSession), arg0, arg1)
+}
+
 // CreateUser mocks base method.
 func (m *MockStore) CreateUser(arg0 context.Context, arg1 db.CreateUserParams) (db.User, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +168,208 @@ func (mr *MockStoreMockRecorder) CreateUser(arg0, arg1 interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockStore)(nil).CreateUser), arg0, arg1)
 }
 
+// DeleteClient mocks base method.
+func (m *MockStore) DeleteClient(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteClient", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteClient indicates an expected call of DeleteClient.
+func (mr *MockStoreMockRecorder) DeleteClient(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteClient", reflect.TypeOf((*MockStore)(nil).DeleteClient), arg0, arg1)
+}
+
+// DeleteExpiredAuthorizationCodes mocks base method.
+func (m *MockStore) DeleteExpiredAuthorizationCodes(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredAuthorizationCodes", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredAuthorizationCodes indicates an expected call of DeleteExpiredAuthorizationCodes.
+func (mr *MockStoreMockRecorder) DeleteExpiredAuthorizationCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredAuthorizationCodes", reflect.TypeOf((*MockStore)(nil).DeleteExpiredAuthorizationCodes), arg0)
+}
+
+// DeleteExpiredDeviceCodes mocks base method.
+func (m *MockStore) DeleteExpiredDeviceCodes(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredDeviceCodes", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredDeviceCodes indicates an expected call of DeleteExpiredDeviceCodes.
+func (mr *MockStoreMockRecorder) DeleteExpiredDeviceCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredDeviceCodes", reflect.TypeOf((*MockStore)(nil).DeleteExpiredDeviceCodes), arg0)
+}
+
+// DeleteExpiredOpaqueTokens mocks base method.
+func (m *MockStore) DeleteExpiredOpaqueTokens(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredOpaqueTokens", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredOpaqueTokens indicates an expected call of DeleteExpiredOpaqueTokens.
+func (mr *MockStoreMockRecorder) DeleteExpiredOpaqueTokens(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredOpaqueTokens", reflect.TypeOf((*MockStore)(nil).DeleteExpiredOpaqueTokens), arg0)
+}
+
+// DeleteExpiredRevokedTokens mocks base method.
+func (m *MockStore) DeleteExpiredRevokedTokens(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevokedTokens", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredRevokedTokens indicates an expected call of DeleteExpiredRevokedTokens.
+func (mr *MockStoreMockRecorder) DeleteExpiredRevokedTokens(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevokedTokens", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRevokedTokens), arg0)
+}
+
+// DeleteExpiredRevokedUsers mocks base method.
+func (m *MockStore) DeleteExpiredRevokedUsers(arg0 context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredRevokedUsers", arg0)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteExpiredRevokedUsers indicates an expected call of DeleteExpiredRevokedUsers.
+func (mr *MockStoreMockRecorder) DeleteExpiredRevokedUsers(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredRevokedUsers", reflect.TypeOf((*MockStore)(nil).DeleteExpiredRevokedUsers), arg0)
+}
+
+// DeleteLogoutDelivery mocks base method.
+func (m *MockStore) DeleteLogoutDelivery(arg0 context.Context, arg1 int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteLogoutDelivery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteLogoutDelivery indicates an expected call of DeleteLogoutDelivery.
+func (mr *MockStoreMockRecorder) DeleteLogoutDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteLogoutDelivery", reflect.TypeOf((*MockStore)(nil).DeleteLogoutDelivery), arg0, arg1)
+}
+
+// DeleteOpaqueToken mocks base method.
+func (m *MockStore) DeleteOpaqueToken(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteOpaqueToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteOpaqueToken indicates an expected call of DeleteOpaqueToken.
+func (mr *MockStoreMockRecorder) DeleteOpaqueToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteOpaqueToken", reflect.TypeOf((*MockStore)(nil).DeleteOpaqueToken), arg0, arg1)
+}
+
+// DenyDeviceCode mocks base method.
+func (m *MockStore) DenyDeviceCode(arg0 context.Context, arg1 string) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DenyDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DenyDeviceCode indicates an expected call of DenyDeviceCode.
+func (mr *MockStoreMockRecorder) DenyDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DenyDeviceCode", reflect.TypeOf((*MockStore)(nil).DenyDeviceCode), arg0, arg1)
+}
+
+// GetClient mocks base method.
+func (m *MockStore) GetClient(arg0 context.Context, arg1 string) (db.Client, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetClient", arg0, arg1)
+	ret0, _ := ret[0].(db.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetClient indicates an expected call of GetClient.
+func (mr *MockStoreMockRecorder) GetClient(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetClient", reflect.TypeOf((*MockStore)(nil).GetClient), arg0, arg1)
+}
+
+// GetDeviceCode mocks base method.
+func (m *MockStore) GetDeviceCode(arg0 context.Context, arg1 string) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceCode indicates an expected call of GetDeviceCode.
+func (mr *MockStoreMockRecorder) GetDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceCode", reflect.TypeOf((*MockStore)(nil).GetDeviceCode), arg0, arg1)
+}
+
+// GetDeviceCodeByUserCode mocks base method.
+func (m *MockStore) GetDeviceCodeByUserCode(arg0 context.Context, arg1 string) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeviceCodeByUserCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeviceCodeByUserCode indicates an expected call of GetDeviceCodeByUserCode.
+func (mr *MockStoreMockRecorder) GetDeviceCodeByUserCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeviceCodeByUserCode", reflect.TypeOf((*MockStore)(nil).GetDeviceCodeByUserCode), arg0, arg1)
+}
+
+// GetOpaqueToken mocks base method.
+func (m *MockStore) GetOpaqueToken(arg0 context.Context, arg1 string) (db.OpaqueToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetOpaqueToken", arg0, arg1)
+	ret0, _ := ret[0].(db.OpaqueToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetOpaqueToken indicates an expected call of GetOpaqueToken.
+func (mr *MockStoreMockRecorder) GetOpaqueToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetOpaqueToken", reflect.TypeOf((*MockStore)(nil).GetOpaqueToken), arg0, arg1)
+}
+
+// GetSessionByRefreshToken mocks base method.
+func (m *MockStore) GetSessionByRefreshToken(arg0 context.Context, arg1 string) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSessionByRefreshToken", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSessionByRefreshToken indicates an expected call of GetSessionByRefreshToken.
+func (mr *MockStoreMockRecorder) GetSessionByRefreshToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSessionByRefreshToken", reflect.TypeOf((*MockStore)(nil).GetSessionByRefreshToken), arg0, arg1)
+}
+
 // GetUser mocks base method.
 func (m *MockStore) GetUser(arg0 context.Context, arg1 string) (db.User, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +385,36 @@ func (mr *MockStoreMockRecorder) GetUser(arg0, arg1 interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUser", reflect.TypeOf((*MockStore)(nil).GetUser), arg0, arg1)
 }
 
+// GetUserByUpstreamIdentity mocks base method.
+func (m *MockStore) GetUserByUpstreamIdentity(arg0 context.Context, arg1 db.GetUserByUpstreamIdentityParams) (db.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByUpstreamIdentity", arg0, arg1)
+	ret0, _ := ret[0].(db.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByUpstreamIdentity indicates an expected call of GetUserByUpstreamIdentity.
+func (mr *MockStoreMockRecorder) GetUserByUpstreamIdentity(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUpstreamIdentity", reflect.TypeOf((*MockStore)(nil).GetUserByUpstreamIdentity), arg0, arg1)
+}
+
+// IsTokenRevoked mocks base method.
+func (m *MockStore) IsTokenRevoked(arg0 context.Context, arg1 db.IsTokenRevokedParams) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTokenRevoked", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsTokenRevoked indicates an expected call of IsTokenRevoked.
+func (mr *MockStoreMockRecorder) IsTokenRevoked(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTokenRevoked", reflect.TypeOf((*MockStore)(nil).IsTokenRevoked), arg0, arg1)
+}
+
 // Me mocks base method.
 func (m *MockStore) Me(arg0 context.Context, arg1 string) (db.User, error) {
 	m.ctrl.T.Helper()
@@ -79,3 +429,176 @@ func (mr *MockStoreMockRecorder) Me(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Me", reflect.TypeOf((*MockStore)(nil).Me), arg0, arg1)
 }
+
+// PollDeviceCode mocks base method.
+func (m *MockStore) PollDeviceCode(arg0 context.Context, arg1 db.PollDeviceCodeParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PollDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PollDeviceCode indicates an expected call of PollDeviceCode.
+func (mr *MockStoreMockRecorder) PollDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PollDeviceCode", reflect.TypeOf((*MockStore)(nil).PollDeviceCode), arg0, arg1)
+}
+
+// QueueLogoutDeliveries mocks base method.
+func (m *MockStore) QueueLogoutDeliveries(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueLogoutDeliveries", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// QueueLogoutDeliveries indicates an expected call of QueueLogoutDeliveries.
+func (mr *MockStoreMockRecorder) QueueLogoutDeliveries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueLogoutDeliveries", reflect.TypeOf((*MockStore)(nil).QueueLogoutDeliveries), arg0, arg1)
+}
+
+// RefreshSessionTx mocks base method.
+func (m *MockStore) RefreshSessionTx(arg0 context.Context, arg1 db.RefreshSessionTxParams) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RefreshSessionTx", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RefreshSessionTx indicates an expected call of RefreshSessionTx.
+func (mr *MockStoreMockRecorder) RefreshSessionTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RefreshSessionTx", reflect.TypeOf((*MockStore)(nil).RefreshSessionTx), arg0, arg1)
+}
+
+// RetryLogoutDelivery mocks base method.
+func (m *MockStore) RetryLogoutDelivery(arg0 context.Context, arg1 db.RetryLogoutDeliveryParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RetryLogoutDelivery", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RetryLogoutDelivery indicates an expected call of RetryLogoutDelivery.
+func (mr *MockStoreMockRecorder) RetryLogoutDelivery(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RetryLogoutDelivery", reflect.TypeOf((*MockStore)(nil).RetryLogoutDelivery), arg0, arg1)
+}
+
+// RevokeSessionFamily mocks base method.
+func (m *MockStore) RevokeSessionFamily(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSessionFamily", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSessionFamily indicates an expected call of RevokeSessionFamily.
+func (mr *MockStoreMockRecorder) RevokeSessionFamily(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSessionFamily", reflect.TypeOf((*MockStore)(nil).RevokeSessionFamily), arg0, arg1)
+}
+
+// RevokeToken mocks base method.
+func (m *MockStore) RevokeToken(arg0 context.Context, arg1 db.RevokeTokenParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeToken", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeToken indicates an expected call of RevokeToken.
+func (mr *MockStoreMockRecorder) RevokeToken(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeToken", reflect.TypeOf((*MockStore)(nil).RevokeToken), arg0, arg1)
+}
+
+// RevokeUserSessions mocks base method.
+func (m *MockStore) RevokeUserSessions(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserSessions", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserSessions indicates an expected call of RevokeUserSessions.
+func (mr *MockStoreMockRecorder) RevokeUserSessions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserSessions", reflect.TypeOf((*MockStore)(nil).RevokeUserSessions), arg0, arg1)
+}
+
+// RevokeUserTokens mocks base method.
+func (m *MockStore) RevokeUserTokens(arg0 context.Context, arg1 db.RevokeUserTokensParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeUserTokens", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeUserTokens indicates an expected call of RevokeUserTokens.
+func (mr *MockStoreMockRecorder) RevokeUserTokens(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeUserTokens", reflect.TypeOf((*MockStore)(nil).RevokeUserTokens), arg0, arg1)
+}
+
+// RotateSession mocks base method.
+func (m *MockStore) RotateSession(arg0 context.Context, arg1 string) (db.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateSession", arg0, arg1)
+	ret0, _ := ret[0].(db.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RotateSession indicates an expected call of RotateSession.
+func (mr *MockStoreMockRecorder) RotateSession(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateSession", reflect.TypeOf((*MockStore)(nil).RotateSession), arg0, arg1)
+}
+
+// UpdateClient mocks base method.
+func (m *MockStore) UpdateClient(arg0 context.Context, arg1 db.UpdateClientParams) (db.Client, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateClient", arg0, arg1)
+	ret0, _ := ret[0].(db.Client)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateClient indicates an expected call of UpdateClient.
+func (mr *MockStoreMockRecorder) UpdateClient(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateClient", reflect.TypeOf((*MockStore)(nil).UpdateClient), arg0, arg1)
+}
+
+// UseAuthorizationCode mocks base method.
+func (m *MockStore) UseAuthorizationCode(arg0 context.Context, arg1 string) (db.AuthorizationCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseAuthorizationCode", arg0, arg1)
+	ret0, _ := ret[0].(db.AuthorizationCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseAuthorizationCode indicates an expected call of UseAuthorizationCode.
+func (mr *MockStoreMockRecorder) UseAuthorizationCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseAuthorizationCode", reflect.TypeOf((*MockStore)(nil).UseAuthorizationCode), arg0, arg1)
+}
+
+// UseDeviceCode mocks base method.
+func (m *MockStore) UseDeviceCode(arg0 context.Context, arg1 string) (db.DeviceCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UseDeviceCode", arg0, arg1)
+	ret0, _ := ret[0].(db.DeviceCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UseDeviceCode indicates an expected call of UseDeviceCode.
+func (mr *MockStoreMockRecorder) UseDeviceCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UseDeviceCode", reflect.TypeOf((*MockStore)(nil).UseDeviceCode), arg0, arg1)
+}
@@ -0,0 +1,17 @@
+-- name: CreateAuthorizationCode :one
+INSERT INTO authorization_codes (
+	code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, expires_at
+)VALUES(
+	$1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: UseAuthorizationCode :one
+UPDATE authorization_codes
+SET used_at = now()
+WHERE code_hash = $1
+AND used_at IS NULL
+RETURNING *;
+
+-- name: DeleteExpiredAuthorizationCodes :exec
+DELETE FROM authorization_codes
+WHERE expires_at < now();
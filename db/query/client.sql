@@ -0,0 +1,30 @@
+-- name: CreateClient :one
+INSERT INTO clients (
+	id, name, secret_hash, scopes, exchange_audiences, redirect_uris, public,
+	grant_types, token_endpoint_auth_method, registration_token_hash,
+	post_logout_redirect_uris, backchannel_logout_uri
+)VALUES(
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING *;
+
+-- name: GetClient :one
+SELECT * FROM clients
+WHERE id = $1
+LIMIT 1;
+
+-- name: UpdateClient :one
+UPDATE clients
+SET name = $2,
+	scopes = $3,
+	redirect_uris = $4,
+	grant_types = $5,
+	token_endpoint_auth_method = $6,
+	post_logout_redirect_uris = $7,
+	backchannel_logout_uri = $8,
+	updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: DeleteClient :exec
+DELETE FROM clients
+WHERE id = $1;
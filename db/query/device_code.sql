@@ -0,0 +1,48 @@
+-- name: CreateDeviceCode :one
+INSERT INTO device_codes (
+	device_code_hash, user_code, client_id, scopes, poll_interval, expires_at
+)VALUES(
+	$1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetDeviceCode :one
+SELECT * FROM device_codes
+WHERE device_code_hash = $1
+LIMIT 1;
+
+-- name: GetDeviceCodeByUserCode :one
+SELECT * FROM device_codes
+WHERE user_code = $1
+LIMIT 1;
+
+-- name: PollDeviceCode :exec
+UPDATE device_codes
+SET polled_at = now(), poll_interval = $2
+WHERE device_code_hash = $1;
+
+-- name: ApproveDeviceCode :one
+UPDATE device_codes
+SET status = 'approved', user_id = $2
+WHERE user_code = $1
+AND status = 'pending'
+AND expires_at > now()
+RETURNING *;
+
+-- name: DenyDeviceCode :one
+UPDATE device_codes
+SET status = 'denied'
+WHERE user_code = $1
+AND status = 'pending'
+AND expires_at > now()
+RETURNING *;
+
+-- name: UseDeviceCode :one
+UPDATE device_codes
+SET status = 'used'
+WHERE device_code_hash = $1
+AND status = 'approved'
+RETURNING *;
+
+-- name: DeleteExpiredDeviceCodes :exec
+DELETE FROM device_codes
+WHERE expires_at < now();
@@ -0,0 +1,39 @@
+-- name: CreateClientLogin :exec
+INSERT INTO client_logins (
+	user_id, client_id
+)VALUES(
+	$1, $2
+) ON CONFLICT (user_id, client_id) DO UPDATE SET created_at = now();
+
+-- name: QueueLogoutDeliveries :exec
+WITH logins AS (
+	DELETE FROM client_logins
+	WHERE client_logins.user_id = $1
+	RETURNING client_id, user_id
+)
+INSERT INTO logout_deliveries (client_id, user_id)
+SELECT logins.client_id, logins.user_id FROM logins
+JOIN clients ON clients.id = logins.client_id
+WHERE clients.backchannel_logout_uri <> '';
+
+-- name: ClaimLogoutDeliveries :many
+UPDATE logout_deliveries
+SET attempts = attempts + 1,
+	next_attempt_at = sqlc.arg(lease_until)
+WHERE id IN (
+	SELECT id FROM logout_deliveries
+	WHERE next_attempt_at <= now()
+	ORDER BY next_attempt_at
+	LIMIT sqlc.arg(max_deliveries)
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RetryLogoutDelivery :exec
+UPDATE logout_deliveries
+SET next_attempt_at = $2
+WHERE id = $1;
+
+-- name: DeleteLogoutDelivery :exec
+DELETE FROM logout_deliveries
+WHERE id = $1;
@@ -0,0 +1,19 @@
+-- name: CreateOpaqueToken :exec
+INSERT INTO opaque_tokens (
+	token_hash, payload, expires_at
+)VALUES(
+	$1, $2, $3
+);
+
+-- name: GetOpaqueToken :one
+SELECT * FROM opaque_tokens
+WHERE token_hash = $1
+LIMIT 1;
+
+-- name: DeleteOpaqueToken :exec
+DELETE FROM opaque_tokens
+WHERE token_hash = $1;
+
+-- name: DeleteExpiredOpaqueTokens :exec
+DELETE FROM opaque_tokens
+WHERE expires_at < now();
@@ -0,0 +1,33 @@
+-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+	id, expires_at
+)VALUES(
+	$1, $2
+) ON CONFLICT (id) DO NOTHING;
+
+-- name: RevokeUserTokens :exec
+INSERT INTO revoked_users (
+	user_id, revoked_before, expires_at
+)VALUES(
+	$1, $2, $3
+) ON CONFLICT (user_id) DO UPDATE
+SET revoked_before = EXCLUDED.revoked_before,
+	expires_at = GREATEST(revoked_users.expires_at, EXCLUDED.expires_at);
+
+-- name: IsTokenRevoked :one
+SELECT (
+	EXISTS(SELECT 1 FROM revoked_tokens WHERE id = sqlc.arg(id))
+	OR EXISTS(
+		SELECT 1 FROM revoked_users
+		WHERE user_id = sqlc.arg(user_id)
+		AND revoked_before >= sqlc.arg(issued_at)
+	)
+)::boolean AS revoked;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now();
+
+-- name: DeleteExpiredRevokedUsers :exec
+DELETE FROM revoked_users
+WHERE expires_at < now();
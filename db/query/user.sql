@@ -1,8 +1,8 @@
 -- name: CreateUser :one
 INSERT INTO users (
-	id, username, email, password, name 
+	id, username, email, password, name, upstream_provider, upstream_subject
 )VALUES(
-	$1, $2, $3, $4, $5
+	$1, $2, $3, $4, $5, $6, $7
 ) RETURNING *;
 
 -- name: GetUser :one 
@@ -14,3 +14,10 @@ OR email = $1 LIMIT 1;
 SELECT * FROM users
 WHERE id = $1
 LIMIT 1;
+
+-- name: GetUserByUpstreamIdentity :one
+SELECT * FROM users
+WHERE upstream_provider = $1
+AND upstream_subject = $2
+AND upstream_provider <> ''
+LIMIT 1;
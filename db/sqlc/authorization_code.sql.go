@@ -0,0 +1,95 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: authorization_code.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const createAuthorizationCode = `-- name: CreateAuthorizationCode :one
+INSERT INTO authorization_codes (
+	code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, expires_at
+)VALUES(
+	$1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, used_at, expires_at, created_at
+`
+
+type CreateAuthorizationCodeParams struct {
+	CodeHash      string    `json:"code_hash"`
+	ClientID      string    `json:"client_id"`
+	UserID        string    `json:"user_id"`
+	RedirectUri   string    `json:"redirect_uri"`
+	Scopes        []string  `json:"scopes"`
+	CodeChallenge string    `json:"code_challenge"`
+	Nonce         string    `json:"nonce"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateAuthorizationCode(ctx context.Context, arg CreateAuthorizationCodeParams) (AuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, createAuthorizationCode,
+		arg.CodeHash,
+		arg.ClientID,
+		arg.UserID,
+		arg.RedirectUri,
+		pq.Array(arg.Scopes),
+		arg.CodeChallenge,
+		arg.Nonce,
+		arg.ExpiresAt,
+	)
+	var i AuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.ClientID,
+		&i.UserID,
+		&i.RedirectUri,
+		pq.Array(&i.Scopes),
+		&i.CodeChallenge,
+		&i.Nonce,
+		&i.UsedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredAuthorizationCodes = `-- name: DeleteExpiredAuthorizationCodes :exec
+DELETE FROM authorization_codes
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredAuthorizationCodes(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredAuthorizationCodes)
+	return err
+}
+
+const useAuthorizationCode = `-- name: UseAuthorizationCode :one
+UPDATE authorization_codes
+SET used_at = now()
+WHERE code_hash = $1
+AND used_at IS NULL
+RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, nonce, used_at, expires_at, created_at
+`
+
+func (q *Queries) UseAuthorizationCode(ctx context.Context, codeHash string) (AuthorizationCode, error) {
+	row := q.db.QueryRowContext(ctx, useAuthorizationCode, codeHash)
+	var i AuthorizationCode
+	err := row.Scan(
+		&i.CodeHash,
+		&i.ClientID,
+		&i.UserID,
+		&i.RedirectUri,
+		pq.Array(&i.Scopes),
+		&i.CodeChallenge,
+		&i.Nonce,
+		&i.UsedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func CreateAuthorizationCode(t *testing.T, expiresAt time.Time) AuthorizationCode {
+	user := CreateUser(t)
+	client := CreateClient(t, nil)
+
+	arg := CreateAuthorizationCodeParams{
+		CodeHash:      utils.HashToken(utils.RandomString(43)),
+		ClientID:      client.ID,
+		UserID:        user.ID,
+		RedirectUri:   client.RedirectUris[0],
+		Scopes:        []string{"admin:users:read"},
+		CodeChallenge: utils.RandomString(43),
+		Nonce:         utils.RandomString(16),
+		ExpiresAt:     expiresAt,
+	}
+	code, err := testQueries.CreateAuthorizationCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.CodeHash, code.CodeHash)
+	require.Equal(t, arg.ClientID, code.ClientID)
+	require.Equal(t, arg.UserID, code.UserID)
+	require.Equal(t, arg.RedirectUri, code.RedirectUri)
+	require.Equal(t, arg.Scopes, code.Scopes)
+	require.Equal(t, arg.CodeChallenge, code.CodeChallenge)
+	require.Equal(t, arg.Nonce, code.Nonce)
+	require.False(t, code.UsedAt.Valid)
+
+	return code
+}
+
+func TestUseAuthorizationCode(t *testing.T) {
+	code := CreateAuthorizationCode(t, time.Now().Add(time.Minute))
+
+	used, err := testQueries.UseAuthorizationCode(context.Background(), code.CodeHash)
+	require.NoError(t, err)
+	require.True(t, used.UsedAt.Valid)
+
+	// a code can only be used once
+	_, err = testQueries.UseAuthorizationCode(context.Background(), code.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestDeleteExpiredAuthorizationCodes(t *testing.T) {
+	expired := CreateAuthorizationCode(t, time.Now().Add(-time.Minute))
+	valid := CreateAuthorizationCode(t, time.Now().Add(time.Minute))
+
+	err := testQueries.DeleteExpiredAuthorizationCodes(context.Background())
+	require.NoError(t, err)
+
+	_, err = testQueries.UseAuthorizationCode(context.Background(), expired.CodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	_, err = testQueries.UseAuthorizationCode(context.Background(), valid.CodeHash)
+	require.NoError(t, err)
+}
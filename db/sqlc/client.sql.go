@@ -0,0 +1,166 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: client.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+const createClient = `-- name: CreateClient :one
+INSERT INTO clients (
+	id, name, secret_hash, scopes, exchange_audiences, redirect_uris, public,
+	grant_types, token_endpoint_auth_method, registration_token_hash,
+	post_logout_redirect_uris, backchannel_logout_uri
+)VALUES(
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING id, name, secret_hash, scopes, created_at, exchange_audiences, redirect_uris, public, grant_types, token_endpoint_auth_method, registration_token_hash, updated_at, post_logout_redirect_uris, backchannel_logout_uri
+`
+
+type CreateClientParams struct {
+	ID                      string   `json:"id"`
+	Name                    string   `json:"name"`
+	SecretHash              string   `json:"secret_hash"`
+	Scopes                  []string `json:"scopes"`
+	ExchangeAudiences       []string `json:"exchange_audiences"`
+	RedirectUris            []string `json:"redirect_uris"`
+	Public                  bool     `json:"public"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	RegistrationTokenHash   string   `json:"registration_token_hash"`
+	PostLogoutRedirectUris  []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri    string   `json:"backchannel_logout_uri"`
+}
+
+func (q *Queries) CreateClient(ctx context.Context, arg CreateClientParams) (Client, error) {
+	row := q.db.QueryRowContext(ctx, createClient,
+		arg.ID,
+		arg.Name,
+		arg.SecretHash,
+		pq.Array(arg.Scopes),
+		pq.Array(arg.ExchangeAudiences),
+		pq.Array(arg.RedirectUris),
+		arg.Public,
+		pq.Array(arg.GrantTypes),
+		arg.TokenEndpointAuthMethod,
+		arg.RegistrationTokenHash,
+		pq.Array(arg.PostLogoutRedirectUris),
+		arg.BackchannelLogoutUri,
+	)
+	var i Client
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.SecretHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		pq.Array(&i.ExchangeAudiences),
+		pq.Array(&i.RedirectUris),
+		&i.Public,
+		pq.Array(&i.GrantTypes),
+		&i.TokenEndpointAuthMethod,
+		&i.RegistrationTokenHash,
+		&i.UpdatedAt,
+		pq.Array(&i.PostLogoutRedirectUris),
+		&i.BackchannelLogoutUri,
+	)
+	return i, err
+}
+
+const deleteClient = `-- name: DeleteClient :exec
+DELETE FROM clients
+WHERE id = $1
+`
+
+func (q *Queries) DeleteClient(ctx context.Context, id string) error {
+	_, err := q.db.ExecContext(ctx, deleteClient, id)
+	return err
+}
+
+const getClient = `-- name: GetClient :one
+SELECT id, name, secret_hash, scopes, created_at, exchange_audiences, redirect_uris, public, grant_types, token_endpoint_auth_method, registration_token_hash, updated_at, post_logout_redirect_uris, backchannel_logout_uri FROM clients
+WHERE id = $1
+LIMIT 1
+`
+
+func (q *Queries) GetClient(ctx context.Context, id string) (Client, error) {
+	row := q.db.QueryRowContext(ctx, getClient, id)
+	var i Client
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.SecretHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		pq.Array(&i.ExchangeAudiences),
+		pq.Array(&i.RedirectUris),
+		&i.Public,
+		pq.Array(&i.GrantTypes),
+		&i.TokenEndpointAuthMethod,
+		&i.RegistrationTokenHash,
+		&i.UpdatedAt,
+		pq.Array(&i.PostLogoutRedirectUris),
+		&i.BackchannelLogoutUri,
+	)
+	return i, err
+}
+
+const updateClient = `-- name: UpdateClient :one
+UPDATE clients
+SET name = $2,
+	scopes = $3,
+	redirect_uris = $4,
+	grant_types = $5,
+	token_endpoint_auth_method = $6,
+	post_logout_redirect_uris = $7,
+	backchannel_logout_uri = $8,
+	updated_at = now()
+WHERE id = $1
+RETURNING id, name, secret_hash, scopes, created_at, exchange_audiences, redirect_uris, public, grant_types, token_endpoint_auth_method, registration_token_hash, updated_at, post_logout_redirect_uris, backchannel_logout_uri
+`
+
+type UpdateClientParams struct {
+	ID                      string   `json:"id"`
+	Name                    string   `json:"name"`
+	Scopes                  []string `json:"scopes"`
+	RedirectUris            []string `json:"redirect_uris"`
+	GrantTypes              []string `json:"grant_types"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	PostLogoutRedirectUris  []string `json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri    string   `json:"backchannel_logout_uri"`
+}
+
+func (q *Queries) UpdateClient(ctx context.Context, arg UpdateClientParams) (Client, error) {
+	row := q.db.QueryRowContext(ctx, updateClient,
+		arg.ID,
+		arg.Name,
+		pq.Array(arg.Scopes),
+		pq.Array(arg.RedirectUris),
+		pq.Array(arg.GrantTypes),
+		arg.TokenEndpointAuthMethod,
+		pq.Array(arg.PostLogoutRedirectUris),
+		arg.BackchannelLogoutUri,
+	)
+	var i Client
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.SecretHash,
+		pq.Array(&i.Scopes),
+		&i.CreatedAt,
+		pq.Array(&i.ExchangeAudiences),
+		pq.Array(&i.RedirectUris),
+		&i.Public,
+		pq.Array(&i.GrantTypes),
+		&i.TokenEndpointAuthMethod,
+		&i.RegistrationTokenHash,
+		&i.UpdatedAt,
+		pq.Array(&i.PostLogoutRedirectUris),
+		&i.BackchannelLogoutUri,
+	)
+	return i, err
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func CreateClient(t *testing.T, scopes []string) Client {
+	hash, err := utils.HashPassword(utils.RandomString(32))
+	require.NoError(t, err)
+
+	arg := CreateClientParams{
+		ID:                      uuid.New().String(),
+		Name:                    utils.RandomString(8),
+		SecretHash:              hash,
+		Scopes:                  scopes,
+		ExchangeAudiences:       []string{"billing"},
+		RedirectUris:            []string{"https://app.example.com/callback"},
+		GrantTypes:              []string{"authorization_code", "client_credentials"},
+		TokenEndpointAuthMethod: "client_secret_basic",
+		RegistrationTokenHash:   utils.HashToken(utils.RandomString(43)),
+		PostLogoutRedirectUris:  []string{"https://app.example.com/logged-out"},
+		BackchannelLogoutUri:    "https://app.example.com/backchannel-logout",
+	}
+	client, err := testQueries.CreateClient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.ID, client.ID)
+	require.Equal(t, arg.Name, client.Name)
+	require.Equal(t, arg.SecretHash, client.SecretHash)
+	require.Equal(t, arg.Scopes, client.Scopes)
+	require.Equal(t, arg.ExchangeAudiences, client.ExchangeAudiences)
+	require.Equal(t, arg.RedirectUris, client.RedirectUris)
+	require.False(t, client.Public)
+	require.Equal(t, arg.GrantTypes, client.GrantTypes)
+	require.Equal(t, arg.TokenEndpointAuthMethod, client.TokenEndpointAuthMethod)
+	require.Equal(t, arg.RegistrationTokenHash, client.RegistrationTokenHash)
+	require.Equal(t, arg.PostLogoutRedirectUris, client.PostLogoutRedirectUris)
+	require.Equal(t, arg.BackchannelLogoutUri, client.BackchannelLogoutUri)
+	require.NotZero(t, client.CreatedAt)
+	require.NotZero(t, client.UpdatedAt)
+
+	return client
+}
+
+func TestGetClient(t *testing.T) {
+	client := CreateClient(t, []string{"token:introspect"})
+
+	got, err := testQueries.GetClient(context.Background(), client.ID)
+	require.NoError(t, err)
+	require.Equal(t, client, got)
+}
+
+func TestUpdateClient(t *testing.T) {
+	client := CreateClient(t, []string{"reports:read"})
+
+	arg := UpdateClientParams{
+		ID:                      client.ID,
+		Name:                    utils.RandomString(8),
+		Scopes:                  []string{"reports:read", "reports:write"},
+		RedirectUris:            []string{"https://app.example.com/callback", "https://app.example.com/other"},
+		GrantTypes:              []string{"authorization_code"},
+		TokenEndpointAuthMethod: "client_secret_post",
+		PostLogoutRedirectUris:  []string{},
+		BackchannelLogoutUri:    "",
+	}
+	updated, err := testQueries.UpdateClient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Name, updated.Name)
+	require.Equal(t, arg.Scopes, updated.Scopes)
+	require.Equal(t, arg.RedirectUris, updated.RedirectUris)
+	require.Equal(t, arg.GrantTypes, updated.GrantTypes)
+	require.Equal(t, arg.TokenEndpointAuthMethod, updated.TokenEndpointAuthMethod)
+	require.Equal(t, arg.PostLogoutRedirectUris, updated.PostLogoutRedirectUris)
+	require.Equal(t, arg.BackchannelLogoutUri, updated.BackchannelLogoutUri)
+	// credentials and exchange policy are not client metadata
+	require.Equal(t, client.SecretHash, updated.SecretHash)
+	require.Equal(t, client.RegistrationTokenHash, updated.RegistrationTokenHash)
+	require.Equal(t, client.ExchangeAudiences, updated.ExchangeAudiences)
+	require.True(t, updated.UpdatedAt.After(client.UpdatedAt))
+}
+
+func TestDeleteClient(t *testing.T) {
+	code := CreateAuthorizationCode(t, time.Now().Add(time.Minute))
+
+	err := testQueries.DeleteClient(context.Background(), code.ClientID)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetClient(context.Background(), code.ClientID)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+	// pending codes go with the client
+	_, err = testQueries.UseAuthorizationCode(context.Background(), code.CodeHash)
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -0,0 +1,215 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: device_code.sql
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const approveDeviceCode = `-- name: ApproveDeviceCode :one
+UPDATE device_codes
+SET status = 'approved', user_id = $2
+WHERE user_code = $1
+AND status = 'pending'
+AND expires_at > now()
+RETURNING device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at
+`
+
+type ApproveDeviceCodeParams struct {
+	UserCode string         `json:"user_code"`
+	UserID   sql.NullString `json:"user_id"`
+}
+
+func (q *Queries) ApproveDeviceCode(ctx context.Context, arg ApproveDeviceCodeParams) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, approveDeviceCode, arg.UserCode, arg.UserID)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createDeviceCode = `-- name: CreateDeviceCode :one
+INSERT INTO device_codes (
+	device_code_hash, user_code, client_id, scopes, poll_interval, expires_at
+)VALUES(
+	$1, $2, $3, $4, $5, $6
+) RETURNING device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at
+`
+
+type CreateDeviceCodeParams struct {
+	DeviceCodeHash string    `json:"device_code_hash"`
+	UserCode       string    `json:"user_code"`
+	ClientID       string    `json:"client_id"`
+	Scopes         []string  `json:"scopes"`
+	PollInterval   int32     `json:"poll_interval"`
+	ExpiresAt      time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateDeviceCode(ctx context.Context, arg CreateDeviceCodeParams) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, createDeviceCode,
+		arg.DeviceCodeHash,
+		arg.UserCode,
+		arg.ClientID,
+		pq.Array(arg.Scopes),
+		arg.PollInterval,
+		arg.ExpiresAt,
+	)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteExpiredDeviceCodes = `-- name: DeleteExpiredDeviceCodes :exec
+DELETE FROM device_codes
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredDeviceCodes(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredDeviceCodes)
+	return err
+}
+
+const denyDeviceCode = `-- name: DenyDeviceCode :one
+UPDATE device_codes
+SET status = 'denied'
+WHERE user_code = $1
+AND status = 'pending'
+AND expires_at > now()
+RETURNING device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at
+`
+
+func (q *Queries) DenyDeviceCode(ctx context.Context, userCode string) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, denyDeviceCode, userCode)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getDeviceCode = `-- name: GetDeviceCode :one
+SELECT device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at FROM device_codes
+WHERE device_code_hash = $1
+LIMIT 1
+`
+
+func (q *Queries) GetDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceCode, deviceCodeHash)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getDeviceCodeByUserCode = `-- name: GetDeviceCodeByUserCode :one
+SELECT device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at FROM device_codes
+WHERE user_code = $1
+LIMIT 1
+`
+
+func (q *Queries) GetDeviceCodeByUserCode(ctx context.Context, userCode string) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, getDeviceCodeByUserCode, userCode)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const pollDeviceCode = `-- name: PollDeviceCode :exec
+UPDATE device_codes
+SET polled_at = now(), poll_interval = $2
+WHERE device_code_hash = $1
+`
+
+type PollDeviceCodeParams struct {
+	DeviceCodeHash string `json:"device_code_hash"`
+	PollInterval   int32  `json:"poll_interval"`
+}
+
+func (q *Queries) PollDeviceCode(ctx context.Context, arg PollDeviceCodeParams) error {
+	_, err := q.db.ExecContext(ctx, pollDeviceCode, arg.DeviceCodeHash, arg.PollInterval)
+	return err
+}
+
+const useDeviceCode = `-- name: UseDeviceCode :one
+UPDATE device_codes
+SET status = 'used'
+WHERE device_code_hash = $1
+AND status = 'approved'
+RETURNING device_code_hash, user_code, client_id, scopes, status, user_id, poll_interval, polled_at, expires_at, created_at
+`
+
+func (q *Queries) UseDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceCode, error) {
+	row := q.db.QueryRowContext(ctx, useDeviceCode, deviceCodeHash)
+	var i DeviceCode
+	err := row.Scan(
+		&i.DeviceCodeHash,
+		&i.UserCode,
+		&i.ClientID,
+		pq.Array(&i.Scopes),
+		&i.Status,
+		&i.UserID,
+		&i.PollInterval,
+		&i.PolledAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func CreateDeviceCode(t *testing.T, expiresAt time.Time) DeviceCode {
+	client := CreateClient(t, nil)
+
+	arg := CreateDeviceCodeParams{
+		DeviceCodeHash: utils.HashToken(utils.RandomString(43)),
+		UserCode:       utils.RandomString(4) + "-" + utils.RandomString(4),
+		ClientID:       client.ID,
+		Scopes:         []string{"admin:users:read"},
+		PollInterval:   5,
+		ExpiresAt:      expiresAt,
+	}
+	code, err := testQueries.CreateDeviceCode(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.DeviceCodeHash, code.DeviceCodeHash)
+	require.Equal(t, arg.UserCode, code.UserCode)
+	require.Equal(t, arg.ClientID, code.ClientID)
+	require.Equal(t, arg.Scopes, code.Scopes)
+	require.Equal(t, "pending", code.Status)
+	require.False(t, code.UserID.Valid)
+	require.False(t, code.PolledAt.Valid)
+
+	return code
+}
+
+func TestApproveDeviceCode(t *testing.T) {
+	code := CreateDeviceCode(t, time.Now().Add(time.Minute))
+	user := CreateUser(t)
+
+	approved, err := testQueries.ApproveDeviceCode(context.Background(), ApproveDeviceCodeParams{
+		UserCode: code.UserCode,
+		UserID:   sql.NullString{String: user.ID, Valid: true},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "approved", approved.Status)
+	require.Equal(t, user.ID, approved.UserID.String)
+
+	// only pending codes can be approved or denied
+	_, err = testQueries.DenyDeviceCode(context.Background(), code.UserCode)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	used, err := testQueries.UseDeviceCode(context.Background(), code.DeviceCodeHash)
+	require.NoError(t, err)
+	require.Equal(t, "used", used.Status)
+
+	_, err = testQueries.UseDeviceCode(context.Background(), code.DeviceCodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestDenyDeviceCode(t *testing.T) {
+	code := CreateDeviceCode(t, time.Now().Add(time.Minute))
+
+	denied, err := testQueries.DenyDeviceCode(context.Background(), code.UserCode)
+	require.NoError(t, err)
+	require.Equal(t, "denied", denied.Status)
+
+	_, err = testQueries.UseDeviceCode(context.Background(), code.DeviceCodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestApproveExpiredDeviceCode(t *testing.T) {
+	code := CreateDeviceCode(t, time.Now().Add(-time.Minute))
+	user := CreateUser(t)
+
+	_, err := testQueries.ApproveDeviceCode(context.Background(), ApproveDeviceCodeParams{
+		UserCode: code.UserCode,
+		UserID:   sql.NullString{String: user.ID, Valid: true},
+	})
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestPollDeviceCode(t *testing.T) {
+	code := CreateDeviceCode(t, time.Now().Add(time.Minute))
+
+	err := testQueries.PollDeviceCode(context.Background(), PollDeviceCodeParams{
+		DeviceCodeHash: code.DeviceCodeHash,
+		PollInterval:   10,
+	})
+	require.NoError(t, err)
+
+	polled, err := testQueries.GetDeviceCode(context.Background(), code.DeviceCodeHash)
+	require.NoError(t, err)
+	require.Equal(t, int32(10), polled.PollInterval)
+	require.True(t, polled.PolledAt.Valid)
+	require.WithinDuration(t, time.Now(), polled.PolledAt.Time, time.Second)
+
+	got, err := testQueries.GetDeviceCodeByUserCode(context.Background(), code.UserCode)
+	require.NoError(t, err)
+	require.Equal(t, polled, got)
+}
+
+func TestDeleteExpiredDeviceCodes(t *testing.T) {
+	expired := CreateDeviceCode(t, time.Now().Add(-time.Minute))
+	valid := CreateDeviceCode(t, time.Now().Add(time.Minute))
+
+	err := testQueries.DeleteExpiredDeviceCodes(context.Background())
+	require.NoError(t, err)
+
+	_, err = testQueries.GetDeviceCode(context.Background(), expired.DeviceCodeHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+	_, err = testQueries.GetDeviceCode(context.Background(), valid.DeviceCodeHash)
+	require.NoError(t, err)
+}
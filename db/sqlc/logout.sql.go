@@ -0,0 +1,121 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: logout.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const claimLogoutDeliveries = `-- name: ClaimLogoutDeliveries :many
+UPDATE logout_deliveries
+SET attempts = attempts + 1,
+	next_attempt_at = $1
+WHERE id IN (
+	SELECT id FROM logout_deliveries
+	WHERE next_attempt_at <= now()
+	ORDER BY next_attempt_at
+	LIMIT $2
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id, client_id, user_id, attempts, next_attempt_at, created_at
+`
+
+type ClaimLogoutDeliveriesParams struct {
+	LeaseUntil    time.Time `json:"lease_until"`
+	MaxDeliveries int32     `json:"max_deliveries"`
+}
+
+func (q *Queries) ClaimLogoutDeliveries(ctx context.Context, arg ClaimLogoutDeliveriesParams) ([]LogoutDelivery, error) {
+	rows, err := q.db.QueryContext(ctx, claimLogoutDeliveries, arg.LeaseUntil, arg.MaxDeliveries)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LogoutDelivery{}
+	for rows.Next() {
+		var i LogoutDelivery
+		if err := rows.Scan(
+			&i.ID,
+			&i.ClientID,
+			&i.UserID,
+			&i.Attempts,
+			&i.NextAttemptAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const createClientLogin = `-- name: CreateClientLogin :exec
+INSERT INTO client_logins (
+	user_id, client_id
+)VALUES(
+	$1, $2
+) ON CONFLICT (user_id, client_id) DO UPDATE SET created_at = now()
+`
+
+type CreateClientLoginParams struct {
+	UserID   string `json:"user_id"`
+	ClientID string `json:"client_id"`
+}
+
+func (q *Queries) CreateClientLogin(ctx context.Context, arg CreateClientLoginParams) error {
+	_, err := q.db.ExecContext(ctx, createClientLogin, arg.UserID, arg.ClientID)
+	return err
+}
+
+const deleteLogoutDelivery = `-- name: DeleteLogoutDelivery :exec
+DELETE FROM logout_deliveries
+WHERE id = $1
+`
+
+func (q *Queries) DeleteLogoutDelivery(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, deleteLogoutDelivery, id)
+	return err
+}
+
+const queueLogoutDeliveries = `-- name: QueueLogoutDeliveries :exec
+WITH logins AS (
+	DELETE FROM client_logins
+	WHERE client_logins.user_id = $1
+	RETURNING client_id, user_id
+)
+INSERT INTO logout_deliveries (client_id, user_id)
+SELECT logins.client_id, logins.user_id FROM logins
+JOIN clients ON clients.id = logins.client_id
+WHERE clients.backchannel_logout_uri <> ''
+`
+
+func (q *Queries) QueueLogoutDeliveries(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, queueLogoutDeliveries, userID)
+	return err
+}
+
+const retryLogoutDelivery = `-- name: RetryLogoutDelivery :exec
+UPDATE logout_deliveries
+SET next_attempt_at = $2
+WHERE id = $1
+`
+
+type RetryLogoutDeliveryParams struct {
+	ID            int64     `json:"id"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+}
+
+func (q *Queries) RetryLogoutDelivery(ctx context.Context, arg RetryLogoutDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, retryLogoutDelivery, arg.ID, arg.NextAttemptAt)
+	return err
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// claimUserLogoutDeliveries returns the claimed deliveries of userId, other tests may have queued some too
+func claimUserLogoutDeliveries(t *testing.T, userId string) []LogoutDelivery {
+	deliveries, err := testQueries.ClaimLogoutDeliveries(context.Background(), ClaimLogoutDeliveriesParams{
+		LeaseUntil:    time.Now().Add(time.Minute),
+		MaxDeliveries: 1000,
+	})
+	require.NoError(t, err)
+
+	var claimed []LogoutDelivery
+	for _, delivery := range deliveries {
+		if delivery.UserID == userId {
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed
+}
+
+func TestQueueLogoutDeliveries(t *testing.T) {
+	user := CreateUser(t)
+	client := CreateClient(t, nil)
+	// a client without a back-channel uri is never told
+	silent, err := testQueries.UpdateClient(context.Background(), UpdateClientParams{
+		ID:                     CreateClient(t, nil).ID,
+		Name:                   "silent",
+		Scopes:                 []string{},
+		RedirectUris:           []string{},
+		GrantTypes:             []string{},
+		PostLogoutRedirectUris: []string{},
+	})
+	require.NoError(t, err)
+
+	for _, clientId := range []string{client.ID, silent.ID, client.ID} {
+		err := testQueries.CreateClientLogin(context.Background(), CreateClientLoginParams{UserID: user.ID, ClientID: clientId})
+		require.NoError(t, err)
+	}
+
+	err = testQueries.QueueLogoutDeliveries(context.Background(), user.ID)
+	require.NoError(t, err)
+	// logins are consumed, logging out twice doesn't tell the clients twice
+	err = testQueries.QueueLogoutDeliveries(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	deliveries := claimUserLogoutDeliveries(t, user.ID)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, client.ID, deliveries[0].ClientID)
+	require.Equal(t, int32(1), deliveries[0].Attempts)
+	require.WithinDuration(t, time.Now().Add(time.Minute), deliveries[0].NextAttemptAt, time.Second)
+
+	// leased until the attempt is over
+	require.Empty(t, claimUserLogoutDeliveries(t, user.ID))
+
+	err = testQueries.RetryLogoutDelivery(context.Background(), RetryLogoutDeliveryParams{
+		ID:            deliveries[0].ID,
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	deliveries = claimUserLogoutDeliveries(t, user.ID)
+	require.Len(t, deliveries, 1)
+	require.Equal(t, int32(2), deliveries[0].Attempts)
+
+	err = testQueries.DeleteLogoutDelivery(context.Background(), deliveries[0].ID)
+	require.NoError(t, err)
+	err = testQueries.RetryLogoutDelivery(context.Background(), RetryLogoutDeliveryParams{
+		ID:            deliveries[0].ID,
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+	require.Empty(t, claimUserLogoutDeliveries(t, user.ID))
+}
@@ -16,6 +16,7 @@ const (
 )
 
 var testQueries *Queries
+var testDB *sql.DB
 
 func TestNewStore(t *testing.T) {
 	store := NewStore(nil)
@@ -29,6 +30,7 @@ func TestMain(m *testing.M) {
 		log.Fatal("cannot connect to databse")
 	}
 
+	testDB = con
 	testQueries = New(con)
 
 	os.Exit(m.Run())
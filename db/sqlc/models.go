@@ -5,14 +5,112 @@
 package db
 
 import (
+	"database/sql"
+	"encoding/json"
 	"time"
 )
 
-type User struct {
+type AuthorizationCode struct {
+	CodeHash      string       `json:"code_hash"`
+	ClientID      string       `json:"client_id"`
+	UserID        string       `json:"user_id"`
+	RedirectUri   string       `json:"redirect_uri"`
+	Scopes        []string     `json:"scopes"`
+	CodeChallenge string       `json:"code_challenge"`
+	Nonce         string       `json:"nonce"`
+	UsedAt        sql.NullTime `json:"used_at"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+type Client struct {
+	ID                      string    `json:"id"`
+	Name                    string    `json:"name"`
+	SecretHash              string    `json:"secret_hash"`
+	Scopes                  []string  `json:"scopes"`
+	CreatedAt               time.Time `json:"created_at"`
+	ExchangeAudiences       []string  `json:"exchange_audiences"`
+	RedirectUris            []string  `json:"redirect_uris"`
+	Public                  bool      `json:"public"`
+	GrantTypes              []string  `json:"grant_types"`
+	TokenEndpointAuthMethod string    `json:"token_endpoint_auth_method"`
+	RegistrationTokenHash   string    `json:"registration_token_hash"`
+	UpdatedAt               time.Time `json:"updated_at"`
+	PostLogoutRedirectUris  []string  `json:"post_logout_redirect_uris"`
+	BackchannelLogoutUri    string    `json:"backchannel_logout_uri"`
+}
+
+type ClientLogin struct {
+	UserID    string    `json:"user_id"`
+	ClientID  string    `json:"client_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DeviceCode struct {
+	DeviceCodeHash string         `json:"device_code_hash"`
+	UserCode       string         `json:"user_code"`
+	ClientID       string         `json:"client_id"`
+	Scopes         []string       `json:"scopes"`
+	Status         string         `json:"status"`
+	UserID         sql.NullString `json:"user_id"`
+	PollInterval   int32          `json:"poll_interval"`
+	PolledAt       sql.NullTime   `json:"polled_at"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type LogoutDelivery struct {
+	ID            int64     `json:"id"`
+	ClientID      string    `json:"client_id"`
+	UserID        string    `json:"user_id"`
+	Attempts      int32     `json:"attempts"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type OpaqueToken struct {
+	TokenHash string          `json:"token_hash"`
+	Payload   json.RawMessage `json:"payload"`
+	ExpiresAt time.Time       `json:"expires_at"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type RevokedToken struct {
 	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Name      string    `json:"name"`
-	Password  string    `json:"password"`
-	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+type RevokedUser struct {
+	UserID        string    `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+	ExpiresAt     time.Time `json:"expires_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type Session struct {
+	ID               string       `json:"id"`
+	UserID           string       `json:"user_id"`
+	FamilyID         string       `json:"family_id"`
+	RefreshTokenHash string       `json:"refresh_token_hash"`
+	UserAgent        string       `json:"user_agent"`
+	ClientIp         string       `json:"client_ip"`
+	IsRevoked        bool         `json:"is_revoked"`
+	RotatedAt        sql.NullTime `json:"rotated_at"`
+	ExpiresAt        time.Time    `json:"expires_at"`
+	CreatedAt        time.Time    `json:"created_at"`
+	DpopJkt          string       `json:"dpop_jkt"`
+	FingerprintHash  string       `json:"fingerprint_hash"`
+}
+
+type User struct {
+	ID               string    `json:"id"`
+	Username         string    `json:"username"`
+	Name             string    `json:"name"`
+	Password         string    `json:"password"`
+	Email            string    `json:"email"`
+	CreatedAt        time.Time `json:"created_at"`
+	Roles            []string  `json:"roles"`
+	UpstreamProvider string    `json:"upstream_provider"`
+	UpstreamSubject  string    `json:"upstream_subject"`
+}
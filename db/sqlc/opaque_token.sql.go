@@ -0,0 +1,69 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: opaque_token.sql
+
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+const createOpaqueToken = `-- name: CreateOpaqueToken :exec
+INSERT INTO opaque_tokens (
+	token_hash, payload, expires_at
+)VALUES(
+	$1, $2, $3
+)
+`
+
+type CreateOpaqueTokenParams struct {
+	TokenHash string          `json:"token_hash"`
+	Payload   json.RawMessage `json:"payload"`
+	ExpiresAt time.Time       `json:"expires_at"`
+}
+
+func (q *Queries) CreateOpaqueToken(ctx context.Context, arg CreateOpaqueTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createOpaqueToken, arg.TokenHash, arg.Payload, arg.ExpiresAt)
+	return err
+}
+
+const deleteExpiredOpaqueTokens = `-- name: DeleteExpiredOpaqueTokens :exec
+DELETE FROM opaque_tokens
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredOpaqueTokens(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredOpaqueTokens)
+	return err
+}
+
+const deleteOpaqueToken = `-- name: DeleteOpaqueToken :exec
+DELETE FROM opaque_tokens
+WHERE token_hash = $1
+`
+
+func (q *Queries) DeleteOpaqueToken(ctx context.Context, tokenHash string) error {
+	_, err := q.db.ExecContext(ctx, deleteOpaqueToken, tokenHash)
+	return err
+}
+
+const getOpaqueToken = `-- name: GetOpaqueToken :one
+SELECT token_hash, payload, expires_at, created_at FROM opaque_tokens
+WHERE token_hash = $1
+LIMIT 1
+`
+
+func (q *Queries) GetOpaqueToken(ctx context.Context, tokenHash string) (OpaqueToken, error) {
+	row := q.db.QueryRowContext(ctx, getOpaqueToken, tokenHash)
+	var i OpaqueToken
+	err := row.Scan(
+		&i.TokenHash,
+		&i.Payload,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,32 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpaqueToken(t *testing.T) {
+	arg := CreateOpaqueTokenParams{
+		TokenHash: utils.HashToken(utils.RandomString(43)),
+		Payload:   []byte(`{"user_id":"user"}`),
+		ExpiresAt: time.Now().Add(time.Minute),
+	}
+	err := testQueries.CreateOpaqueToken(context.Background(), arg)
+	require.NoError(t, err)
+
+	row, err := testQueries.GetOpaqueToken(context.Background(), arg.TokenHash)
+	require.NoError(t, err)
+	require.JSONEq(t, string(arg.Payload), string(row.Payload))
+	require.WithinDuration(t, arg.ExpiresAt, row.ExpiresAt, time.Second)
+
+	err = testQueries.DeleteOpaqueToken(context.Background(), arg.TokenHash)
+	require.NoError(t, err)
+
+	_, err = testQueries.GetOpaqueToken(context.Background(), arg.TokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
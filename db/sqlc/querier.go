@@ -9,9 +9,44 @@ import (
 )
 
 type Querier interface {
+	ApproveDeviceCode(ctx context.Context, arg ApproveDeviceCodeParams) (DeviceCode, error)
+	ClaimLogoutDeliveries(ctx context.Context, arg ClaimLogoutDeliveriesParams) ([]LogoutDelivery, error)
+	CreateAuthorizationCode(ctx context.Context, arg CreateAuthorizationCodeParams) (AuthorizationCode, error)
+	CreateClient(ctx context.Context, arg CreateClientParams) (Client, error)
+	CreateClientLogin(ctx context.Context, arg CreateClientLoginParams) error
+	CreateDeviceCode(ctx context.Context, arg CreateDeviceCodeParams) (DeviceCode, error)
+	CreateOpaqueToken(ctx context.Context, arg CreateOpaqueTokenParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
 	CreateUser(ctx context.Context, arg CreateUserParams) (User, error)
+	DeleteClient(ctx context.Context, id string) error
+	DeleteExpiredAuthorizationCodes(ctx context.Context) error
+	DeleteExpiredDeviceCodes(ctx context.Context) error
+	DeleteExpiredOpaqueTokens(ctx context.Context) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
+	DeleteExpiredRevokedUsers(ctx context.Context) error
+	DeleteLogoutDelivery(ctx context.Context, id int64) error
+	DeleteOpaqueToken(ctx context.Context, tokenHash string) error
+	DenyDeviceCode(ctx context.Context, userCode string) (DeviceCode, error)
+	GetClient(ctx context.Context, id string) (Client, error)
+	GetDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceCode, error)
+	GetDeviceCodeByUserCode(ctx context.Context, userCode string) (DeviceCode, error)
+	GetOpaqueToken(ctx context.Context, tokenHash string) (OpaqueToken, error)
+	GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (Session, error)
 	GetUser(ctx context.Context, username string) (User, error)
+	GetUserByUpstreamIdentity(ctx context.Context, arg GetUserByUpstreamIdentityParams) (User, error)
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
 	Me(ctx context.Context, id string) (User, error)
+	PollDeviceCode(ctx context.Context, arg PollDeviceCodeParams) error
+	QueueLogoutDeliveries(ctx context.Context, userID string) error
+	RetryLogoutDelivery(ctx context.Context, arg RetryLogoutDeliveryParams) error
+	RevokeSessionFamily(ctx context.Context, familyID string) error
+	RevokeToken(ctx context.Context, arg RevokeTokenParams) error
+	RevokeUserSessions(ctx context.Context, userID string) error
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	RotateSession(ctx context.Context, id string) (Session, error)
+	UpdateClient(ctx context.Context, arg UpdateClientParams) (Client, error)
+	UseAuthorizationCode(ctx context.Context, codeHash string) (AuthorizationCode, error)
+	UseDeviceCode(ctx context.Context, deviceCodeHash string) (DeviceCode, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,94 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: revocation.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const deleteExpiredRevokedTokens = `-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredRevokedTokens)
+	return err
+}
+
+const deleteExpiredRevokedUsers = `-- name: DeleteExpiredRevokedUsers :exec
+DELETE FROM revoked_users
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredRevokedUsers(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, deleteExpiredRevokedUsers)
+	return err
+}
+
+const isTokenRevoked = `-- name: IsTokenRevoked :one
+SELECT (
+	EXISTS(SELECT 1 FROM revoked_tokens WHERE id = $1)
+	OR EXISTS(
+		SELECT 1 FROM revoked_users
+		WHERE user_id = $2
+		AND revoked_before >= $3
+	)
+)::boolean AS revoked
+`
+
+type IsTokenRevokedParams struct {
+	ID       string    `json:"id"`
+	UserID   string    `json:"user_id"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+func (q *Queries) IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isTokenRevoked, arg.ID, arg.UserID, arg.IssuedAt)
+	var revoked bool
+	err := row.Scan(&revoked)
+	return revoked, err
+}
+
+const revokeToken = `-- name: RevokeToken :exec
+INSERT INTO revoked_tokens (
+	id, expires_at
+)VALUES(
+	$1, $2
+) ON CONFLICT (id) DO NOTHING
+`
+
+type RevokeTokenParams struct {
+	ID        string    `json:"id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) RevokeToken(ctx context.Context, arg RevokeTokenParams) error {
+	_, err := q.db.ExecContext(ctx, revokeToken, arg.ID, arg.ExpiresAt)
+	return err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+INSERT INTO revoked_users (
+	user_id, revoked_before, expires_at
+)VALUES(
+	$1, $2, $3
+) ON CONFLICT (user_id) DO UPDATE
+SET revoked_before = EXCLUDED.revoked_before,
+	expires_at = GREATEST(revoked_users.expires_at, EXCLUDED.expires_at)
+`
+
+type RevokeUserTokensParams struct {
+	UserID        string    `json:"user_id"`
+	RevokedBefore time.Time `json:"revoked_before"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.ExecContext(ctx, revokeUserTokens, arg.UserID, arg.RevokedBefore, arg.ExpiresAt)
+	return err
+}
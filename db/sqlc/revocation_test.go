@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRevokeToken(t *testing.T) {
+	id := uuid.New().String()
+	userId := uuid.New().String()
+
+	arg := IsTokenRevokedParams{ID: id, UserID: userId, IssuedAt: time.Now()}
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.False(t, revoked)
+
+	err = testQueries.RevokeToken(context.Background(), RevokeTokenParams{ID: id, ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+
+	// revoking twice is fine
+	err = testQueries.RevokeToken(context.Background(), RevokeTokenParams{ID: id, ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), arg)
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
+
+func TestRevokeUserTokens(t *testing.T) {
+	userId := uuid.New().String()
+	now := time.Now()
+
+	err := testQueries.RevokeUserTokens(context.Background(), RevokeUserTokensParams{
+		UserID:        userId,
+		RevokedBefore: now,
+		ExpiresAt:     now.Add(time.Minute),
+	})
+	require.NoError(t, err)
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New().String(),
+		UserID:   userId,
+		IssuedAt: now.Add(-time.Second),
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New().String(),
+		UserID:   userId,
+		IssuedAt: now.Add(time.Second),
+	})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestDeleteExpiredRevocations(t *testing.T) {
+	id := uuid.New().String()
+
+	err := testQueries.RevokeToken(context.Background(), RevokeTokenParams{ID: id, ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+
+	require.NoError(t, testQueries.DeleteExpiredRevokedTokens(context.Background()))
+	require.NoError(t, testQueries.DeleteExpiredRevokedUsers(context.Background()))
+
+	revoked, err := testQueries.IsTokenRevoked(context.Background(), IsTokenRevokedParams{ID: id, IssuedAt: time.Now()})
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
@@ -0,0 +1,138 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.16.0
+// source: session.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (
+	id, user_id, family_id, refresh_token_hash, user_agent, client_ip, expires_at, dpop_jkt, fingerprint_hash
+)VALUES(
+	$1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING id, user_id, family_id, refresh_token_hash, user_agent, client_ip, is_revoked, rotated_at, expires_at, created_at, dpop_jkt, fingerprint_hash
+`
+
+type CreateSessionParams struct {
+	ID               string    `json:"id"`
+	UserID           string    `json:"user_id"`
+	FamilyID         string    `json:"family_id"`
+	RefreshTokenHash string    `json:"refresh_token_hash"`
+	UserAgent        string    `json:"user_agent"`
+	ClientIp         string    `json:"client_ip"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	DpopJkt          string    `json:"dpop_jkt"`
+	FingerprintHash  string    `json:"fingerprint_hash"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRowContext(ctx, createSession,
+		arg.ID,
+		arg.UserID,
+		arg.FamilyID,
+		arg.RefreshTokenHash,
+		arg.UserAgent,
+		arg.ClientIp,
+		arg.ExpiresAt,
+		arg.DpopJkt,
+		arg.FingerprintHash,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.RefreshTokenHash,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsRevoked,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.DpopJkt,
+		&i.FingerprintHash,
+	)
+	return i, err
+}
+
+const getSessionByRefreshToken = `-- name: GetSessionByRefreshToken :one
+SELECT id, user_id, family_id, refresh_token_hash, user_agent, client_ip, is_revoked, rotated_at, expires_at, created_at, dpop_jkt, fingerprint_hash FROM sessions
+WHERE refresh_token_hash = $1
+LIMIT 1
+`
+
+func (q *Queries) GetSessionByRefreshToken(ctx context.Context, refreshTokenHash string) (Session, error) {
+	row := q.db.QueryRowContext(ctx, getSessionByRefreshToken, refreshTokenHash)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.RefreshTokenHash,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsRevoked,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.DpopJkt,
+		&i.FingerprintHash,
+	)
+	return i, err
+}
+
+const revokeSessionFamily = `-- name: RevokeSessionFamily :exec
+UPDATE sessions
+SET is_revoked = true
+WHERE family_id = $1
+`
+
+func (q *Queries) RevokeSessionFamily(ctx context.Context, familyID string) error {
+	_, err := q.db.ExecContext(ctx, revokeSessionFamily, familyID)
+	return err
+}
+
+const revokeUserSessions = `-- name: RevokeUserSessions :exec
+UPDATE sessions
+SET is_revoked = true
+WHERE user_id = $1
+`
+
+func (q *Queries) RevokeUserSessions(ctx context.Context, userID string) error {
+	_, err := q.db.ExecContext(ctx, revokeUserSessions, userID)
+	return err
+}
+
+const rotateSession = `-- name: RotateSession :one
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1
+AND rotated_at IS NULL
+AND is_revoked = false
+RETURNING id, user_id, family_id, refresh_token_hash, user_agent, client_ip, is_revoked, rotated_at, expires_at, created_at, dpop_jkt, fingerprint_hash
+`
+
+func (q *Queries) RotateSession(ctx context.Context, id string) (Session, error) {
+	row := q.db.QueryRowContext(ctx, rotateSession, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.FamilyID,
+		&i.RefreshTokenHash,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsRevoked,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+		&i.DpopJkt,
+		&i.FingerprintHash,
+	)
+	return i, err
+}
@@ -0,0 +1,99 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func CreateSession(t *testing.T, user User, familyId string) Session {
+
+	arg := CreateSessionParams{
+		ID:               uuid.New().String(),
+		UserID:           user.ID,
+		FamilyID:         familyId,
+		RefreshTokenHash: utils.HashToken(utils.RandomString(32)),
+		UserAgent:        utils.RandomString(10),
+		ClientIp:         "127.0.0.1",
+		ExpiresAt:        time.Now().Add(time.Hour),
+		FingerprintHash:  utils.HashToken(utils.RandomString(32)),
+	}
+
+	session, err := testQueries.CreateSession(context.Background(), arg)
+	require.NoError(t, err)
+	require.NotEmpty(t, session)
+
+	require.Equal(t, arg.ID, session.ID)
+	require.Equal(t, arg.UserID, session.UserID)
+	require.Equal(t, arg.FamilyID, session.FamilyID)
+	require.Equal(t, arg.RefreshTokenHash, session.RefreshTokenHash)
+	require.Equal(t, arg.FingerprintHash, session.FingerprintHash)
+	require.False(t, session.IsRevoked)
+	require.False(t, session.RotatedAt.Valid)
+	require.WithinDuration(t, arg.ExpiresAt, session.ExpiresAt, time.Second)
+	return session
+}
+
+func TestCreateSession(t *testing.T) {
+	CreateSession(t, CreateUser(t), uuid.New().String())
+}
+
+func TestGetSessionByRefreshToken(t *testing.T) {
+	session := CreateSession(t, CreateUser(t), uuid.New().String())
+
+	gotSession, err := testQueries.GetSessionByRefreshToken(context.Background(), session.RefreshTokenHash)
+	require.NoError(t, err)
+	require.Equal(t, session.ID, gotSession.ID)
+	require.Equal(t, session.FamilyID, gotSession.FamilyID)
+}
+
+func TestRotateSession(t *testing.T) {
+	session := CreateSession(t, CreateUser(t), uuid.New().String())
+
+	rotated, err := testQueries.RotateSession(context.Background(), session.ID)
+	require.NoError(t, err)
+	require.True(t, rotated.RotatedAt.Valid)
+
+	// a session can only be rotated once
+	_, err = testQueries.RotateSession(context.Background(), session.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestRevokeSessionFamily(t *testing.T) {
+	user := CreateUser(t)
+	familyId := uuid.New().String()
+	session1 := CreateSession(t, user, familyId)
+	session2 := CreateSession(t, user, familyId)
+
+	err := testQueries.RevokeSessionFamily(context.Background(), familyId)
+	require.NoError(t, err)
+
+	for _, session := range []Session{session1, session2} {
+		gotSession, err := testQueries.GetSessionByRefreshToken(context.Background(), session.RefreshTokenHash)
+		require.NoError(t, err)
+		require.True(t, gotSession.IsRevoked)
+	}
+
+	_, err = testQueries.RotateSession(context.Background(), session1.ID)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
+
+func TestRevokeUserSessions(t *testing.T) {
+	user := CreateUser(t)
+	session1 := CreateSession(t, user, uuid.New().String())
+	session2 := CreateSession(t, user, uuid.New().String())
+
+	err := testQueries.RevokeUserSessions(context.Background(), user.ID)
+	require.NoError(t, err)
+
+	for _, session := range []Session{session1, session2} {
+		gotSession, err := testQueries.GetSessionByRefreshToken(context.Background(), session.RefreshTokenHash)
+		require.NoError(t, err)
+		require.True(t, gotSession.IsRevoked)
+	}
+}
@@ -1,9 +1,14 @@
 package db
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
 
 type Store interface {
 	Querier
+	RefreshSessionTx(ctx context.Context, arg RefreshSessionTxParams) (Session, error)
 }
 
 type SQLStore struct {
@@ -17,3 +22,42 @@ func NewStore(con *sql.DB) Store {
 		Queries: New(con),
 	}
 }
+
+// execTx run fn in a transaction, it's rolled back when fn returns an error
+func (store *SQLStore) execTx(ctx context.Context, fn func(*Queries) error) error {
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	err = fn(New(tx))
+	if err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("tx err: %v, rb err: %v", err, rbErr)
+		}
+		return err
+	}
+	return tx.Commit()
+}
+
+type RefreshSessionTxParams struct {
+	SessionID  string
+	NewSession CreateSessionParams
+}
+
+// RefreshSessionTx rotate a session and create the one replacing it, a session already
+// rotated returns sql.ErrNoRows and nothing is created
+func (store *SQLStore) RefreshSessionTx(ctx context.Context, arg RefreshSessionTxParams) (Session, error) {
+	var session Session
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		_, err := q.RotateSession(ctx, arg.SessionID)
+		if err != nil {
+			return err
+		}
+
+		session, err = q.CreateSession(ctx, arg.NewSession)
+		return err
+	})
+	return session, err
+}
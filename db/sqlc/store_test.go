@@ -0,0 +1,49 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshSessionTx(t *testing.T) {
+	store := NewStore(testDB)
+	user := CreateUser(t)
+	session := CreateSession(t, user, uuid.New().String())
+
+	arg := RefreshSessionTxParams{
+		SessionID: session.ID,
+		NewSession: CreateSessionParams{
+			ID:               uuid.New().String(),
+			UserID:           user.ID,
+			FamilyID:         session.FamilyID,
+			RefreshTokenHash: utils.HashToken(utils.RandomString(32)),
+			UserAgent:        utils.RandomString(10),
+			ClientIp:         "127.0.0.1",
+			ExpiresAt:        time.Now().Add(time.Hour),
+		},
+	}
+
+	newSession, err := store.RefreshSessionTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.NewSession.ID, newSession.ID)
+	require.Equal(t, session.FamilyID, newSession.FamilyID)
+
+	rotated, err := testQueries.GetSessionByRefreshToken(context.Background(), session.RefreshTokenHash)
+	require.NoError(t, err)
+	require.True(t, rotated.RotatedAt.Valid)
+
+	// losing the rotation rolls back, the other session is never created
+	arg.NewSession.ID = uuid.New().String()
+	arg.NewSession.RefreshTokenHash = utils.HashToken(utils.RandomString(32))
+	_, err = store.RefreshSessionTx(context.Background(), arg)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+
+	_, err = testQueries.GetSessionByRefreshToken(context.Background(), arg.NewSession.RefreshTokenHash)
+	require.EqualError(t, err, sql.ErrNoRows.Error())
+}
@@ -7,22 +7,26 @@ package db
 
 import (
 	"context"
+
+	"github.com/lib/pq"
 )
 
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (
-	id, username, email, password, name 
+	id, username, email, password, name, upstream_provider, upstream_subject
 )VALUES(
-	$1, $2, $3, $4, $5
-) RETURNING id, username, name, password, email, created_at
+	$1, $2, $3, $4, $5, $6, $7
+) RETURNING id, username, name, password, email, created_at, roles, upstream_provider, upstream_subject
 `
 
 type CreateUserParams struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Name     string `json:"name"`
+	ID               string `json:"id"`
+	Username         string `json:"username"`
+	Email            string `json:"email"`
+	Password         string `json:"password"`
+	Name             string `json:"name"`
+	UpstreamProvider string `json:"upstream_provider"`
+	UpstreamSubject  string `json:"upstream_subject"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -32,6 +36,8 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		arg.Email,
 		arg.Password,
 		arg.Name,
+		arg.UpstreamProvider,
+		arg.UpstreamSubject,
 	)
 	var i User
 	err := row.Scan(
@@ -41,12 +47,15 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Password,
 		&i.Email,
 		&i.CreatedAt,
+		pq.Array(&i.Roles),
+		&i.UpstreamProvider,
+		&i.UpstreamSubject,
 	)
 	return i, err
 }
 
 const getUser = `-- name: GetUser :one
-SELECT id, username, name, password, email, created_at FROM users 
+SELECT id, username, name, password, email, created_at, roles, upstream_provider, upstream_subject FROM users 
 WHERE username = $1
 OR email = $1 LIMIT 1
 `
@@ -61,12 +70,45 @@ func (q *Queries) GetUser(ctx context.Context, username string) (User, error) {
 		&i.Password,
 		&i.Email,
 		&i.CreatedAt,
+		pq.Array(&i.Roles),
+		&i.UpstreamProvider,
+		&i.UpstreamSubject,
+	)
+	return i, err
+}
+
+const getUserByUpstreamIdentity = `-- name: GetUserByUpstreamIdentity :one
+SELECT id, username, name, password, email, created_at, roles, upstream_provider, upstream_subject FROM users
+WHERE upstream_provider = $1
+AND upstream_subject = $2
+AND upstream_provider <> ''
+LIMIT 1
+`
+
+type GetUserByUpstreamIdentityParams struct {
+	UpstreamProvider string `json:"upstream_provider"`
+	UpstreamSubject  string `json:"upstream_subject"`
+}
+
+func (q *Queries) GetUserByUpstreamIdentity(ctx context.Context, arg GetUserByUpstreamIdentityParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByUpstreamIdentity, arg.UpstreamProvider, arg.UpstreamSubject)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Name,
+		&i.Password,
+		&i.Email,
+		&i.CreatedAt,
+		pq.Array(&i.Roles),
+		&i.UpstreamProvider,
+		&i.UpstreamSubject,
 	)
 	return i, err
 }
 
 const me = `-- name: Me :one
-SELECT id, username, name, password, email, created_at FROM users
+SELECT id, username, name, password, email, created_at, roles, upstream_provider, upstream_subject FROM users
 WHERE id = $1
 LIMIT 1
 `
@@ -81,6 +123,9 @@ func (q *Queries) Me(ctx context.Context, id string) (User, error) {
 		&i.Password,
 		&i.Email,
 		&i.CreatedAt,
+		pq.Array(&i.Roles),
+		&i.UpstreamProvider,
+		&i.UpstreamSubject,
 	)
 	return i, err
 }
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -29,6 +30,7 @@ func CreateUser(t *testing.T) (User){
 	require.Equal(t, user.Email, arg.Email)
 	require.Equal(t, user.Username, arg.Username)
 	require.Equal(t, user.Password, arg.Password)
+	require.Empty(t, user.Roles)
 	require.WithinDuration(t, user.CreatedAt, time.Now(), time.Second)	
 	return (user)
 }
@@ -64,3 +66,34 @@ func TestMe(t *testing.T){
 	require.Equal(t, user.Email, me.Email)
 	require.WithinDuration(t, user.CreatedAt, me.CreatedAt, time.Second)
 }
+func TestGetUserByUpstreamIdentity(t *testing.T){
+	arg := CreateUserParams{
+		ID: uuid.New().String(),
+		Username: utils.RandomName(),
+		Email: utils.RandomEmail(),
+		Name: utils.RandomName(),
+		UpstreamProvider: "corp",
+		UpstreamSubject: uuid.New().String(),
+	}
+	user, err := testQueries.CreateUser(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.UpstreamProvider, user.UpstreamProvider)
+	require.Equal(t, arg.UpstreamSubject, user.UpstreamSubject)
+
+	gotUser, err := testQueries.GetUserByUpstreamIdentity(context.Background(), GetUserByUpstreamIdentityParams{
+		UpstreamProvider: arg.UpstreamProvider,
+		UpstreamSubject: arg.UpstreamSubject,
+	})
+	require.NoError(t, err)
+	require.Equal(t, user.ID, gotUser.ID)
+
+	// a subject is a single user per provider
+	arg.ID, arg.Username, arg.Email = uuid.New().String(), utils.RandomName(), utils.RandomEmail()
+	_, err = testQueries.CreateUser(context.Background(), arg)
+	require.Error(t, err)
+
+	// password users have no upstream identity
+	CreateUser(t)
+	_, err = testQueries.GetUserByUpstreamIdentity(context.Background(), GetUserByUpstreamIdentityParams{})
+	require.ErrorIs(t, err, sql.ErrNoRows)
+}
@@ -1,8 +1,13 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/brkss/go-auth/api"
 	db "github.com/brkss/go-auth/db/sqlc"
@@ -28,13 +33,98 @@ func main(){
 	}
 
 
-	maker, err := token.NewPasetoMaker(config.TokenSymtricKey)
+	store := db.NewStore(con)
+
+	maker, err := newTokenMaker(config, store)
 	if err != nil {
 		log.Fatal("cannot create token maker :", err)
 	}
 
-	store := db.NewStore(con)
-	server := api.NewServer(store, maker, config)
+	idTokenMaker, err := newIDTokenMaker(config)
+	if err != nil {
+		log.Fatal("cannot create id token maker :", err)
+	}
+
+	server := api.NewServer(store, maker, idTokenMaker, config)
 	
-	server.Start("0.0.0.0:4000")
+	err = server.Start("0.0.0.0:4000")
+	if err != nil {
+		log.Fatal("cannot start server :", err)
+	}
+}
+
+// newTokenMaker build the token maker selected by TOKEN_MAKER
+func newTokenMaker(config *utils.Config, store db.Store) (token.Maker, error) {
+	var keyring *token.Keyring
+	if config.TokenKeyringFile != "" {
+		var err error
+		keyring, err = token.LoadKeyring(config.TokenKeyringFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.TokenMaker {
+	case "", "paseto":
+		opts := []token.PasetoOption{token.AcceptV2Until(config.TokenV2GraceUntil)}
+		if !config.TokenAcceptV2 {
+			opts = append(opts, token.RejectV2())
+		}
+		if keyring != nil {
+			return token.NewPasetoKeyringMaker(keyring, opts...)
+		}
+		return token.NewPasetoMaker(config.TokenSymtricKey, opts...)
+	case "paseto-public":
+		if keyring != nil {
+			return token.NewPasetoPublicKeyringMaker(keyring)
+		}
+		seed, err := hex.DecodeString(config.TokenPrivateKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("TOKEN_PRIVATE_KEY must be a hex encoded %d bytes seed", ed25519.SeedSize)
+		}
+		return token.NewPasetoPublicMaker(ed25519.NewKeyFromSeed(seed))
+	case "opaque":
+		return token.NewOpaqueMaker(store)
+	case "macaroon":
+		return token.NewMacaroonMaker(config.TokenSymtricKey)
+	case "jwt":
+		if config.TokenAlgorithm == "HS256" {
+			return token.NewJWTMaker(config.TokenAlgorithm, []byte(config.TokenSymtricKey))
+		}
+		data, err := os.ReadFile(config.TokenPrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key, err := token.ParsePrivateKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		return token.NewJWTMaker(config.TokenAlgorithm, key)
+	default:
+		return nil, fmt.Errorf("unknown token maker %q", config.TokenMaker)
+	}
+}
+
+// newIDTokenMaker build the OpenID Connect id token signer, nil when OIDC_PRIVATE_KEY_FILE isn't set
+func newIDTokenMaker(config *utils.Config) (token.Maker, error) {
+	if config.OIDCPrivateKeyFile == "" {
+		return nil, nil
+	}
+	// the issuer is compared as a string by relying parties, it can't come from the Host header
+	if !strings.HasPrefix(config.OIDCIssuer, "https://") {
+		return nil, fmt.Errorf("OIDC_ISSUER must be an https url")
+	}
+	// id tokens are verified by third parties, a shared secret would let them forge some
+	if config.OIDCAlgorithm == "HS256" {
+		return nil, fmt.Errorf("OIDC_ALGORITHM must be asymmetric")
+	}
+	data, err := os.ReadFile(config.OIDCPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := token.ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return token.NewJWTMaker(config.OIDCAlgorithm, key)
 }
@@ -0,0 +1,177 @@
+package token
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// CachedMaker keeps the payloads of recently verified tokens so hot tokens skip
+// decryption, exp, nbf, issuer and audience are still checked on every hit.
+// Entries are dropped after ttl so a token revoked by another instance
+// (or deleted from the opaque table) isn't trusted for longer than that
+type CachedMaker struct {
+	Maker
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[[sha256.Size]byte]*list.Element
+	// front is the most recently used
+	order *list.List
+}
+
+type cacheEntry struct {
+	key      [sha256.Size]byte
+	payload  *Payload
+	cachedAt time.Time
+}
+
+func NewCachedMaker(maker Maker, size int, ttl time.Duration) *CachedMaker {
+	return &CachedMaker{
+		Maker:   maker,
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (cache *CachedMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+	key := sha256.Sum256([]byte(token))
+
+	if payload, ok := cache.get(key); ok {
+		valid, err := payload.Valid(opts...)
+		if !valid {
+			if err == ErrExpiredToken {
+				cache.remove(key)
+			}
+			return nil, err
+		}
+		return payload, nil
+	}
+
+	payload, err := cache.Maker.VerifyToken(token, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cache.add(key, payload)
+	return copyPayload(payload), nil
+}
+
+// Forget drop the cached payloads of a revoked token id
+func (cache *CachedMaker) Forget(id string) {
+	cache.removeIf(func(payload *Payload) bool { return payload.ID == id })
+}
+
+// ForgetUser drop every cached payload of a user
+func (cache *CachedMaker) ForgetUser(userId string) {
+	cache.removeIf(func(payload *Payload) bool { return payload.UserId == userId })
+}
+
+// copyPayload deep copies so callers can't change the cached payload
+func copyPayload(payload *Payload) *Payload {
+	copied := *payload
+	copied.Audience = cloneSlice(payload.Audience)
+	copied.Roles = cloneSlice(payload.Roles)
+	copied.Scopes = cloneSlice(payload.Scopes)
+	copied.Caveats = cloneSlice(payload.Caveats)
+	if payload.Confirmation != nil {
+		confirmation := *payload.Confirmation
+		copied.Confirmation = &confirmation
+	}
+	copied.Actor = copyActor(payload.Actor)
+	if payload.Extra != nil {
+		copied.Extra = make(map[string]json.RawMessage, len(payload.Extra))
+		for name, value := range payload.Extra {
+			copied.Extra[name] = cloneSlice(value)
+		}
+	}
+	return &copied
+}
+
+func copyActor(actor *Actor) *Actor {
+	if actor == nil {
+		return nil
+	}
+	copied := *actor
+	copied.Actor = copyActor(actor.Actor)
+	return &copied
+}
+
+// cloneSlice keeps nil slices nil
+func cloneSlice[T any](values []T) []T {
+	if values == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(values)), values...)
+}
+
+func (cache *CachedMaker) get(key [sha256.Size]byte) (*Payload, bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	element, ok := cache.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Since(entry.cachedAt) > cache.ttl {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+		return nil, false
+	}
+	cache.order.MoveToFront(element)
+	return copyPayload(entry.payload), true
+}
+
+func (cache *CachedMaker) add(key [sha256.Size]byte, payload *Payload) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		element.Value = &cacheEntry{key: key, payload: payload, cachedAt: time.Now()}
+		cache.order.MoveToFront(element)
+		return
+	}
+	cache.entries[key] = cache.order.PushFront(&cacheEntry{key: key, payload: payload, cachedAt: time.Now()})
+
+	for cache.order.Len() > cache.size {
+		oldest := cache.order.Back()
+		cache.order.Remove(oldest)
+		delete(cache.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (cache *CachedMaker) remove(key [sha256.Size]byte) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if element, ok := cache.entries[key]; ok {
+		cache.order.Remove(element)
+		delete(cache.entries, key)
+	}
+}
+
+func (cache *CachedMaker) removeIf(match func(payload *Payload) bool) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	for element := cache.order.Front(); element != nil; {
+		next := element.Next()
+		entry := element.Value.(*cacheEntry)
+		if match(entry.payload) {
+			cache.order.Remove(element)
+			delete(cache.entries, entry.key)
+		}
+		element = next
+	}
+}
+
+// Len is the number of cached payloads
+func (cache *CachedMaker) Len() int {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	return cache.order.Len()
+}
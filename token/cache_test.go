@@ -0,0 +1,218 @@
+package token
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// countingMaker counts the verifications reaching the wrapped maker
+type countingMaker struct {
+	Maker
+	verified int64
+}
+
+func (maker *countingMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+	atomic.AddInt64(&maker.verified, 1)
+	return maker.Maker.VerifyToken(token, opts...)
+}
+
+func newCountingMaker(t testing.TB) *countingMaker {
+	maker, err := NewPasetoMaker(utils.RandomString(32))
+	require.NoError(t, err)
+	return &countingMaker{Maker: maker}
+}
+
+func TestCachedMakerHit(t *testing.T) {
+
+	maker := newCountingMaker(t)
+	cache := NewCachedMaker(maker, 10, time.Minute)
+
+	userId := uuid.New().String()
+	token, err := cache.CreateToken(userId, time.Minute,
+		WithScopes("reports:read"),
+		WithAudience("api"),
+		WithActor(&Actor{Subject: "service", Actor: &Actor{Subject: "gateway"}}),
+		tenantClaim.With("acme"),
+	)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		payload, err := cache.VerifyToken(token)
+		require.NoError(t, err)
+		require.Equal(t, userId, payload.UserId)
+	}
+	require.Equal(t, int64(1), maker.verified)
+
+	// a caller changing its payload doesn't change the cached one
+	payload, err := cache.VerifyToken(token)
+	require.NoError(t, err)
+	payload.UserId = "someone-else"
+	payload.Scopes[0] = "admin:users:write"
+	payload.Audience[0] = "admin"
+	payload.Actor.Actor.Subject = "mallory"
+	payload.Extra["tenant_id"][1] = 'X'
+	payload.Extra["role"] = []byte(`"admin"`)
+	payload, err = cache.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, userId, payload.UserId)
+	require.Equal(t, []string{"reports:read"}, payload.Scopes)
+	require.Equal(t, []string{"api"}, payload.Audience)
+	require.Equal(t, "gateway", payload.Actor.Actor.Subject)
+	tenant, err := tenantClaim.Get(payload)
+	require.NoError(t, err)
+	require.Equal(t, "acme", tenant)
+	require.NotContains(t, payload.Extra, "role")
+}
+
+func TestCachedMakerInvalidToken(t *testing.T) {
+
+	maker := newCountingMaker(t)
+	cache := NewCachedMaker(maker, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := cache.VerifyToken("v4.local.invalid")
+		require.EqualError(t, err, ErrInvalidToken.Error())
+	}
+	require.Equal(t, int64(2), maker.verified)
+	require.Zero(t, cache.Len())
+}
+
+func TestCachedMakerExpiredToken(t *testing.T) {
+
+	cache := NewCachedMaker(newCountingMaker(t), 10, time.Minute)
+
+	token, err := cache.CreateToken(uuid.New().String(), 100*time.Millisecond)
+	require.NoError(t, err)
+	_, err = cache.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, 1, cache.Len())
+
+	time.Sleep(150 * time.Millisecond)
+	_, err = cache.VerifyToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Zero(t, cache.Len())
+}
+
+func TestCachedMakerVerifyOptions(t *testing.T) {
+
+	cache := NewCachedMaker(newCountingMaker(t), 10, time.Minute)
+
+	token, err := cache.CreateToken(uuid.New().String(), time.Minute, WithAudience("billing"))
+	require.NoError(t, err)
+	_, err = cache.VerifyToken(token, ExpectAudience("billing"))
+	require.NoError(t, err)
+
+	// a cached token is still checked against the options of each call
+	_, err = cache.VerifyToken(token, ExpectAudience("orders"))
+	require.EqualError(t, err, ErrInvalidAudience.Error())
+}
+
+func TestCachedMakerEviction(t *testing.T) {
+
+	maker := newCountingMaker(t)
+	cache := NewCachedMaker(maker, 2, time.Minute)
+
+	var tokens []string
+	for i := 0; i < 3; i++ {
+		token, err := cache.CreateToken(uuid.New().String(), time.Minute)
+		require.NoError(t, err)
+		_, err = cache.VerifyToken(token)
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+	require.Equal(t, 2, cache.Len())
+	require.Equal(t, int64(3), maker.verified)
+
+	// the least recently used one was evicted
+	_, err := cache.VerifyToken(tokens[2])
+	require.NoError(t, err)
+	require.Equal(t, int64(3), maker.verified)
+	_, err = cache.VerifyToken(tokens[0])
+	require.NoError(t, err)
+	require.Equal(t, int64(4), maker.verified)
+}
+
+func TestCachedMakerTTL(t *testing.T) {
+
+	maker := newCountingMaker(t)
+	cache := NewCachedMaker(maker, 10, 50*time.Millisecond)
+
+	token, err := cache.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	_, err = cache.VerifyToken(token)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = cache.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), maker.verified)
+}
+
+func TestCachedMakerForget(t *testing.T) {
+
+	cache := NewCachedMaker(newCountingMaker(t), 10, time.Minute)
+
+	userId := uuid.New().String()
+	var payloads []*Payload
+	for i := 0; i < 3; i++ {
+		token, err := cache.CreateToken(userId, time.Minute)
+		require.NoError(t, err)
+		payload, err := cache.VerifyToken(token)
+		require.NoError(t, err)
+		payloads = append(payloads, payload)
+	}
+	other, err := cache.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	_, err = cache.VerifyToken(other)
+	require.NoError(t, err)
+	require.Equal(t, 4, cache.Len())
+
+	cache.Forget(payloads[0].ID)
+	require.Equal(t, 3, cache.Len())
+
+	cache.ForgetUser(userId)
+	require.Equal(t, 1, cache.Len())
+}
+
+func BenchmarkVerifyToken(b *testing.B) {
+
+	const tokenCount = 100
+
+	makers := map[string]func(maker Maker) Maker{
+		"Uncached": func(maker Maker) Maker { return maker },
+		"Cached":   func(maker Maker) Maker { return NewCachedMaker(maker, tokenCount, time.Minute) },
+	}
+
+	for name, wrap := range makers {
+		b.Run(name, func(b *testing.B) {
+			maker := wrap(newCountingMaker(b))
+
+			tokens := make([]string, tokenCount)
+			for i := range tokens {
+				token, err := maker.CreateToken(fmt.Sprintf("user-%d", i), time.Hour)
+				require.NoError(b, err)
+				tokens[i] = token
+			}
+
+			var next int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					token := tokens[atomic.AddInt64(&next, 1)%tokenCount]
+					_, err := maker.VerifyToken(token)
+					if err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
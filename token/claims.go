@@ -0,0 +1,132 @@
+package token
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var ErrReservedClaim = errors.New("this claim name is reserved")
+var ErrMissingClaim = errors.New("this claim is not in the token")
+
+// reservedClaims are the names used by Payload in every token format,
+// custom claims can never overwrite them
+var reservedClaims = map[string]bool{
+	"ID": true, "UserId": true, "ExpiredAt": true, "IssuedAt": true,
+	"iss": true, "aud": true, "sub": true, "nbf": true, "roles": true, "scopes": true,
+	"client_id": true, "cnf": true, "act": true, "fgp": true,
+	// jwt names of the payload fields
+	"jti": true, "exp": true, "iat": true, "uid": true, "scope": true,
+}
+
+// isReservedClaim compare names the way encoding/json matches fields on decode, case
+// insensitively with unicode folding, so "userid" or "ſub" can't overwrite a field either
+func isReservedClaim(name string) bool {
+	for reserved := range reservedClaims {
+		if strings.EqualFold(name, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim is a typed custom claim, declare it once and use it to set and read the value
+//
+//	var TenantClaim = token.NewClaim[string]("tenant_id")
+//	maker.CreateToken(userId, duration, TenantClaim.With("acme"))
+//	tenant, err := TenantClaim.Get(payload)
+type Claim[T any] struct {
+	name string
+}
+
+func NewClaim[T any](name string) Claim[T] {
+	return Claim[T]{name: name}
+}
+
+func (claim Claim[T]) Name() string {
+	return claim.name
+}
+
+// With set the claim value, a reserved name makes token creation fail
+func (claim Claim[T]) With(value T) PayloadOption {
+	return func(payload *Payload) error {
+		if claim.name == "" || isReservedClaim(claim.name) {
+			return fmt.Errorf("%w : %q", ErrReservedClaim, claim.name)
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if payload.Extra == nil {
+			payload.Extra = make(map[string]json.RawMessage)
+		}
+		payload.Extra[claim.name] = data
+		return nil
+	}
+}
+
+// Get read the claim from a verified payload
+func (claim Claim[T]) Get(payload *Payload) (T, error) {
+	var value T
+	data, ok := payload.Extra[claim.name]
+	if !ok {
+		return value, ErrMissingClaim
+	}
+	err := json.Unmarshal(data, &value)
+	return value, err
+}
+
+// marshalWithExtra encode v and add the extra claims at the same level
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if !isReservedClaim(name) {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+// extraClaims returns every claim of data that isn't reserved
+func extraClaims(data []byte) (map[string]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+	for name := range fields {
+		if isReservedClaim(name) {
+			delete(fields, name)
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// payloadFields has the Payload fields without its json methods
+type payloadFields Payload
+
+func (payload Payload) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(payloadFields(payload), payload.Extra)
+}
+
+func (payload *Payload) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, (*payloadFields)(payload))
+	if err != nil {
+		return err
+	}
+	payload.Extra, err = extraClaims(data)
+	return err
+}
@@ -0,0 +1,118 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type appMetadata struct {
+	Plan  string   `json:"plan"`
+	Seats int      `json:"seats"`
+	Flags []string `json:"flags"`
+}
+
+var (
+	tenantClaim   = NewClaim[string]("tenant_id")
+	sessionClaim  = NewClaim[int64]("sid")
+	metadataClaim = NewClaim[appMetadata]("app")
+)
+
+func TestCustomClaims(t *testing.T) {
+
+	metadata := appMetadata{Plan: "pro", Seats: 5, Flags: []string{"beta"}}
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			userId := uuid.New().String()
+			token, err := maker.CreateToken(userId, time.Minute,
+				tenantClaim.With("acme"),
+				sessionClaim.With(42),
+				metadataClaim.With(metadata),
+			)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, userId, payload.UserId)
+			require.Equal(t, userId, payload.Subject)
+
+			tenant, err := tenantClaim.Get(payload)
+			require.NoError(t, err)
+			require.Equal(t, "acme", tenant)
+
+			session, err := sessionClaim.Get(payload)
+			require.NoError(t, err)
+			require.Equal(t, int64(42), session)
+
+			got, err := metadataClaim.Get(payload)
+			require.NoError(t, err)
+			require.Equal(t, metadata, got)
+		})
+	}
+}
+
+func TestMissingClaim(t *testing.T) {
+
+	payload := newTestPayload(t, uuid.New().String(), time.Minute)
+	_, err := tenantClaim.Get(payload)
+	require.ErrorIs(t, err, ErrMissingClaim)
+}
+
+func TestReservedClaims(t *testing.T) {
+
+	for _, name := range []string{"sub", "exp", "iat", "jti", "aud", "iss", "nbf", "ExpiredAt", "UserId", "scope", "cnf", "act", "",
+		// encoding/json matches fields case insensitively
+		"expiredat", "userid", "USERID", "Sub", "EXP", "Id", "\u017fub"} {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewPayload(uuid.New().String(), time.Minute, NewClaim[string](name).With("attacker"))
+			require.ErrorIs(t, err, ErrReservedClaim)
+		})
+	}
+
+	// the maker refuses too, so nothing gets signed
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := maker.CreateToken(uuid.New().String(), time.Minute, NewClaim[string]("sub").With("admin"))
+			require.ErrorIs(t, err, ErrReservedClaim)
+		})
+	}
+}
+
+func TestPayloadWithoutCustomClaims(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Empty(t, payload.Extra)
+		})
+	}
+}
+
+// case variants of a field sort after it and would win on decode if they were signed
+func TestReservedClaimCaseVariants(t *testing.T) {
+
+	userId := uuid.New().String()
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			_, err := maker.CreateToken(userId, time.Minute,
+				NewClaim[time.Time]("expiredat").With(time.Now().Add(1000*time.Hour)),
+			)
+			require.ErrorIs(t, err, ErrReservedClaim)
+			_, err = maker.CreateToken(userId, time.Minute, NewClaim[string]("userid").With("mallory"))
+			require.ErrorIs(t, err, ErrReservedClaim)
+		})
+	}
+
+	// nor are case variants kept as extra claims when decoding
+	extra, err := extraClaims([]byte(`{"userid":"mallory","Exp":1,"tenant_id":"acme"}`))
+	require.NoError(t, err)
+	require.Len(t, extra, 1)
+	require.Contains(t, extra, "tenant_id")
+}
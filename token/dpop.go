@@ -0,0 +1,135 @@
+package token
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrInvalidDPoPProof = errors.New("invalid DPoP proof")
+
+const dpopProofType = "dpop+jwt"
+
+// DPoPProof is what a verified RFC 9449 proof tells about the request
+type DPoPProof struct {
+	// JKT is the thumbprint of the key that signed the proof
+	JKT      string
+	ID       string
+	IssuedAt time.Time
+}
+
+type dpopClaims struct {
+	jwt.RegisteredClaims
+	Method string `json:"htm"`
+	URL    string `json:"htu"`
+	// AccessTokenHash is set when the proof goes with an access token
+	AccessTokenHash string `json:"ath,omitempty"`
+}
+
+// AccessTokenHash is the ath claim a proof must carry for accessToken
+func AccessTokenHash(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return encodeBase64(sum[:])
+}
+
+// VerifyDPoPProof check the proof signature with its embedded jwk and that it was made
+// for this method and url less than maxAge ago, accessToken is empty when a token is requested
+func VerifyDPoPProof(proof string, method string, requestURL string, accessToken string, maxAge time.Duration) (*DPoPProof, error) {
+	var jwk JWK
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		if token.Header["typ"] != dpopProofType {
+			return nil, ErrInvalidDPoPProof
+		}
+		header, ok := token.Header["jwk"].(map[string]interface{})
+		if !ok {
+			return nil, ErrInvalidDPoPProof
+		}
+		// a private key in the header means the client leaked it
+		if _, ok := header["d"]; ok {
+			return nil, ErrInvalidDPoPProof
+		}
+		data, err := json.Marshal(header)
+		if err != nil || json.Unmarshal(data, &jwk) != nil {
+			return nil, ErrInvalidDPoPProof
+		}
+		return jwk.PublicKey()
+	}
+
+	var claims dpopClaims
+	_, err := jwt.ParseWithClaims(proof, &claims, keyFunc,
+		jwt.WithValidMethods([]string{"ES256", "RS256", "EdDSA"}),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	if claims.ID == "" || claims.IssuedAt == nil || claims.Method != method || !sameURL(claims.URL, requestURL) {
+		return nil, ErrInvalidDPoPProof
+	}
+	age := time.Since(claims.IssuedAt.Time)
+	if age > maxAge || age < -maxAge {
+		return nil, ErrInvalidDPoPProof
+	}
+	if accessToken != "" && claims.AccessTokenHash != AccessTokenHash(accessToken) {
+		return nil, ErrInvalidDPoPProof
+	}
+
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return nil, ErrInvalidDPoPProof
+	}
+	return &DPoPProof{JKT: thumbprint, ID: claims.ID, IssuedAt: claims.IssuedAt.Time}, nil
+}
+
+// sameURL compare htu ignoring query and fragment like RFC 9449 section 4.3
+func sameURL(htu string, requestURL string) bool {
+	a, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	b, err := url.Parse(requestURL)
+	if err != nil {
+		return false
+	}
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path
+}
+
+// ReplayCache remember proof ids until they are too old to be accepted anyway
+type ReplayCache struct {
+	mu        sync.Mutex
+	entries   map[string]time.Time
+	lastPrune time.Time
+}
+
+const replayCachePruneInterval = time.Minute
+
+func NewReplayCache() *ReplayCache {
+	return &ReplayCache{entries: make(map[string]time.Time)}
+}
+
+// Seen record id and report whether it was already recorded
+func (cache *ReplayCache) Seen(id string, expiresAt time.Time) bool {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	now := time.Now()
+	if expires, ok := cache.entries[id]; ok && now.Before(expires) {
+		return true
+	}
+	if now.Sub(cache.lastPrune) > replayCachePruneInterval {
+		for key, expires := range cache.entries {
+			if !now.Before(expires) {
+				delete(cache.entries, key)
+			}
+		}
+		cache.lastPrune = now
+	}
+	cache.entries[id] = expiresAt
+	return false
+}
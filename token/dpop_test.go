@@ -0,0 +1,159 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newDPoPProof(t *testing.T, key *ecdsa.PrivateKey, method string, url string, accessToken string, issuedAt time.Time) string {
+	jwk, err := NewJWK(PublicKey{Key: &key.PublicKey})
+	require.NoError(t, err)
+
+	claims := dpopClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       uuid.New().String(),
+			IssuedAt: jwt.NewNumericDate(issuedAt),
+		},
+		Method: method,
+		URL:    url,
+	}
+	if accessToken != "" {
+		claims.AccessTokenHash = AccessTokenHash(accessToken)
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["typ"] = dpopProofType
+	token.Header["jwk"] = jwk
+
+	proof, err := token.SignedString(key)
+	require.NoError(t, err)
+	return proof
+}
+
+func TestVerifyDPoPProof(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, err := NewJWK(PublicKey{Key: &key.PublicKey})
+	require.NoError(t, err)
+	jkt, err := jwk.Thumbprint()
+	require.NoError(t, err)
+
+	url := "https://auth.example.com/me"
+	now := time.Now()
+
+	testCases := []struct {
+		name        string
+		proof       string
+		accessToken string
+		valid       bool
+	}{
+		{
+			name:  "OK",
+			proof: newDPoPProof(t, key, "GET", url, "", now),
+			valid: true,
+		},
+		{
+			name:  "QueryIgnored",
+			proof: newDPoPProof(t, key, "GET", url+"?page=2", "", now),
+			valid: true,
+		},
+		{
+			name:        "AccessTokenHash",
+			proof:       newDPoPProof(t, key, "GET", url, "access-token", now),
+			accessToken: "access-token",
+			valid:       true,
+		},
+		{
+			name:        "WrongAccessToken",
+			proof:       newDPoPProof(t, key, "GET", url, "other-token", now),
+			accessToken: "access-token",
+		},
+		{
+			name:  "WrongMethod",
+			proof: newDPoPProof(t, key, "POST", url, "", now),
+		},
+		{
+			name:  "WrongURL",
+			proof: newDPoPProof(t, key, "GET", "https://auth.example.com/logout", "", now),
+		},
+		{
+			name:  "TooOld",
+			proof: newDPoPProof(t, key, "GET", url, "", now.Add(-2*time.Minute)),
+		},
+		{
+			name:  "Garbage",
+			proof: "not.a.proof",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proof, err := VerifyDPoPProof(tc.proof, "GET", url, tc.accessToken, time.Minute)
+			if !tc.valid {
+				require.EqualError(t, err, ErrInvalidDPoPProof.Error())
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, jkt, proof.JKT)
+			require.NotEmpty(t, proof.ID)
+		})
+	}
+}
+
+func TestDPoPProofWithoutType(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk, err := NewJWK(PublicKey{Key: &key.PublicKey})
+	require.NoError(t, err)
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, dpopClaims{
+		RegisteredClaims: jwt.RegisteredClaims{ID: "id", IssuedAt: jwt.NewNumericDate(time.Now())},
+		Method:           "GET",
+		URL:              "https://auth.example.com/me",
+	})
+	token.Header["jwk"] = jwk
+	proof, err := token.SignedString(key)
+	require.NoError(t, err)
+
+	_, err = VerifyDPoPProof(proof, "GET", "https://auth.example.com/me", "", time.Minute)
+	require.EqualError(t, err, ErrInvalidDPoPProof.Error())
+}
+
+func TestReplayCache(t *testing.T) {
+
+	cache := NewReplayCache()
+	require.False(t, cache.Seen("jti", time.Now().Add(time.Minute)))
+	require.True(t, cache.Seen("jti", time.Now().Add(time.Minute)))
+
+	// expired entries can be seen again
+	require.False(t, cache.Seen("old", time.Now().Add(-time.Second)))
+	require.False(t, cache.Seen("old", time.Now().Add(time.Minute)))
+}
+
+func TestPayloadDPoPKey(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute, WithDPoPKey("thumbprint"))
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "thumbprint", payload.DPoPKey())
+
+			token, err = maker.CreateToken(uuid.New().String(), time.Minute)
+			require.NoError(t, err)
+			payload, err = maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Empty(t, payload.DPoPKey())
+		})
+	}
+}
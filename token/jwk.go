@@ -0,0 +1,181 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// PasetoV4Public is the algorithm of public keys verifying v4.public tokens
+const PasetoV4Public = "v4.public"
+
+// PublicKey is a verification key published by a KeyPublisher
+type PublicKey struct {
+	ID string
+	// Algorithm is a JWS alg name or PasetoV4Public
+	Algorithm string
+	Key       crypto.PublicKey
+}
+
+// JWK is the RFC 7517 representation of a public key
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// NewJWK converts an ed25519, P-256 ecdsa or rsa public key, paseto keys get no alg
+// because it would be read as a JWS algorithm
+func NewJWK(key PublicKey) (JWK, error) {
+	jwk := JWK{KeyID: key.ID, Use: "sig"}
+	if key.Algorithm != PasetoV4Public {
+		jwk.Algorithm = key.Algorithm
+	}
+
+	switch k := key.Key.(type) {
+	case ed25519.PublicKey:
+		jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+		jwk.X = encodeBase64(k)
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType, jwk.Curve = "EC", k.Curve.Params().Name
+		jwk.X = encodeBase64(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64(k.Y.FillBytes(make([]byte, size)))
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encodeBase64(k.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(k.E)).Bytes())
+	default:
+		return JWK{}, fmt.Errorf("unsupported public key type %T", key.Key)
+	}
+	return jwk, nil
+}
+
+func decodeBase64(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
+
+// PublicKey parse the key back, only the curves and sizes NewJWK produces are accepted
+func (jwk JWK) PublicKey() (crypto.PublicKey, error) {
+	switch jwk.KeyType {
+	case "OKP":
+		x, err := decodeBase64(jwk.X)
+		if err != nil || jwk.Curve != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid OKP key")
+		}
+		return ed25519.PublicKey(x), nil
+	case "EC":
+		x, errX := decodeBase64(jwk.X)
+		y, errY := decodeBase64(jwk.Y)
+		if errX != nil || errY != nil || jwk.Curve != elliptic.P256().Params().Name {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		return key, nil
+	case "RSA":
+		n, errN := decodeBase64(jwk.N)
+		e, errE := decodeBase64(jwk.E)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key")
+		}
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("Invalid RSA key size must be at least %d bits\n", minRSAKeyBits)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+}
+
+// Thumbprint is the RFC 7638 SHA-256 thumbprint, base64url encoded
+func (jwk JWK) Thumbprint() (string, error) {
+	// only the required members, in lexicographic order
+	var members interface{}
+	switch jwk.KeyType {
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Curve, jwk.KeyType, jwk.X}
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Curve, jwk.KeyType, jwk.X, jwk.Y}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.KeyType, jwk.N}
+	default:
+		return "", fmt.Errorf("unsupported key type %q", jwk.KeyType)
+	}
+
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encodeBase64(sum[:]), nil
+}
+
+// PASERK is the paserk serialization of a v4.public key with its paserk id
+type PASERK struct {
+	KeyID  string `json:"kid,omitempty"`
+	PASERK string `json:"paserk"`
+	PID    string `json:"pid"`
+}
+
+// NewPASERK serialize a v4.public key as k4.public and compute its k4.pid
+func NewPASERK(key PublicKey) (PASERK, error) {
+	publicKey, ok := key.Key.(ed25519.PublicKey)
+	if key.Algorithm != PasetoV4Public || !ok {
+		return PASERK{}, fmt.Errorf("only %s keys have a paserk", PasetoV4Public)
+	}
+
+	paserk := "k4.public." + encodeBase64(publicKey)
+
+	const pidHeader = "k4.pid."
+	hash, err := blake2b.New(33, nil)
+	if err != nil {
+		return PASERK{}, err
+	}
+	hash.Write([]byte(pidHeader + paserk))
+
+	return PASERK{
+		KeyID:  key.ID,
+		PASERK: paserk,
+		PID:    pidHeader + encodeBase64(hash.Sum(nil)),
+	}, nil
+}
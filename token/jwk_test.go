@@ -0,0 +1,121 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWKThumbprint(t *testing.T) {
+
+	// RFC 8037 appendix A.3
+	x, err := base64.RawURLEncoding.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+	require.NoError(t, err)
+
+	jwk, err := NewJWK(PublicKey{ID: "key-1", Algorithm: "EdDSA", Key: ed25519.PublicKey(x)})
+	require.NoError(t, err)
+	require.Equal(t, "OKP", jwk.KeyType)
+	require.Equal(t, "Ed25519", jwk.Curve)
+	require.Equal(t, "EdDSA", jwk.Algorithm)
+	require.Equal(t, "key-1", jwk.KeyID)
+
+	thumbprint, err := jwk.Thumbprint()
+	require.NoError(t, err)
+	require.Equal(t, "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+}
+
+func TestJWKKeyTypes(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		t.Run(key.algorithm, func(t *testing.T) {
+			jwk, err := NewJWK(PublicKey{Algorithm: key.algorithm, Key: key.publicKey})
+			if key.algorithm == "HS256" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			thumbprint, err := jwk.Thumbprint()
+			require.NoError(t, err)
+			require.NotEmpty(t, thumbprint)
+		})
+	}
+}
+
+func TestJWTMakerPublicKeys(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		t.Run(key.algorithm, func(t *testing.T) {
+			maker, err := NewJWTMaker(key.algorithm, key.privateKey)
+			require.NoError(t, err)
+
+			keys := maker.(KeyPublisher).PublicKeys(time.Now())
+			if key.algorithm == "HS256" {
+				require.Empty(t, keys)
+				return
+			}
+			require.Len(t, keys, 1)
+			require.Equal(t, key.algorithm, keys[0].Algorithm)
+
+			// the published kid is the one in the token header
+			token, err := maker.CreateToken("user", time.Minute)
+			require.NoError(t, err)
+			header, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[0])
+			require.NoError(t, err)
+			require.Contains(t, string(header), `"kid":"`+keys[0].ID+`"`)
+		})
+	}
+}
+
+func TestPASERK(t *testing.T) {
+
+	publicKey, _ := newTestKeyPair(t)
+
+	paserk, err := NewPASERK(PublicKey{ID: "key-1", Algorithm: PasetoV4Public, Key: publicKey})
+	require.NoError(t, err)
+	require.Equal(t, "key-1", paserk.KeyID)
+	require.Equal(t, "k4.public."+base64.RawURLEncoding.EncodeToString(publicKey), paserk.PASERK)
+	require.True(t, strings.HasPrefix(paserk.PID, "k4.pid."))
+	// blake2b-264 digest
+	require.Len(t, strings.TrimPrefix(paserk.PID, "k4.pid."), 44)
+
+	again, err := NewPASERK(PublicKey{Algorithm: PasetoV4Public, Key: publicKey})
+	require.NoError(t, err)
+	require.Equal(t, paserk.PID, again.PID)
+
+	_, err = NewPASERK(PublicKey{Algorithm: "EdDSA", Key: publicKey})
+	require.Error(t, err)
+}
+
+func TestPasetoPublicMakerPublicKeys(t *testing.T) {
+
+	now := time.Now()
+	old := newTestKey("old", now.Add(-2*time.Hour), now.Add(-time.Minute))
+	current := newTestKey("current", now.Add(-time.Hour), time.Time{})
+	next := newTestKey("next", now.Add(time.Hour), time.Time{})
+	for _, key := range []*Key{&old, &current, &next} {
+		key.Material = key.Material[:ed25519.SeedSize]
+	}
+
+	keyring, err := NewKeyring(old, current, next)
+	require.NoError(t, err)
+	maker, err := NewPasetoPublicKeyringMaker(keyring)
+	require.NoError(t, err)
+
+	keys := maker.(KeyPublisher).PublicKeys(now)
+	require.Len(t, keys, 2)
+	require.Equal(t, "current", keys[0].ID)
+	require.Equal(t, "next", keys[1].ID)
+
+	// a token verifies with its published key
+	token, err := maker.CreateToken("user", time.Minute)
+	require.NoError(t, err)
+	verifier, err := NewPasetoPublicVerifier(keys[0].Key.(ed25519.PublicKey))
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(token)
+	require.NoError(t, err)
+}
@@ -0,0 +1,161 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// minJWKSRefresh keeps tokens naming made up keys from hammering the issuer
+	minJWKSRefresh = time.Minute
+	maxJWKSSize    = 1 << 20
+)
+
+// JWKSVerifier verify JWTs of a remote issuer with the keys published at its jwks_uri,
+// the set is fetched again when a token names a key it doesn't know so rotations are picked up
+type JWKSVerifier struct {
+	url    string
+	client *http.Client
+
+	mu sync.Mutex
+	// makers are verify only JWTMakers by key id
+	makers    map[string]Maker
+	fetchedAt time.Time
+}
+
+func NewJWKSVerifier(url string, client *http.Client) *JWKSVerifier {
+	return &JWKSVerifier{url: url, client: client, makers: make(map[string]Maker)}
+}
+
+func (verifier *JWKSVerifier) CreateToken(userId string, duration time.Duration, opts ...PayloadOption) (string, error) {
+	return "", ErrVerifyOnly
+}
+
+func (verifier *JWKSVerifier) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+	keyId, err := jwtKeyID(token)
+	if err != nil {
+		return nil, err
+	}
+	maker, err := verifier.maker(keyId)
+	if err != nil {
+		return nil, err
+	}
+	// the maker is pinned to the algorithm of the key, a header naming another one fails
+	return maker.VerifyToken(token, opts...)
+}
+
+// jwtKeyID read the kid of the header without verifying anything
+func jwtKeyID(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidToken
+	}
+	data, err := decodeBase64(parts[0])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	var header struct {
+		KeyID string `json:"kid"`
+	}
+	err = json.Unmarshal(data, &header)
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	return header.KeyID, nil
+}
+
+// maker returns the verifier of keyId, a token without kid is only accepted from an issuer
+// publishing a single key
+func (verifier *JWKSVerifier) maker(keyId string) (Maker, error) {
+	verifier.mu.Lock()
+	defer verifier.mu.Unlock()
+
+	maker, ok := verifier.lookup(keyId)
+	if ok {
+		return maker, nil
+	}
+	if time.Since(verifier.fetchedAt) < minJWKSRefresh {
+		return nil, ErrUnknownKey
+	}
+
+	err := verifier.refresh()
+	if err != nil {
+		return nil, err
+	}
+	maker, ok = verifier.lookup(keyId)
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return maker, nil
+}
+
+func (verifier *JWKSVerifier) lookup(keyId string) (Maker, bool) {
+	if keyId == "" && len(verifier.makers) == 1 {
+		for _, maker := range verifier.makers {
+			return maker, true
+		}
+	}
+	maker, ok := verifier.makers[keyId]
+	return maker, ok
+}
+
+// refresh replace the keys with the published set, keys this package can't verify with are skipped
+func (verifier *JWKSVerifier) refresh() error {
+	// failed fetches count too, the issuer being down is no reason to retry on every token
+	verifier.fetchedAt = time.Now()
+
+	response, err := verifier.client.Get(verifier.url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("cannot fetch %s : %s", verifier.url, response.Status)
+	}
+
+	var set JWKSet
+	err = json.NewDecoder(io.LimitReader(response.Body, maxJWKSSize)).Decode(&set)
+	if err != nil {
+		return err
+	}
+
+	makers := make(map[string]Maker)
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		maker, err := NewJWTMaker(jwkAlgorithm(jwk), key)
+		if err != nil {
+			continue
+		}
+		makers[jwk.KeyID] = maker
+	}
+	verifier.makers = makers
+	return nil
+}
+
+// jwkAlgorithm is the alg of the key, or the only one this package supports for its type
+// since alg is optional in a JWK
+func jwkAlgorithm(jwk JWK) string {
+	if jwk.Algorithm != "" {
+		return jwk.Algorithm
+	}
+	switch jwk.KeyType {
+	case "RSA":
+		return "RS256"
+	case "EC":
+		return "ES256"
+	case "OKP":
+		return "EdDSA"
+	}
+	return ""
+}
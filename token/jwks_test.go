@@ -0,0 +1,144 @@
+package token
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// jwksTestServer publish the keys of its makers like an issuer jwks_uri
+type jwksTestServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	makers  []Maker
+	fetches int
+}
+
+func newJWKSTestServer(t *testing.T, makers ...Maker) *jwksTestServer {
+	server := &jwksTestServer{makers: makers}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		server.fetches++
+
+		set := JWKSet{Keys: []JWK{}}
+		for _, maker := range server.makers {
+			for _, key := range maker.(KeyPublisher).PublicKeys(time.Now()) {
+				jwk, err := NewJWK(key)
+				require.NoError(t, err)
+				set.Keys = append(set.Keys, jwk)
+			}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(set))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (server *jwksTestServer) publish(makers ...Maker) {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	server.makers = makers
+}
+
+func TestJWKSVerifier(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		if key.algorithm == "HS256" {
+			continue
+		}
+		t.Run(key.algorithm, func(t *testing.T) {
+			maker, err := NewJWTMaker(key.algorithm, key.privateKey)
+			require.NoError(t, err)
+			issuer := newJWKSTestServer(t, maker)
+
+			token, err := maker.CreateToken("user", time.Minute, WithIssuer(issuer.URL), WithSubject("upstream-subject"))
+			require.NoError(t, err)
+
+			verifier := NewJWKSVerifier(issuer.URL, issuer.Client())
+			payload, err := verifier.VerifyToken(token, ExpectIssuer(issuer.URL))
+			require.NoError(t, err)
+			require.Equal(t, "upstream-subject", payload.Subject)
+
+			_, err = verifier.CreateToken("user", time.Minute)
+			require.ErrorIs(t, err, ErrVerifyOnly)
+		})
+	}
+}
+
+func TestJWKSVerifierRotation(t *testing.T) {
+
+	keys := newJWTTestKeys(t)
+	oldMaker, err := NewJWTMaker(keys[2].algorithm, keys[2].privateKey)
+	require.NoError(t, err)
+	newMaker, err := NewJWTMaker(keys[3].algorithm, keys[3].privateKey)
+	require.NoError(t, err)
+
+	issuer := newJWKSTestServer(t, oldMaker)
+	verifier := NewJWKSVerifier(issuer.URL, issuer.Client())
+
+	oldToken, err := oldMaker.CreateToken("user", time.Minute)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(oldToken)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(oldToken)
+	require.NoError(t, err)
+	require.Equal(t, 1, issuer.fetches)
+
+	issuer.publish(newMaker)
+	newToken, err := newMaker.CreateToken("user", time.Minute)
+	require.NoError(t, err)
+
+	// unknown keys don't refetch the set more than once every minJWKSRefresh
+	_, err = verifier.VerifyToken(newToken)
+	require.ErrorIs(t, err, ErrUnknownKey)
+	require.Equal(t, 1, issuer.fetches)
+
+	verifier.fetchedAt = time.Now().Add(-minJWKSRefresh)
+	_, err = verifier.VerifyToken(newToken)
+	require.NoError(t, err)
+	require.Equal(t, 2, issuer.fetches)
+
+	// keys the issuer stopped publishing are forgotten
+	_, err = verifier.VerifyToken(oldToken)
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestJWKSVerifierAlgorithmConfusion(t *testing.T) {
+
+	keys := newJWTTestKeys(t)
+	maker, err := NewJWTMaker(keys[1].algorithm, keys[1].privateKey)
+	require.NoError(t, err)
+	issuer := newJWKSTestServer(t, maker)
+	verifier := NewJWKSVerifier(issuer.URL, issuer.Client())
+
+	keyId := maker.(KeyPublisher).PublicKeys(time.Now())[0].ID
+	payload, err := NewPayload("user", time.Minute)
+	require.NoError(t, err)
+
+	// the published RSA key used as a HS256 secret
+	jwk, err := NewJWK(maker.(KeyPublisher).PublicKeys(time.Now())[0])
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, payload.jwtClaims())
+	forged.Header["kid"] = keyId
+	forgedToken, err := forged.SignedString([]byte(jwk.N))
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(forgedToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	unsigned := jwt.NewWithClaims(jwt.SigningMethodNone, payload.jwtClaims())
+	unsigned.Header["kid"] = keyId
+	unsignedToken, err := unsigned.SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(unsignedToken)
+	require.ErrorIs(t, err, ErrInvalidToken)
+
+	_, err = verifier.VerifyToken("not-a-jwt")
+	require.ErrorIs(t, err, ErrInvalidToken)
+}
@@ -0,0 +1,242 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const (
+	minHMACKeySize = 32
+	minRSAKeyBits  = 2048
+)
+
+// JWTMaker issue and verify JWTs for consumers that don't speak PASETO,
+// each maker is pinned to a single algorithm so "none" and alg confusion fail
+type JWTMaker struct {
+	method     jwt.SigningMethod
+	signingKey interface{}
+	verifyKey  interface{}
+	// keyId is the RFC 7638 thumbprint of asymmetric keys, empty for HS256
+	keyId string
+}
+
+// NewJWTMaker supports HS256 ([]byte secret), RS256 (*rsa key), ES256 (*ecdsa P-256 key)
+// and EdDSA (ed25519 key), giving a public key returns a verify only maker
+func NewJWTMaker(algorithm string, key interface{}) (Maker, error) {
+	maker := &JWTMaker{}
+
+	switch algorithm {
+	case jwt.SigningMethodHS256.Alg():
+		secret, ok := key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("HS256 key must be a byte slice")
+		}
+		if len(secret) < minHMACKeySize {
+			return nil, fmt.Errorf("Invalid HS256 key size must be at least %d\n", minHMACKeySize)
+		}
+		maker.method = jwt.SigningMethodHS256
+		maker.signingKey, maker.verifyKey = secret, secret
+	case jwt.SigningMethodRS256.Alg():
+		maker.method = jwt.SigningMethodRS256
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			maker.signingKey, maker.verifyKey = k, &k.PublicKey
+		case *rsa.PublicKey:
+			maker.verifyKey = k
+		default:
+			return nil, fmt.Errorf("RS256 key must be an rsa key")
+		}
+		if maker.verifyKey.(*rsa.PublicKey).N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("Invalid RS256 key size must be at least %d bits\n", minRSAKeyBits)
+		}
+	case jwt.SigningMethodES256.Alg():
+		maker.method = jwt.SigningMethodES256
+		switch k := key.(type) {
+		case *ecdsa.PrivateKey:
+			maker.signingKey, maker.verifyKey = k, &k.PublicKey
+		case *ecdsa.PublicKey:
+			maker.verifyKey = k
+		default:
+			return nil, fmt.Errorf("ES256 key must be an ecdsa key")
+		}
+		if maker.verifyKey.(*ecdsa.PublicKey).Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 key must use the P-256 curve")
+		}
+	case jwt.SigningMethodEdDSA.Alg():
+		maker.method = jwt.SigningMethodEdDSA
+		switch k := key.(type) {
+		case ed25519.PrivateKey:
+			if len(k) != ed25519.PrivateKeySize {
+				return nil, fmt.Errorf("Invalid private key size must be %d\n", ed25519.PrivateKeySize)
+			}
+			maker.signingKey, maker.verifyKey = k, k.Public()
+		case ed25519.PublicKey:
+			if len(k) != ed25519.PublicKeySize {
+				return nil, fmt.Errorf("Invalid public key size must be %d\n", ed25519.PublicKeySize)
+			}
+			maker.verifyKey = k
+		default:
+			return nil, fmt.Errorf("EdDSA key must be an ed25519 key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", algorithm)
+	}
+
+	if maker.method != jwt.SigningMethodHS256 {
+		jwk, err := NewJWK(PublicKey{Algorithm: algorithm, Key: maker.verifyKey})
+		if err != nil {
+			return nil, err
+		}
+		maker.keyId, err = jwk.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return maker, nil
+}
+
+func (maker *JWTMaker) CreateToken(userId string, duration time.Duration, opts ...PayloadOption) (string, error) {
+	if maker.signingKey == nil {
+		return "", ErrVerifyOnly
+	}
+
+	payload, err := NewPayload(userId, duration, opts...)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(maker.method, payload.jwtClaims())
+	if maker.keyId != "" {
+		token.Header["kid"] = maker.keyId
+	}
+	return token.SignedString(maker.signingKey)
+}
+
+// PublicKeys returns the verification key, a HS256 secret is never published
+func (maker *JWTMaker) PublicKeys(now time.Time) []PublicKey {
+	if maker.keyId == "" {
+		return nil
+	}
+	return []PublicKey{{ID: maker.keyId, Algorithm: maker.method.Alg(), Key: maker.verifyKey}}
+}
+
+func (maker *JWTMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		// never trust the header, WithValidMethods already checks it but be explicit
+		if token.Method.Alg() != maker.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return maker.verifyKey, nil
+	}
+
+	var claims jwtClaims
+	_, err := jwt.ParseWithClaims(token, &claims, keyFunc,
+		jwt.WithValidMethods([]string{maker.method.Alg()}),
+		// expiration is checked by payload.Valid like the other makers
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := claims.payload()
+	if err != nil {
+		return nil, err
+	}
+
+	valid, err := payload.Valid(opts...)
+	if !valid {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// jwtClaims map the payload to the registered claims, what has no registered
+// name gets a private one
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	UserId string   `json:"uid,omitempty"`
+	Roles  []string `json:"roles,omitempty"`
+	// space separated like OAuth 2.0 scope parameter
+	Scope        string                     `json:"scope,omitempty"`
+	ClientID     string                     `json:"client_id,omitempty"`
+	Confirmation *Confirmation              `json:"cnf,omitempty"`
+	Actor        *Actor                     `json:"act,omitempty"`
+	Fingerprint  string                     `json:"fgp,omitempty"`
+	Extra        map[string]json.RawMessage `json:"-"`
+}
+
+type jwtClaimsFields jwtClaims
+
+func (claims jwtClaims) MarshalJSON() ([]byte, error) {
+	return marshalWithExtra(jwtClaimsFields(claims), claims.Extra)
+}
+
+func (claims *jwtClaims) UnmarshalJSON(data []byte) error {
+	err := json.Unmarshal(data, (*jwtClaimsFields)(claims))
+	if err != nil {
+		return err
+	}
+	claims.Extra, err = extraClaims(data)
+	return err
+}
+
+func (payload *Payload) jwtClaims() jwtClaims {
+	claims := jwtClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        payload.ID,
+			Issuer:    payload.Issuer,
+			Subject:   payload.Subject,
+			Audience:  payload.Audience,
+			IssuedAt:  jwt.NewNumericDate(payload.IssuedAt),
+			ExpiresAt: jwt.NewNumericDate(payload.ExpiredAt),
+		},
+		UserId:       payload.UserId,
+		Roles:        payload.Roles,
+		Scope:        strings.Join(payload.Scopes, " "),
+		ClientID:     payload.ClientID,
+		Confirmation: payload.Confirmation,
+		Actor:        payload.Actor,
+		Fingerprint:  payload.Fingerprint,
+		Extra:        payload.Extra,
+	}
+	if !payload.NotBefore.IsZero() {
+		claims.NotBefore = jwt.NewNumericDate(payload.NotBefore)
+	}
+	return claims
+}
+
+func (claims *jwtClaims) payload() (*Payload, error) {
+	if claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return nil, ErrInvalidToken
+	}
+	payload := &Payload{
+		ID:           claims.ID,
+		UserId:       claims.UserId,
+		IssuedAt:     claims.IssuedAt.Time,
+		ExpiredAt:    claims.ExpiresAt.Time,
+		Issuer:       claims.Issuer,
+		Audience:     claims.Audience,
+		Subject:      claims.Subject,
+		Roles:        claims.Roles,
+		Scopes:       strings.Fields(claims.Scope),
+		ClientID:     claims.ClientID,
+		Confirmation: claims.Confirmation,
+		Actor:        claims.Actor,
+		Fingerprint:  claims.Fingerprint,
+		Extra:        claims.Extra,
+	}
+	if claims.NotBefore != nil {
+		payload.NotBefore = claims.NotBefore.Time
+	}
+	return payload, nil
+}
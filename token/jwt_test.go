@@ -0,0 +1,193 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+type jwtTestKey struct {
+	algorithm  string
+	privateKey interface{}
+	publicKey  interface{}
+}
+
+func newJWTTestKeys(t *testing.T) []jwtTestKey {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	edPublic, edPrivate := newTestKeyPair(t)
+	secret := []byte(utils.RandomString(32))
+
+	return []jwtTestKey{
+		{algorithm: "HS256", privateKey: secret, publicKey: secret},
+		{algorithm: "RS256", privateKey: rsaKey, publicKey: &rsaKey.PublicKey},
+		{algorithm: "ES256", privateKey: ecKey, publicKey: &ecKey.PublicKey},
+		{algorithm: "EdDSA", privateKey: edPrivate, publicKey: edPublic},
+	}
+}
+
+func TestJWTValidToken(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		t.Run(key.algorithm, func(t *testing.T) {
+			id := uuid.New().String()
+
+			maker, err := NewJWTMaker(key.algorithm, key.privateKey)
+			require.NoError(t, err)
+
+			token, err := maker.CreateToken(id, time.Minute)
+			require.NoError(t, err)
+			require.NotEmpty(t, token)
+
+			verifier, err := NewJWTMaker(key.algorithm, key.publicKey)
+			require.NoError(t, err)
+
+			payload, err := verifier.VerifyToken(token)
+			require.NoError(t, err)
+			require.NotEmpty(t, payload)
+
+			require.Equal(t, payload.UserId, id)
+			require.NotEmpty(t, payload.ID)
+			require.WithinDuration(t, payload.IssuedAt, time.Now(), time.Second)
+			require.WithinDuration(t, payload.ExpiredAt, time.Now().Add(time.Minute), time.Second)
+		})
+	}
+}
+
+func TestJWTExpiredToken(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		t.Run(key.algorithm, func(t *testing.T) {
+			maker, err := NewJWTMaker(key.algorithm, key.privateKey)
+			require.NoError(t, err)
+
+			token, err := maker.CreateToken(uuid.New().String(), -time.Minute)
+			require.NoError(t, err)
+
+			_, err = maker.VerifyToken(token)
+			require.EqualError(t, err, ErrExpiredToken.Error())
+		})
+	}
+}
+
+func TestJWTInvalidToken(t *testing.T) {
+
+	keys1 := newJWTTestKeys(t)
+	keys2 := newJWTTestKeys(t)
+
+	for i := range keys1 {
+		t.Run(keys1[i].algorithm, func(t *testing.T) {
+			maker1, err := NewJWTMaker(keys1[i].algorithm, keys1[i].privateKey)
+			require.NoError(t, err)
+
+			maker2, err := NewJWTMaker(keys2[i].algorithm, keys2[i].privateKey)
+			require.NoError(t, err)
+
+			token, err := maker1.CreateToken(uuid.New().String(), time.Minute)
+			require.NoError(t, err)
+
+			_, err = maker2.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+		})
+	}
+}
+
+func TestJWTAlgNone(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t) {
+		t.Run(key.algorithm, func(t *testing.T) {
+			payload, err := NewPayload(uuid.New().String(), time.Minute)
+			require.NoError(t, err)
+			token, err := jwt.NewWithClaims(jwt.SigningMethodNone, payload.jwtClaims()).
+				SignedString(jwt.UnsafeAllowNoneSignatureType)
+			require.NoError(t, err)
+
+			maker, err := NewJWTMaker(key.algorithm, key.privateKey)
+			require.NoError(t, err)
+
+			_, err = maker.VerifyToken(token)
+			require.EqualError(t, err, ErrInvalidToken.Error())
+		})
+	}
+}
+
+func TestJWTAlgConfusion(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	// sign with HS256 using the public key as secret, the classic confusion attack
+	payload, err := NewPayload(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, payload.jwtClaims()).
+		SignedString(publicPEM)
+	require.NoError(t, err)
+
+	verifier, err := NewJWTMaker("RS256", &rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	_, err = verifier.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	// a token from a different algorithm is rejected even when the key could verify it
+	hmacMaker, err := NewJWTMaker("HS256", []byte(utils.RandomString(32)))
+	require.NoError(t, err)
+	hmacToken, err := hmacMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	_, err = verifier.VerifyToken(hmacToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestJWTVerifierCannotCreate(t *testing.T) {
+
+	for _, key := range newJWTTestKeys(t)[1:] {
+		t.Run(key.algorithm, func(t *testing.T) {
+			verifier, err := NewJWTMaker(key.algorithm, key.publicKey)
+			require.NoError(t, err)
+
+			_, err = verifier.CreateToken(uuid.New().String(), time.Minute)
+			require.EqualError(t, err, ErrVerifyOnly.Error())
+		})
+	}
+}
+
+func TestJWTInvalidKey(t *testing.T) {
+
+	_, err := NewJWTMaker("HS256", []byte("aaa"))
+	require.Error(t, err)
+
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	_, err = NewJWTMaker("RS256", smallKey)
+	require.Error(t, err)
+
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = NewJWTMaker("ES256", p384Key)
+	require.Error(t, err)
+
+	_, err = NewJWTMaker("EdDSA", []byte(utils.RandomString(32)))
+	require.Error(t, err)
+
+	_, err = NewJWTMaker("none", nil)
+	require.Error(t, err)
+}
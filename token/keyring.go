@@ -0,0 +1,134 @@
+package token
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+var ErrNoActiveKey = errors.New("no active token key")
+var ErrUnknownKey = errors.New("unknown or retired token key")
+
+// Key is one signing/encryption key of a keyring, it mints new tokens from
+// ActiveAt until a newer key becomes active and verifies tokens until RetireAt
+type Key struct {
+	ID       string
+	Material []byte
+	ActiveAt time.Time
+	// zero RetireAt means the key is never retired
+	RetireAt time.Time
+}
+
+func (key Key) retired(now time.Time) bool {
+	return !key.RetireAt.IsZero() && !now.Before(key.RetireAt)
+}
+
+// Keyring holds every key that may still verify tokens so rotating keys
+// doesn't log out users holding tokens from the previous one
+type Keyring struct {
+	keys []Key
+}
+
+func NewKeyring(keys ...Key) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("keyring needs at least one key")
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		if seen[key.ID] {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = true
+		if !key.RetireAt.IsZero() && !key.RetireAt.After(key.ActiveAt) {
+			return nil, fmt.Errorf("key %q must retire after it becomes active", key.ID)
+		}
+	}
+
+	sorted := append([]Key(nil), keys...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ActiveAt.Before(sorted[j].ActiveAt)
+	})
+	return &Keyring{keys: sorted}, nil
+}
+
+// ActiveKey returns the most recently activated key that isn't retired
+func (ring *Keyring) ActiveKey(now time.Time) (Key, error) {
+	for i := len(ring.keys) - 1; i >= 0; i-- {
+		key := ring.keys[i]
+		if !key.ActiveAt.After(now) && !key.retired(now) {
+			return key, nil
+		}
+	}
+	return Key{}, ErrNoActiveKey
+}
+
+// Key returns the key with the given id as long as it isn't retired
+func (ring *Keyring) Key(id string, now time.Time) (Key, error) {
+	for _, key := range ring.keys {
+		if key.ID == id {
+			if key.retired(now) {
+				return Key{}, ErrUnknownKey
+			}
+			return key, nil
+		}
+	}
+	return Key{}, ErrUnknownKey
+}
+
+// Keys returns every key ordered by activation time
+func (ring *Keyring) Keys() []Key {
+	return append([]Key(nil), ring.keys...)
+}
+
+// Unretired returns the keys that can still verify tokens at now, including the ones
+// that aren't active yet
+func (ring *Keyring) Unretired(now time.Time) []Key {
+	var keys []Key
+	for _, key := range ring.keys {
+		if !key.retired(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+type keyringFileEntry struct {
+	ID       string    `json:"id"`
+	Key      string    `json:"key"`
+	ActiveAt time.Time `json:"active_at"`
+	RetireAt time.Time `json:"retire_at"`
+}
+
+// LoadKeyring reads a json array of {"id", "key" (hex), "active_at", "retire_at"}
+func LoadKeyring(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []keyringFileEntry
+	err = json.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0, len(entries))
+	for _, entry := range entries {
+		material, err := hex.DecodeString(entry.Key)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not hex encoded", entry.ID)
+		}
+		keys = append(keys, Key{
+			ID:       entry.ID,
+			Material: material,
+			ActiveAt: entry.ActiveAt,
+			RetireAt: entry.RetireAt,
+		})
+	}
+	return NewKeyring(keys...)
+}
@@ -0,0 +1,191 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/o1egl/paseto"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKey(id string, activeAt time.Time, retireAt time.Time) Key {
+	return Key{
+		ID:       id,
+		Material: []byte(utils.RandomString(32)),
+		ActiveAt: activeAt,
+		RetireAt: retireAt,
+	}
+}
+
+func TestKeyringActiveKey(t *testing.T) {
+
+	now := time.Now()
+	old := newTestKey("old", now.Add(-2*time.Hour), now.Add(time.Hour))
+	current := newTestKey("current", now.Add(-time.Hour), time.Time{})
+	next := newTestKey("next", now.Add(time.Hour), time.Time{})
+
+	keyring, err := NewKeyring(next, old, current)
+	require.NoError(t, err)
+
+	key, err := keyring.ActiveKey(now)
+	require.NoError(t, err)
+	require.Equal(t, current.ID, key.ID)
+
+	key, err = keyring.ActiveKey(now.Add(2 * time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, next.ID, key.ID)
+
+	// old is still usable for verification until it retires
+	_, err = keyring.Key(old.ID, now)
+	require.NoError(t, err)
+	_, err = keyring.Key(old.ID, now.Add(time.Hour))
+	require.EqualError(t, err, ErrUnknownKey.Error())
+
+	_, err = keyring.Key("missing", now)
+	require.EqualError(t, err, ErrUnknownKey.Error())
+
+	keys := keyring.Keys()
+	require.Len(t, keys, 3)
+	require.Equal(t, []string{"old", "current", "next"}, []string{keys[0].ID, keys[1].ID, keys[2].ID})
+}
+
+func TestKeyringNoActiveKey(t *testing.T) {
+
+	now := time.Now()
+	keyring, err := NewKeyring(newTestKey("next", now.Add(time.Hour), time.Time{}))
+	require.NoError(t, err)
+
+	_, err = keyring.ActiveKey(now)
+	require.EqualError(t, err, ErrNoActiveKey.Error())
+}
+
+func TestInvalidKeyring(t *testing.T) {
+
+	now := time.Now()
+
+	_, err := NewKeyring()
+	require.Error(t, err)
+
+	_, err = NewKeyring(newTestKey("a", now, time.Time{}), newTestKey("a", now, time.Time{}))
+	require.Error(t, err)
+
+	_, err = NewKeyring(newTestKey("a", now, now.Add(-time.Hour)))
+	require.Error(t, err)
+}
+
+func TestLoadKeyring(t *testing.T) {
+
+	now := time.Now().UTC().Truncate(time.Second)
+	key := newTestKey("2023-01", now, now.Add(time.Hour))
+
+	data, err := json.Marshal([]keyringFileEntry{
+		{ID: key.ID, Key: hex.EncodeToString(key.Material), ActiveAt: key.ActiveAt, RetireAt: key.RetireAt},
+	})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	keyring, err := LoadKeyring(path)
+	require.NoError(t, err)
+	require.Equal(t, []Key{key}, keyring.Keys())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"id": "a", "key": "zz"}]`), 0600))
+	_, err = LoadKeyring(path)
+	require.Error(t, err)
+}
+
+func TestPasetoKeyRotation(t *testing.T) {
+
+	now := time.Now()
+	oldKey := newTestKey("old", now.Add(-time.Hour), now.Add(time.Hour))
+	newKey := newTestKey("new", now.Add(-time.Minute), time.Time{})
+
+	oldMaker, err := NewPasetoKeyringMaker(mustKeyring(t, oldKey))
+	require.NoError(t, err)
+	oldToken, err := oldMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoKeyringMaker(mustKeyring(t, oldKey, newKey))
+	require.NoError(t, err)
+	newToken, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	var footer keyFooter
+	require.NoError(t, paseto.ParseFooter(newToken, &footer))
+	require.Equal(t, newKey.ID, footer.KeyID)
+
+	// tokens from the previous key keep working until it retires
+	for _, token := range []string{oldToken, newToken} {
+		_, err = maker.VerifyToken(token)
+		require.NoError(t, err)
+	}
+
+	retiredKey := oldKey
+	retiredKey.RetireAt = now.Add(-time.Second)
+	retiredKey.ActiveAt = now.Add(-time.Hour)
+	retiredMaker, err := NewPasetoKeyringMaker(mustKeyring(t, retiredKey, newKey))
+	require.NoError(t, err)
+
+	_, err = retiredMaker.VerifyToken(oldToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	// a token naming an unknown key is rejected
+	strangerMaker, err := NewPasetoKeyringMaker(mustKeyring(t, newTestKey("stranger", now.Add(-time.Hour), time.Time{})))
+	require.NoError(t, err)
+	strangerToken, err := strangerMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(strangerToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestPasetoPublicKeyRotation(t *testing.T) {
+
+	now := time.Now()
+	_, oldPrivate := newTestKeyPair(t)
+	_, newPrivate := newTestKeyPair(t)
+	oldKey := Key{ID: "old", Material: oldPrivate.Seed(), ActiveAt: now.Add(-time.Hour), RetireAt: now.Add(time.Hour)}
+	newKey := Key{ID: "new", Material: newPrivate.Seed(), ActiveAt: now.Add(-time.Minute)}
+
+	oldMaker, err := NewPasetoPublicKeyringMaker(mustKeyring(t, oldKey))
+	require.NoError(t, err)
+	oldToken, err := oldMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	maker, err := NewPasetoPublicKeyringMaker(mustKeyring(t, oldKey, newKey))
+	require.NoError(t, err)
+	newToken, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 3, strings.Count(newToken, "."))
+
+	for _, token := range []string{oldToken, newToken} {
+		_, err = maker.VerifyToken(token)
+		require.NoError(t, err)
+	}
+
+	// a verifier with only the new public key accepts the new tokens
+	verifier, err := NewPasetoPublicVerifier(newPrivate.Public().(ed25519.PublicKey))
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(newToken)
+	require.NoError(t, err)
+	_, err = verifier.VerifyToken(oldToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = NewPasetoPublicKeyringMaker(mustKeyring(t, Key{ID: "bad", Material: []byte("aaa")}))
+	require.Error(t, err)
+}
+
+func mustKeyring(t *testing.T, keys ...Key) *Keyring {
+	keyring, err := NewKeyring(keys...)
+	require.NoError(t, err)
+	return keyring
+}
@@ -0,0 +1,237 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	macaroonPrefix     = "mc1."
+	minMacaroonKeySize = 32
+)
+
+var ErrCaveatNotSatisfied = errors.New("the request doesn't satisfy a caveat of this token")
+
+// Caveat restricts what a macaroon can be used for, once added it can't be removed
+type Caveat string
+
+const (
+	caveatExpires  = "expires"
+	caveatPath     = "path"
+	caveatMethod   = "method"
+	caveatIP       = "ip"
+	caveatReadOnly = "read-only"
+)
+
+// ExpiresBefore caveat, the token expires at t if that's before its own expiration
+func ExpiresBefore(t time.Time) Caveat {
+	return Caveat(caveatExpires + " " + t.UTC().Format(time.RFC3339))
+}
+
+// PathPrefix caveat, the token only works for prefix and the paths under it
+func PathPrefix(prefix string) Caveat {
+	return Caveat(caveatPath + " " + path.Clean("/"+prefix))
+}
+
+// Methods caveat, the token only works for the given HTTP methods
+func Methods(methods ...string) Caveat {
+	return Caveat(caveatMethod + " " + strings.ToUpper(strings.Join(methods, ",")))
+}
+
+// IPRange caveat, the token only works from clients in the cidr range
+func IPRange(cidr string) (Caveat, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", err
+	}
+	return Caveat(caveatIP + " " + network.String()), nil
+}
+
+// ReadOnly caveat, the token only works for GET, HEAD and OPTIONS requests
+func ReadOnly() Caveat {
+	return Caveat(caveatReadOnly)
+}
+
+func (caveat Caveat) split() (string, string) {
+	name, value, _ := strings.Cut(string(caveat), " ")
+	return name, value
+}
+
+// CaveatRequest is what the caveats of a token are checked against
+type CaveatRequest struct {
+	Method string
+	Path   string
+	IP     net.IP
+}
+
+// CheckCaveats returns ErrCaveatNotSatisfied unless every caveat of the token allows the request,
+// tokens of the other makers have no caveats
+func (payload *Payload) CheckCaveats(request CaveatRequest) error {
+	for _, caveat := range payload.Caveats {
+		if !caveat.allows(request) {
+			return fmt.Errorf("%w : %s", ErrCaveatNotSatisfied, caveat)
+		}
+	}
+	return nil
+}
+
+func (caveat Caveat) allows(request CaveatRequest) bool {
+	name, value := caveat.split()
+	switch name {
+	case caveatExpires:
+		// already applied to ExpiredAt by VerifyToken
+		return true
+	case caveatPath:
+		requestPath := path.Clean("/" + request.Path)
+		return value == "/" || requestPath == value || strings.HasPrefix(requestPath, value+"/")
+	case caveatMethod:
+		for _, method := range strings.Split(value, ",") {
+			if method == request.Method {
+				return true
+			}
+		}
+		return false
+	case caveatIP:
+		_, network, err := net.ParseCIDR(value)
+		return err == nil && request.IP != nil && network.Contains(request.IP)
+	case caveatReadOnly:
+		return request.Method == "GET" || request.Method == "HEAD" || request.Method == "OPTIONS"
+	}
+	return false
+}
+
+// MacaroonMaker issue macaroon style tokens : the payload is the identifier and each caveat
+// chains the signature with HMAC(signature, caveat), so any holder can add a caveat with
+// AttenuateMacaroon but removing one needs a signature only the root secret can compute
+//
+//	mc1.<payload>.<caveat>...<caveat>.<signature>
+type MacaroonMaker struct {
+	rootKey []byte
+}
+
+func NewMacaroonMaker(rootKey string) (Maker, error) {
+	if len(rootKey) < minMacaroonKeySize {
+		return nil, fmt.Errorf("Invalid macaroon root key size must be at least %d\n", minMacaroonKeySize)
+	}
+	return &MacaroonMaker{rootKey: []byte(rootKey)}, nil
+}
+
+func (maker *MacaroonMaker) CreateToken(userId string, duration time.Duration, opts ...PayloadOption) (string, error) {
+	payload, err := NewPayload(userId, duration, opts...)
+	if err != nil {
+		return "", err
+	}
+	identifier, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	signature := macaroonHMAC(maker.rootKey, identifier)
+	return macaroonPrefix + encodeBase64(identifier) + "." + encodeBase64(signature), nil
+}
+
+func (maker *MacaroonMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+	identifier, caveats, signature, err := parseMacaroon(token)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := macaroonHMAC(maker.rootKey, identifier)
+	for _, caveat := range caveats {
+		expected = macaroonHMAC(expected, []byte(caveat))
+	}
+	if !hmac.Equal(expected, signature) {
+		return nil, ErrInvalidToken
+	}
+
+	var payload Payload
+	err = json.Unmarshal(identifier, &payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	for _, caveat := range caveats {
+		name, value := caveat.split()
+		switch name {
+		case caveatExpires:
+			expires, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, ErrInvalidToken
+			}
+			if expires.Before(payload.ExpiredAt) {
+				payload.ExpiredAt = expires
+			}
+		case caveatPath, caveatMethod, caveatIP, caveatReadOnly:
+		default:
+			// a caveat we can't check must never be ignored
+			return nil, fmt.Errorf("%w : %s", ErrCaveatNotSatisfied, caveat)
+		}
+	}
+	payload.Caveats = caveats
+
+	valid, err := payload.Valid(opts...)
+	if !valid {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// AttenuateMacaroon add caveats to a macaroon, it doesn't need the root secret
+func AttenuateMacaroon(token string, caveats ...Caveat) (string, error) {
+	_, _, signature, err := parseMacaroon(token)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{token[:strings.LastIndex(token, ".")]}
+	for _, caveat := range caveats {
+		if caveat == "" {
+			return "", fmt.Errorf("empty caveat")
+		}
+		signature = macaroonHMAC(signature, []byte(caveat))
+		parts = append(parts, encodeBase64([]byte(caveat)))
+	}
+	parts = append(parts, encodeBase64(signature))
+	return strings.Join(parts, "."), nil
+}
+
+func parseMacaroon(token string) ([]byte, []Caveat, []byte, error) {
+	if !strings.HasPrefix(token, macaroonPrefix) {
+		return nil, nil, nil, ErrInvalidToken
+	}
+	parts := strings.Split(strings.TrimPrefix(token, macaroonPrefix), ".")
+	if len(parts) < 2 {
+		return nil, nil, nil, ErrInvalidToken
+	}
+
+	identifier, err := decodeBase64(parts[0])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidToken
+	}
+	signature, err := decodeBase64(parts[len(parts)-1])
+	if err != nil || len(signature) != sha256.Size {
+		return nil, nil, nil, ErrInvalidToken
+	}
+
+	var caveats []Caveat
+	for _, part := range parts[1 : len(parts)-1] {
+		caveat, err := decodeBase64(part)
+		if err != nil {
+			return nil, nil, nil, ErrInvalidToken
+		}
+		caveats = append(caveats, Caveat(caveat))
+	}
+	return identifier, caveats, signature, nil
+}
+
+func macaroonHMAC(key []byte, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
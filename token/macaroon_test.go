@@ -0,0 +1,230 @@
+package token
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMacaroonMaker(t *testing.T) Maker {
+	maker, err := NewMacaroonMaker(utils.RandomString(32))
+	require.NoError(t, err)
+	return maker
+}
+
+func TestMacaroonValidToken(t *testing.T) {
+
+	maker := newTestMacaroonMaker(t)
+
+	userId := uuid.New().String()
+	token, err := maker.CreateToken(userId, time.Minute, WithScopes("orders:read"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, macaroonPrefix))
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, userId, payload.UserId)
+	require.Equal(t, []string{"orders:read"}, payload.Scopes)
+	require.Empty(t, payload.Caveats)
+}
+
+func TestMacaroonExpiredToken(t *testing.T) {
+
+	maker := newTestMacaroonMaker(t)
+
+	token, err := maker.CreateToken(uuid.New().String(), -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestMacaroonInvalidKey(t *testing.T) {
+
+	_, err := NewMacaroonMaker(utils.RandomString(16))
+	require.Error(t, err)
+
+	token, err := newTestMacaroonMaker(t).CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	_, err = newTestMacaroonMaker(t).VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestAttenuateMacaroon(t *testing.T) {
+
+	maker := newTestMacaroonMaker(t)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	ipRange, err := IPRange("10.0.0.0/8")
+	require.NoError(t, err)
+	attenuated, err := AttenuateMacaroon(token, PathPrefix("/me"), ipRange)
+	require.NoError(t, err)
+	// caveats can be added by later holders too
+	attenuated, err = AttenuateMacaroon(attenuated, ReadOnly())
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(attenuated)
+	require.NoError(t, err)
+	require.Equal(t, []Caveat{PathPrefix("/me"), ipRange, ReadOnly()}, payload.Caveats)
+
+	// dropping a caveat breaks the signature chain
+	parts := strings.Split(attenuated, ".")
+	removed := strings.Join(append(parts[:2:2], parts[3:]...), ".")
+	_, err = maker.VerifyToken(removed)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	// so does replacing one
+	parts[2] = encodeBase64([]byte(PathPrefix("/")))
+	_, err = maker.VerifyToken(strings.Join(parts, "."))
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	_, err = AttenuateMacaroon("v4.local.not-a-macaroon", ReadOnly())
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestMacaroonExpiresCaveat(t *testing.T) {
+
+	maker := newTestMacaroonMaker(t)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Hour)
+	require.NoError(t, err)
+
+	expires := time.Now().Add(time.Minute).Truncate(time.Second)
+	attenuated, err := AttenuateMacaroon(token, ExpiresBefore(expires))
+	require.NoError(t, err)
+	payload, err := maker.VerifyToken(attenuated)
+	require.NoError(t, err)
+	require.WithinDuration(t, expires, payload.ExpiredAt, 0)
+
+	// a later expiration can't extend the token
+	attenuated, err = AttenuateMacaroon(token, ExpiresBefore(time.Now().Add(48*time.Hour)))
+	require.NoError(t, err)
+	payload, err = maker.VerifyToken(attenuated)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(time.Hour), payload.ExpiredAt, time.Second)
+
+	attenuated, err = AttenuateMacaroon(token, ExpiresBefore(time.Now().Add(-time.Second)))
+	require.NoError(t, err)
+	_, err = maker.VerifyToken(attenuated)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+}
+
+func TestMacaroonUnknownCaveat(t *testing.T) {
+
+	maker := newTestMacaroonMaker(t)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	attenuated, err := AttenuateMacaroon(token, Caveat("tenant acme"))
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(attenuated)
+	require.ErrorIs(t, err, ErrCaveatNotSatisfied)
+}
+
+func TestCheckCaveats(t *testing.T) {
+
+	ipRange, err := IPRange("192.168.1.0/24")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name    string
+		caveats []Caveat
+		request CaveatRequest
+		allowed bool
+	}{
+		{
+			name:    "NoCaveat",
+			request: CaveatRequest{Method: "DELETE", Path: "/admin/users/bob"},
+			allowed: true,
+		},
+		{
+			name:    "PathPrefix",
+			caveats: []Caveat{PathPrefix("/admin/users/")},
+			request: CaveatRequest{Method: "GET", Path: "/admin/users/bob"},
+			allowed: true,
+		},
+		{
+			name:    "PathPrefixExact",
+			caveats: []Caveat{PathPrefix("/me")},
+			request: CaveatRequest{Method: "GET", Path: "/me"},
+			allowed: true,
+		},
+		{
+			name:    "PathPrefixSibling",
+			caveats: []Caveat{PathPrefix("/me")},
+			request: CaveatRequest{Method: "GET", Path: "/metrics"},
+		},
+		{
+			name:    "PathTraversal",
+			caveats: []Caveat{PathPrefix("/me")},
+			request: CaveatRequest{Method: "GET", Path: "/me/../admin/users"},
+		},
+		{
+			name:    "Methods",
+			caveats: []Caveat{Methods("get", "post")},
+			request: CaveatRequest{Method: "POST", Path: "/logout"},
+			allowed: true,
+		},
+		{
+			name:    "MethodNotAllowed",
+			caveats: []Caveat{Methods("GET")},
+			request: CaveatRequest{Method: "POST", Path: "/logout"},
+		},
+		{
+			name:    "ReadOnly",
+			caveats: []Caveat{ReadOnly()},
+			request: CaveatRequest{Method: "HEAD", Path: "/me"},
+			allowed: true,
+		},
+		{
+			name:    "ReadOnlyWrite",
+			caveats: []Caveat{ReadOnly()},
+			request: CaveatRequest{Method: "POST", Path: "/logout"},
+		},
+		{
+			name:    "IPRange",
+			caveats: []Caveat{ipRange},
+			request: CaveatRequest{Method: "GET", Path: "/me", IP: net.ParseIP("192.168.1.42")},
+			allowed: true,
+		},
+		{
+			name:    "IPOutOfRange",
+			caveats: []Caveat{ipRange},
+			request: CaveatRequest{Method: "GET", Path: "/me", IP: net.ParseIP("192.168.2.42")},
+		},
+		{
+			name:    "NoIP",
+			caveats: []Caveat{ipRange},
+			request: CaveatRequest{Method: "GET", Path: "/me"},
+		},
+		{
+			name:    "EveryCaveat",
+			caveats: []Caveat{PathPrefix("/me"), ReadOnly()},
+			request: CaveatRequest{Method: "POST", Path: "/me"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			payload := &Payload{Caveats: tc.caveats}
+			err := payload.CheckCaveats(tc.request)
+			if tc.allowed {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, ErrCaveatNotSatisfied)
+			}
+		})
+	}
+
+	_, err = IPRange("not a range")
+	require.Error(t, err)
+}
@@ -3,6 +3,13 @@ package token
 import "time"
 
 type Maker interface {
-	CreateToken(id string, duration time.Duration)(string, error)
-	VerifyToken(token string)(*Payload, error)
+	CreateToken(id string, duration time.Duration, opts ...PayloadOption)(string, error)
+	VerifyToken(token string, opts ...VerifyOption)(*Payload, error)
+}
+
+// KeyPublisher is implemented by makers whose tokens can be verified with public keys,
+// PublicKeys returns every key that isn't retired at now, including ones not yet active
+// so verifiers already know them when they start signing
+type KeyPublisher interface {
+	PublicKeys(now time.Time) []PublicKey
 }
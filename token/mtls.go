@@ -0,0 +1,12 @@
+package token
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+)
+
+// CertificateThumbprint is the x5t#S256 of a certificate, base64url sha256 of its DER
+func CertificateThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return encodeBase64(sum[:])
+}
@@ -0,0 +1,80 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+)
+
+const opaqueTokenSize = 32
+
+// OpaqueMaker issue random handles carrying no claims, the payload lives in the
+// database next to the handle hash so deleting the row revokes the token at once
+type OpaqueMaker struct {
+	store db.Store
+}
+
+func NewOpaqueMaker(store db.Store) (Maker, error) {
+	return &OpaqueMaker{store: store}, nil
+}
+
+func (maker *OpaqueMaker) CreateToken(userId string, duration time.Duration, opts ...PayloadOption) (string, error) {
+	payload, err := NewPayload(userId, duration, opts...)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := utils.RandomSecureToken(opaqueTokenSize)
+	if err != nil {
+		return "", err
+	}
+
+	err = maker.store.CreateOpaqueToken(context.Background(), db.CreateOpaqueTokenParams{
+		TokenHash: utils.HashToken(token),
+		Payload:   data,
+		ExpiresAt: payload.ExpiredAt,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (maker *OpaqueMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+	row, err := maker.store.GetOpaqueToken(context.Background(), utils.HashToken(token))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var payload Payload
+	err = json.Unmarshal(row.Payload, &payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	valid, err := payload.Valid(opts...)
+	if !valid {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// Revoke delete the handle, it stops verifying immediately
+func (maker *OpaqueMaker) Revoke(ctx context.Context, token string) error {
+	return maker.store.DeleteOpaqueToken(ctx, utils.HashToken(token))
+}
+
+func (maker *OpaqueMaker) DeleteExpired(ctx context.Context) error {
+	return maker.store.DeleteExpiredOpaqueTokens(ctx)
+}
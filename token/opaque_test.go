@@ -0,0 +1,99 @@
+package token
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/brkss/go-auth/utils"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// newOpaqueTestStore keeps created opaque tokens in a map so they can be looked up again
+func newOpaqueTestStore(t *testing.T) *mockdb.MockStore {
+	ctrl := gomock.NewController(t)
+	store := mockdb.NewMockStore(ctrl)
+
+	rows := make(map[string]db.OpaqueToken)
+	store.EXPECT().CreateOpaqueToken(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, arg db.CreateOpaqueTokenParams) error {
+			rows[arg.TokenHash] = db.OpaqueToken{TokenHash: arg.TokenHash, Payload: arg.Payload, ExpiresAt: arg.ExpiresAt}
+			return nil
+		})
+	store.EXPECT().GetOpaqueToken(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, tokenHash string) (db.OpaqueToken, error) {
+			row, ok := rows[tokenHash]
+			if !ok {
+				return db.OpaqueToken{}, sql.ErrNoRows
+			}
+			return row, nil
+		})
+	store.EXPECT().DeleteOpaqueToken(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(ctx context.Context, tokenHash string) error {
+			delete(rows, tokenHash)
+			return nil
+		})
+	return store
+}
+
+func TestOpaqueValidToken(t *testing.T) {
+
+	id := uuid.New().String()
+	maker, err := NewOpaqueMaker(newOpaqueTestStore(t))
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(id, time.Minute, WithScopes("admin:users:read"))
+	require.NoError(t, err)
+	require.NotEmpty(t, token)
+	// nothing readable in the handle
+	require.NotContains(t, token, id)
+
+	payload, err := maker.VerifyToken(token)
+	require.NoError(t, err)
+	require.Equal(t, id, payload.UserId)
+	require.Equal(t, []string{"admin:users:read"}, payload.Scopes)
+	require.WithinDuration(t, time.Now().Add(time.Minute), payload.ExpiredAt, time.Second)
+}
+
+func TestOpaqueExpiredToken(t *testing.T) {
+
+	maker, err := NewOpaqueMaker(newOpaqueTestStore(t))
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(uuid.New().String(), -time.Minute)
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestOpaqueUnknownToken(t *testing.T) {
+
+	maker, err := NewOpaqueMaker(newOpaqueTestStore(t))
+	require.NoError(t, err)
+
+	payload, err := maker.VerifyToken(utils.RandomString(43))
+	require.EqualError(t, err, ErrInvalidToken.Error())
+	require.Nil(t, payload)
+}
+
+func TestOpaqueRevoke(t *testing.T) {
+
+	maker, err := NewOpaqueMaker(newOpaqueTestStore(t))
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	err = maker.(*OpaqueMaker).Revoke(context.Background(), token)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
@@ -1,53 +1,216 @@
 package token
 
 import (
+	"encoding/json"
+	"expvar"
 	"fmt"
+	"strings"
 	"time"
 
+	pasetov4 "aidanwoods.dev/go-paseto"
 	"github.com/aead/chacha20poly1305"
 	"github.com/o1egl/paseto"
+	"golang.org/x/crypto/blake2b"
 )
 
+const (
+	headerV2Local = "v2.local."
+	headerV4Local = "v4.local."
+	// v4KeyContext separate the v4.local key from the v2.local one derived from the same material
+	v4KeyContext = "go-auth paseto v4.local key"
+)
+
+// legacy v2.local tokens still presented, v2 can be turned off once accepted stays flat
+var (
+	pasetoV2Accepted = expvar.NewInt("token_paseto_v2_accepted")
+	pasetoV2Rejected = expvar.NewInt("token_paseto_v2_rejected")
+)
+
+// PasetoMaker issue v4.local tokens, v2.local tokens issued before the migration
+// are still accepted until v2 is turned off or its grace period ends
 type PasetoMaker struct {
 	paseto 			*paseto.V2
-	symetricKey 	string
+	keyring 		*Keyring
+	v4Keys 			map[string]pasetov4.V4SymmetricKey
+	acceptV2 		bool
+	// zero v2Until means no deadline
+	v2Until 		time.Time
+}
+
+// keyFooter is left unencrypted in the token so VerifyToken knows which key to use
+type keyFooter struct {
+	KeyID string `json:"kid"`
+}
+
+type PasetoOption func(maker *PasetoMaker)
+
+// AcceptV2Until stop accepting v2.local tokens after deadline
+func AcceptV2Until(deadline time.Time) PasetoOption {
+	return func(maker *PasetoMaker) {
+		maker.v2Until = deadline
+	}
 }
 
-func NewPasetoMaker(symetricKey string) (Maker, error) {
-	if len(symetricKey) < chacha20poly1305.KeySize {
-		return nil, fmt.Errorf("Invalid symteric key size must be %d\n", chacha20poly1305.KeySize)
+// RejectV2 turn off v2.local verification once legacy tokens have drained
+func RejectV2() PasetoOption {
+	return func(maker *PasetoMaker) {
+		maker.acceptV2 = false
+	}
+}
+
+func NewPasetoMaker(symetricKey string, opts ...PasetoOption) (Maker, error) {
+	keyring, err := NewKeyring(Key{Material: []byte(symetricKey)})
+	if err != nil {
+		return nil, err
 	}
+	return NewPasetoKeyringMaker(keyring, opts...)
+}
+
+// NewPasetoKeyringMaker encrypt with the keyring active key and decrypt with the key named in the footer
+func NewPasetoKeyringMaker(keyring *Keyring, opts ...PasetoOption) (Maker, error) {
 	maker := &PasetoMaker{
 		paseto: paseto.NewV2(),
-		symetricKey: symetricKey,
+		keyring: keyring,
+		v4Keys: make(map[string]pasetov4.V4SymmetricKey),
+		acceptV2: true,
+	}
+	for _, key := range keyring.Keys() {
+		if len(key.Material) != chacha20poly1305.KeySize {
+			return nil, fmt.Errorf("Invalid symteric key size must be %d\n", chacha20poly1305.KeySize)
+		}
+		v4Key, err := deriveV4Key(key.Material)
+		if err != nil {
+			return nil, err
+		}
+		maker.v4Keys[key.ID] = v4Key
+	}
+	for _, opt := range opts {
+		opt(maker)
 	}
 	return maker, nil
 }
 
-func (paseto *PasetoMaker)CreateToken(userId string, duration time.Duration)(string, error){
+// deriveV4Key keeps a key from being used by both v2.local and v4.local, v2 tokens are still
+// decrypted with the raw material. Services decrypting v4.local tokens themselves need the same
+// key : the BLAKE2b-256 MAC of v4KeyContext keyed with the material
+func deriveV4Key(material []byte) (pasetov4.V4SymmetricKey, error) {
+	mac, err := blake2b.New256(material)
+	if err != nil {
+		return pasetov4.V4SymmetricKey{}, err
+	}
+	mac.Write([]byte(v4KeyContext))
+	return pasetov4.V4SymmetricKeyFromBytes(mac.Sum(nil))
+}
 
-	payload := NewPayload(userId, duration)
-	token, err := paseto.paseto.Encrypt([]byte(paseto.symetricKey), payload, nil) 
-	
+func (maker *PasetoMaker)CreateToken(userId string, duration time.Duration, opts ...PayloadOption)(string, error){
+
+	key, err := maker.keyring.ActiveKey(time.Now())
 	if err != nil {
 		return "", err
 	}
 
-	return token, nil
-}
+	var footer []byte
+	if key.ID != "" {
+		footer, err = json.Marshal(keyFooter{KeyID: key.ID})
+		if err != nil {
+			return "", err
+		}
+	}
 
-func (paseto *PasetoMaker)VerifyToken(token string)(*Payload, error){
+	payload, err := NewPayload(userId, duration, opts...)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
 
-	var payload Payload
-	err := paseto.paseto.Decrypt(token, []byte(paseto.symetricKey), &payload, nil)
+	token, err := pasetov4.NewTokenFromClaimsJSON(claims, footer)
+	if err != nil {
+		return "", err
+	}
 
+	return token.V4Encrypt(maker.v4Keys[key.ID], nil), nil
+}
+
+func (maker *PasetoMaker)VerifyToken(token string, opts ...VerifyOption)(*Payload, error){
+
+	var payload *Payload
+	var err error
+	switch {
+	case strings.HasPrefix(token, headerV4Local):
+		payload, err = maker.decryptV4(token)
+	case strings.HasPrefix(token, headerV2Local):
+		payload, err = maker.decryptV2(token)
+	default:
+		return nil, ErrInvalidToken
+	}
 	if err != nil {
-		return nil, ErrInvalidToken 
+		return nil, err
 	}
 
-	valid, err := payload.Valid()
+	valid, err := payload.Valid(opts...)
 	if !valid {
 		return nil, err 
 	} 
-	return &payload, nil 
+	if strings.HasPrefix(token, headerV2Local) {
+		pasetoV2Accepted.Add(1)
+	}
+	return payload, nil 
+}
+
+func (maker *PasetoMaker) decryptV4(token string) (*Payload, error) {
+	// expiration is checked by payload.Valid, our claims don't use the paseto names
+	parser := pasetov4.NewParserWithoutExpiryCheck()
+
+	var footer keyFooter
+	rawFooter, err := parser.UnsafeParseFooter(pasetov4.V4Local, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if len(rawFooter) > 0 && json.Unmarshal(rawFooter, &footer) != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if _, err := maker.keyring.Key(footer.KeyID, time.Now()); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	parsed, err := parser.ParseV4Local(maker.v4Keys[footer.KeyID], token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var payload Payload
+	err = json.Unmarshal(parsed.ClaimsJSON(), &payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	return &payload, nil
+}
+
+func (maker *PasetoMaker) decryptV2(token string) (*Payload, error) {
+	if !maker.acceptV2 || (!maker.v2Until.IsZero() && time.Now().After(maker.v2Until)) {
+		pasetoV2Rejected.Add(1)
+		return nil, ErrInvalidToken
+	}
+
+	var footer keyFooter
+	err := paseto.ParseFooter(token, &footer)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, err := maker.keyring.Key(footer.KeyID, time.Now())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var payload Payload
+	err = maker.paseto.Decrypt(token, key.Material, &payload, nil)
+	if err != nil {
+		return nil, ErrInvalidToken 
+	}
+	return &payload, nil
 }
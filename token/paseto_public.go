@@ -0,0 +1,170 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	pasetov4 "aidanwoods.dev/go-paseto"
+)
+
+var ErrVerifyOnly = errors.New("this token maker can only verify tokens")
+
+// PasetoPublicMaker sign tokens with an Ed25519 private key (v4.public),
+// anyone holding the public key can verify them without being able to mint new ones
+type PasetoPublicMaker struct {
+	// nil for verify only makers
+	keyring    *Keyring
+	publicKeys map[string]pasetov4.V4AsymmetricPublicKey
+}
+
+func NewPasetoPublicMaker(privateKey ed25519.PrivateKey) (Maker, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("Invalid private key size must be %d\n", ed25519.PrivateKeySize)
+	}
+	keyring, err := NewKeyring(Key{Material: privateKey.Seed()})
+	if err != nil {
+		return nil, err
+	}
+	return NewPasetoPublicKeyringMaker(keyring)
+}
+
+// NewPasetoPublicKeyringMaker expects keys material to be Ed25519 seeds
+func NewPasetoPublicKeyringMaker(keyring *Keyring) (Maker, error) {
+	maker := &PasetoPublicMaker{
+		keyring:    keyring,
+		publicKeys: make(map[string]pasetov4.V4AsymmetricPublicKey),
+	}
+	for _, key := range keyring.Keys() {
+		secretKey, err := v4SecretKey(key)
+		if err != nil {
+			return nil, err
+		}
+		maker.publicKeys[key.ID] = secretKey.Public()
+	}
+	return maker, nil
+}
+
+// NewPasetoPublicVerifier returns a maker for services that only need to verify tokens
+func NewPasetoPublicVerifier(publicKey ed25519.PublicKey) (Maker, error) {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Invalid public key size must be %d\n", ed25519.PublicKeySize)
+	}
+	key, err := pasetov4.NewV4AsymmetricPublicKeyFromBytes(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	maker := &PasetoPublicMaker{
+		publicKeys: map[string]pasetov4.V4AsymmetricPublicKey{"": key},
+	}
+	return maker, nil
+}
+
+func v4SecretKey(key Key) (pasetov4.V4AsymmetricSecretKey, error) {
+	if len(key.Material) != ed25519.SeedSize {
+		return pasetov4.V4AsymmetricSecretKey{}, fmt.Errorf("Invalid private key seed size must be %d\n", ed25519.SeedSize)
+	}
+	return pasetov4.NewV4AsymmetricSecretKeyFromBytes(ed25519.NewKeyFromSeed(key.Material))
+}
+
+func (maker *PasetoPublicMaker) CreateToken(userId string, duration time.Duration, opts ...PayloadOption) (string, error) {
+	if maker.keyring == nil {
+		return "", ErrVerifyOnly
+	}
+
+	key, err := maker.keyring.ActiveKey(time.Now())
+	if err != nil {
+		return "", err
+	}
+	secretKey, err := v4SecretKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	var footer []byte
+	if key.ID != "" {
+		footer, err = json.Marshal(keyFooter{KeyID: key.ID})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	payload, err := NewPayload(userId, duration, opts...)
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := pasetov4.NewTokenFromClaimsJSON(claims, footer)
+	if err != nil {
+		return "", err
+	}
+
+	return token.V4Sign(secretKey, nil), nil
+}
+
+func (maker *PasetoPublicMaker) PublicKeys(now time.Time) []PublicKey {
+	if maker.keyring == nil {
+		return []PublicKey{{Algorithm: PasetoV4Public, Key: ed25519.PublicKey(maker.publicKeys[""].ExportBytes())}}
+	}
+
+	var keys []PublicKey
+	for _, key := range maker.keyring.Unretired(now) {
+		keys = append(keys, PublicKey{
+			ID:        key.ID,
+			Algorithm: PasetoV4Public,
+			Key:       ed25519.PublicKey(maker.publicKeys[key.ID].ExportBytes()),
+		})
+	}
+	return keys
+}
+
+func (maker *PasetoPublicMaker) VerifyToken(token string, opts ...VerifyOption) (*Payload, error) {
+
+	// expiration is checked by payload.Valid, our claims don't use the paseto names
+	parser := pasetov4.NewParserWithoutExpiryCheck()
+
+	var footer keyFooter
+	rawFooter, err := parser.UnsafeParseFooter(pasetov4.V4Public, token)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if len(rawFooter) > 0 && json.Unmarshal(rawFooter, &footer) != nil {
+		return nil, ErrInvalidToken
+	}
+
+	keyId := footer.KeyID
+	if maker.keyring == nil {
+		// a verifier holds a single public key whatever id it was published under
+		keyId = ""
+	} else if _, err := maker.keyring.Key(keyId, time.Now()); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	publicKey, ok := maker.publicKeys[keyId]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	parsed, err := parser.ParseV4Public(publicKey, token, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var payload Payload
+	err = json.Unmarshal(parsed.ClaimsJSON(), &payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	valid, err := payload.Valid(opts...)
+	if !valid {
+		return nil, err
+	}
+	return &payload, nil
+}
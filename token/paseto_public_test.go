@@ -0,0 +1,105 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyPair(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return publicKey, privateKey
+}
+
+func TestPublicValidToken(t *testing.T) {
+
+	id := uuid.New().String()
+	publicKey, privateKey := newTestKeyPair(t)
+
+	maker, err := NewPasetoPublicMaker(privateKey)
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(id, time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, token, "v4.public.")
+
+	verifier, err := NewPasetoPublicVerifier(publicKey)
+	require.NoError(t, err)
+
+	for _, m := range []Maker{maker, verifier} {
+		payload, err := m.VerifyToken(token)
+		require.NoError(t, err)
+		require.NotEmpty(t, payload)
+
+		require.Equal(t, payload.UserId, id)
+		require.WithinDuration(t, payload.IssuedAt, time.Now(), time.Second)
+		require.WithinDuration(t, payload.ExpiredAt, time.Now().Add(time.Minute), time.Second)
+	}
+}
+
+func TestPublicExpiredToken(t *testing.T) {
+
+	_, privateKey := newTestKeyPair(t)
+
+	maker, err := NewPasetoPublicMaker(privateKey)
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(uuid.New().String(), -time.Minute)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token)
+	require.EqualError(t, err, ErrExpiredToken.Error())
+}
+
+func TestPublicInvalidToken(t *testing.T) {
+
+	_, privateKey1 := newTestKeyPair(t)
+	publicKey2, _ := newTestKeyPair(t)
+
+	maker, err := NewPasetoPublicMaker(privateKey1)
+	require.NoError(t, err)
+
+	verifier, err := NewPasetoPublicVerifier(publicKey2)
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	_, err = verifier.VerifyToken(token)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+
+	// a symmetric token must never be accepted by the public maker
+	localMaker, err := NewPasetoMaker(utils.RandomString(32))
+	require.NoError(t, err)
+	localToken, err := localMaker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(localToken)
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestPublicVerifierCannotCreate(t *testing.T) {
+
+	publicKey, _ := newTestKeyPair(t)
+
+	verifier, err := NewPasetoPublicVerifier(publicKey)
+	require.NoError(t, err)
+
+	_, err = verifier.CreateToken(uuid.New().String(), time.Minute)
+	require.EqualError(t, err, ErrVerifyOnly.Error())
+}
+
+func TestPublicInvalidKeySize(t *testing.T) {
+
+	_, err := NewPasetoPublicMaker(ed25519.PrivateKey("aaa"))
+	require.Error(t, err)
+
+	_, err = NewPasetoPublicVerifier(ed25519.PublicKey("aaa"))
+	require.Error(t, err)
+}
@@ -1,12 +1,16 @@
 package token
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	pasetov4 "aidanwoods.dev/go-paseto"
 	"github.com/brkss/go-auth/utils"
 	"github.com/google/uuid"
+	"github.com/o1egl/paseto"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
 )
 
 func TestValidToken(t *testing.T) {
@@ -68,3 +72,124 @@ func TestInvalidSymtrictKey(t *testing.T) {
 	_, err := NewPasetoMaker("aaa")
 	require.Error(t, err)
 }
+
+// newV2Token mint a v2.local token the way PasetoMaker did before v4.local
+func newV2Token(t *testing.T, key string, duration time.Duration) string {
+	payload := newTestPayload(t, uuid.New().String(), duration)
+	token, err := paseto.NewV2().Encrypt([]byte(key), payload, nil)
+	require.NoError(t, err)
+	return token
+}
+
+func TestIssueV4Local(t *testing.T) {
+
+	maker, err := NewPasetoMaker(utils.RandomString(32))
+	require.NoError(t, err)
+
+	token, err := maker.CreateToken(uuid.New().String(), time.Minute)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token, "v4.local."))
+}
+
+func TestLegacyV2Token(t *testing.T) {
+
+	key := utils.RandomString(32)
+	token := newV2Token(t, key, time.Minute)
+
+	testCases := []struct {
+		name  string
+		opts  []PasetoOption
+		valid bool
+	}{
+		{
+			name:  "Accepted",
+			valid: true,
+		},
+		{
+			name:  "WithinGracePeriod",
+			opts:  []PasetoOption{AcceptV2Until(time.Now().Add(time.Hour))},
+			valid: true,
+		},
+		{
+			name: "GracePeriodOver",
+			opts: []PasetoOption{AcceptV2Until(time.Now().Add(-time.Hour))},
+		},
+		{
+			name: "Rejected",
+			opts: []PasetoOption{RejectV2()},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			maker, err := NewPasetoMaker(key, tc.opts...)
+			require.NoError(t, err)
+
+			accepted, rejected := pasetoV2Accepted.Value(), pasetoV2Rejected.Value()
+
+			payload, err := maker.VerifyToken(token)
+			if tc.valid {
+				require.NoError(t, err)
+				require.NotEmpty(t, payload)
+				require.Equal(t, accepted+1, pasetoV2Accepted.Value())
+				return
+			}
+			require.EqualError(t, err, ErrInvalidToken.Error())
+			require.Equal(t, rejected+1, pasetoV2Rejected.Value())
+		})
+	}
+}
+
+func TestLegacyV2ExpiredToken(t *testing.T) {
+
+	key := utils.RandomString(32)
+	maker, err := NewPasetoMaker(key)
+	require.NoError(t, err)
+
+	accepted := pasetoV2Accepted.Value()
+	_, err = maker.VerifyToken(newV2Token(t, key, -time.Minute))
+	require.EqualError(t, err, ErrExpiredToken.Error())
+	require.Equal(t, accepted, pasetoV2Accepted.Value())
+}
+
+func TestV4KeySeparateFromV2(t *testing.T) {
+
+	key := utils.RandomString(32)
+	maker, err := NewPasetoMaker(key)
+	require.NoError(t, err)
+
+	// a v4.local token under the raw key is one the maker never issues
+	claims, err := newTestPayload(t, uuid.New().String(), time.Minute).MarshalJSON()
+	require.NoError(t, err)
+	token, err := pasetov4.NewTokenFromClaimsJSON(claims, nil)
+	require.NoError(t, err)
+	rawKey, err := pasetov4.V4SymmetricKeyFromBytes([]byte(key))
+	require.NoError(t, err)
+
+	_, err = maker.VerifyToken(token.V4Encrypt(rawKey, nil))
+	require.EqualError(t, err, ErrInvalidToken.Error())
+}
+
+func TestV4KeyDerivation(t *testing.T) {
+
+	key := utils.RandomString(32)
+	maker, err := NewPasetoMaker(key)
+	require.NoError(t, err)
+	userId := uuid.New().String()
+	token, err := maker.CreateToken(userId, time.Minute)
+	require.NoError(t, err)
+
+	// what a downstream service does with the documented derivation and a stock paseto library
+	mac, err := blake2b.New256([]byte(key))
+	require.NoError(t, err)
+	mac.Write([]byte("go-auth paseto v4.local key"))
+	v4Key, err := pasetov4.V4SymmetricKeyFromBytes(mac.Sum(nil))
+	require.NoError(t, err)
+
+	parser := pasetov4.NewParserWithoutExpiryCheck()
+	parsed, err := parser.ParseV4Local(v4Key, token, nil)
+	require.NoError(t, err)
+	subject, err := parsed.GetSubject()
+	require.NoError(t, err)
+	require.Equal(t, userId, subject)
+}
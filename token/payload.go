@@ -1,6 +1,7 @@
 package token
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -9,29 +10,268 @@ import (
 
 var ErrExpiredToken = errors.New("this token has been expired")
 var ErrInvalidToken = errors.New("invalid token !")
+var ErrTokenNotYetValid = errors.New("this token is not valid yet")
+var ErrInvalidIssuer = errors.New("this token was issued by an unexpected issuer")
+var ErrInvalidAudience = errors.New("this token was not issued for this audience")
 
 type Payload struct {
 	ID string 
 	UserId string
 	ExpiredAt time.Time
 	IssuedAt time.Time
+	Issuer string `json:"iss,omitempty"`
+	Audience []string `json:"aud,omitempty"`
+	Subject string `json:"sub,omitempty"`
+	NotBefore time.Time `json:"nbf"`
+	Roles []string `json:"roles,omitempty"`
+	Scopes []string `json:"scopes,omitempty"`
+	// ClientID is the OAuth client the token was issued to, empty for direct logins
+	ClientID string `json:"client_id,omitempty"`
+	// Confirmation binds the token to a key the client has to prove it holds
+	Confirmation *Confirmation `json:"cnf,omitempty"`
+	// Actor is who acts on behalf of the subject for exchanged tokens (RFC 8693)
+	Actor *Actor `json:"act,omitempty"`
+	// Fingerprint is the hash of the value of the fingerprint cookie the token must be sent with
+	Fingerprint string `json:"fgp,omitempty"`
+	// Caveats restrict the requests a macaroon is valid for, see CheckCaveats
+	Caveats []Caveat `json:"-"`
+	// Extra holds the custom claims set with Claim, it is flattened next to the other claims
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
-func NewPayload(userId string, duration time.Duration) (*Payload){
-	return &Payload{
+// Actor is one step of a delegation chain, the nested Actor acted before it
+type Actor struct {
+	Subject  string `json:"sub"`
+	ClientID string `json:"client_id,omitempty"`
+	Actor    *Actor `json:"act,omitempty"`
+}
+
+// Confirmation is the RFC 7800 cnf claim
+type Confirmation struct {
+	// JKT is the RFC 7638 thumbprint of the client DPoP key (RFC 9449)
+	JKT string `json:"jkt,omitempty"`
+	// X5tS256 is the thumbprint of the client TLS certificate (RFC 8705)
+	X5tS256 string `json:"x5t#S256,omitempty"`
+}
+
+// PayloadOption customise a payload before the token gets signed
+type PayloadOption func(payload *Payload) error
+
+func WithIssuer(issuer string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Issuer = issuer
+		return nil
+	}
+}
+
+func WithAudience(audience ...string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Audience = audience
+		return nil
+	}
+}
+
+// WithSubject override the subject which defaults to the user id
+func WithSubject(subject string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Subject = subject
+		return nil
+	}
+}
+
+func WithNotBefore(notBefore time.Time) PayloadOption {
+	return func(payload *Payload) error {
+		payload.NotBefore = notBefore
+		return nil
+	}
+}
+
+func WithRoles(roles ...string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Roles = roles
+		return nil
+	}
+}
+
+func WithScopes(scopes ...string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Scopes = scopes
+		return nil
+	}
+}
+
+func WithClientID(clientId string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.ClientID = clientId
+		return nil
+	}
+}
+
+// WithDPoPKey bind the token to the DPoP key with the given thumbprint
+func WithDPoPKey(jkt string) PayloadOption {
+	return func(payload *Payload) error {
+		if payload.Confirmation == nil {
+			payload.Confirmation = &Confirmation{}
+		}
+		payload.Confirmation.JKT = jkt
+		return nil
+	}
+}
+
+// DPoPKey returns the thumbprint of the key the token is bound to, empty for bearer tokens
+func (payload *Payload) DPoPKey() string {
+	if payload.Confirmation == nil {
+		return ""
+	}
+	return payload.Confirmation.JKT
+}
+
+// WithCertificate bind the token to the client certificate with the given thumbprint
+func WithCertificate(x5tS256 string) PayloadOption {
+	return func(payload *Payload) error {
+		if payload.Confirmation == nil {
+			payload.Confirmation = &Confirmation{}
+		}
+		payload.Confirmation.X5tS256 = x5tS256
+		return nil
+	}
+}
+
+// CertificateKey returns the thumbprint of the certificate the token is bound to
+func (payload *Payload) CertificateKey() string {
+	if payload.Confirmation == nil {
+		return ""
+	}
+	return payload.Confirmation.X5tS256
+}
+
+// IsClient reports whether the token was issued to a client acting for itself,
+// client_credentials tokens have the client as subject and no user
+func (payload *Payload) IsClient() bool {
+	return payload.UserId == "" && payload.ClientID != ""
+}
+
+func WithActor(actor *Actor) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Actor = actor
+		return nil
+	}
+}
+
+// WithFingerprint bind the token to a cookie, fingerprintHash is the hash of its value
+func WithFingerprint(fingerprintHash string) PayloadOption {
+	return func(payload *Payload) error {
+		payload.Fingerprint = fingerprintHash
+		return nil
+	}
+}
+
+func NewPayload(userId string, duration time.Duration, opts ...PayloadOption) (*Payload, error){
+	now := time.Now()
+	payload := &Payload{
 		ID: uuid.New().String(),
 		UserId: userId,
-		ExpiredAt: time.Now().Add(duration),
-		IssuedAt: time.Now(),
+		ExpiredAt: now.Add(duration),
+		IssuedAt: now,
+		Subject: userId,
+		NotBefore: now,
+	}
+	for _, opt := range opts {
+		err := opt(payload)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
+
+type verifyOptions struct {
+	issuer    string
+	audiences []string
+	leeway    time.Duration
+	ignoreExp bool
+}
+
+// VerifyOption add a check on top of the signature and expiration
+type VerifyOption func(options *verifyOptions)
+
+// ExpectIssuer reject tokens not issued by issuer
+func ExpectIssuer(issuer string) VerifyOption {
+	return func(options *verifyOptions) {
+		options.issuer = issuer
+	}
+}
+
+// ExpectAudience reject tokens whose audience doesn't contain one of audiences
+func ExpectAudience(audiences ...string) VerifyOption {
+	return func(options *verifyOptions) {
+		options.audiences = audiences
+	}
+}
+
+// AllowLeeway tolerate clock skew between servers when checking exp and nbf
+func AllowLeeway(leeway time.Duration) VerifyOption {
+	return func(options *verifyOptions) {
+		options.leeway = leeway
+	}
+}
+
+// IgnoreExpiration accept expired tokens, for tokens only used to tell who they were issued to
+// like OpenID Connect id token hints
+func IgnoreExpiration() VerifyOption {
+	return func(options *verifyOptions) {
+		options.ignoreExp = true
 	}
 }
 
-func (p *Payload)Valid()(bool, error){
+func (p *Payload)Valid(opts ...VerifyOption)(bool, error){
 
-	if time.Now().After(p.ExpiredAt){
+	var options verifyOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	now := time.Now()
+	if !options.ignoreExp && now.After(p.ExpiredAt.Add(options.leeway)){
 		return false, ErrExpiredToken
 	}
 
+	if now.Add(options.leeway).Before(p.NotBefore) {
+		return false, ErrTokenNotYetValid
+	}
+
+	if options.issuer != "" && p.Issuer != options.issuer {
+		return false, ErrInvalidIssuer
+	}
+
+	if len(options.audiences) > 0 && !p.hasAudience(options.audiences) {
+		return false, ErrInvalidAudience
+	}
+
 	return true, nil
 }
 
+func (p *Payload) hasAudience(audiences []string) bool {
+	for _, expected := range audiences {
+		for _, audience := range p.Audience {
+			if audience == expected {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScopes report whether the token was granted every one of scopes
+func (p *Payload) HasScopes(scopes ...string) bool {
+	granted := make(map[string]bool, len(p.Scopes))
+	for _, scope := range p.Scopes {
+		granted[scope] = true
+	}
+	for _, scope := range scopes {
+		if !granted[scope] {
+			return false
+		}
+	}
+	return true
+}
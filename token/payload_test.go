@@ -0,0 +1,249 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"testing"
+	"time"
+
+	"github.com/brkss/go-auth/utils"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPayload(t *testing.T, userId string, duration time.Duration, opts ...PayloadOption) *Payload {
+	payload, err := NewPayload(userId, duration, opts...)
+	require.NoError(t, err)
+	return payload
+}
+
+// newTestMakers returns one maker of each implementation
+func newTestMakers(t *testing.T) map[string]Maker {
+	_, privateKey := newTestKeyPair(t)
+
+	local, err := NewPasetoMaker(utils.RandomString(32))
+	require.NoError(t, err)
+	public, err := NewPasetoPublicMaker(privateKey)
+	require.NoError(t, err)
+	jwtMaker, err := NewJWTMaker("EdDSA", ed25519.PrivateKey(privateKey))
+	require.NoError(t, err)
+	macaroon, err := NewMacaroonMaker(utils.RandomString(32))
+	require.NoError(t, err)
+
+	return map[string]Maker{"paseto": local, "paseto-public": public, "jwt": jwtMaker, "macaroon": macaroon}
+}
+
+func TestPayloadDefaults(t *testing.T) {
+
+	userId := uuid.New().String()
+	payload := newTestPayload(t, userId, time.Minute)
+
+	require.Equal(t, userId, payload.Subject)
+	require.Equal(t, payload.IssuedAt, payload.NotBefore)
+	require.Empty(t, payload.Issuer)
+	require.Empty(t, payload.Audience)
+}
+
+func TestPayloadValid(t *testing.T) {
+
+	testCases := []struct {
+		name     string
+		opts     []PayloadOption
+		verify   []VerifyOption
+		checkErr func(err error)
+	}{
+		{
+			name:   "OK",
+			opts:   []PayloadOption{WithIssuer("auth"), WithAudience("billing", "orders")},
+			verify: []VerifyOption{ExpectIssuer("auth"), ExpectAudience("orders")},
+			checkErr: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name:   "InvalidIssuer",
+			opts:   []PayloadOption{WithIssuer("someone-else")},
+			verify: []VerifyOption{ExpectIssuer("auth")},
+			checkErr: func(err error) {
+				require.EqualError(t, err, ErrInvalidIssuer.Error())
+			},
+		},
+		{
+			name:   "InvalidAudience",
+			opts:   []PayloadOption{WithAudience("billing")},
+			verify: []VerifyOption{ExpectAudience("orders", "shipping")},
+			checkErr: func(err error) {
+				require.EqualError(t, err, ErrInvalidAudience.Error())
+			},
+		},
+		{
+			name:   "MissingAudience",
+			verify: []VerifyOption{ExpectAudience("orders")},
+			checkErr: func(err error) {
+				require.EqualError(t, err, ErrInvalidAudience.Error())
+			},
+		},
+		{
+			name: "NotYetValid",
+			opts: []PayloadOption{WithNotBefore(time.Now().Add(10 * time.Second))},
+			checkErr: func(err error) {
+				require.EqualError(t, err, ErrTokenNotYetValid.Error())
+			},
+		},
+		{
+			name:   "NotYetValidWithinLeeway",
+			opts:   []PayloadOption{WithNotBefore(time.Now().Add(10 * time.Second))},
+			verify: []VerifyOption{AllowLeeway(time.Minute)},
+			checkErr: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+		{
+			name: "CustomSubject",
+			opts: []PayloadOption{WithSubject("service-a")},
+			checkErr: func(err error) {
+				require.NoError(t, err)
+			},
+		},
+	}
+
+	for name, maker := range newTestMakers(t) {
+		for _, tc := range testCases {
+			t.Run(name+"/"+tc.name, func(t *testing.T) {
+				token, err := maker.CreateToken(uuid.New().String(), time.Minute, tc.opts...)
+				require.NoError(t, err)
+
+				payload, err := maker.VerifyToken(token, tc.verify...)
+				tc.checkErr(err)
+				if err != nil {
+					return
+				}
+
+				expected := newTestPayload(t, payload.UserId, time.Minute, tc.opts...)
+				require.Equal(t, expected.Issuer, payload.Issuer)
+				require.Equal(t, expected.Audience, payload.Audience)
+				if tc.name == "CustomSubject" {
+					require.Equal(t, "service-a", payload.Subject)
+				} else {
+					require.Equal(t, payload.UserId, payload.Subject)
+				}
+			})
+		}
+	}
+}
+
+func TestPayloadExpiredWithinLeeway(t *testing.T) {
+
+	payload := newTestPayload(t, uuid.New().String(), -10*time.Second)
+
+	_, err := payload.Valid()
+	require.EqualError(t, err, ErrExpiredToken.Error())
+
+	valid, err := payload.Valid(AllowLeeway(time.Minute))
+	require.NoError(t, err)
+	require.True(t, valid)
+}
+
+func TestPayloadIgnoreExpiration(t *testing.T) {
+
+	payload := newTestPayload(t, uuid.New().String(), -24*time.Hour, WithIssuer("https://auth.example.com"))
+
+	valid, err := payload.Valid(IgnoreExpiration())
+	require.NoError(t, err)
+	require.True(t, valid)
+
+	// the other checks still apply
+	_, err = payload.Valid(IgnoreExpiration(), ExpectIssuer("https://other.example.com"))
+	require.EqualError(t, err, ErrInvalidIssuer.Error())
+}
+
+func TestPayloadScopes(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute,
+				WithRoles("admin"),
+				WithScopes("admin:users:read", "admin:users:write"),
+			)
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, []string{"admin"}, payload.Roles)
+			require.Equal(t, []string{"admin:users:read", "admin:users:write"}, payload.Scopes)
+
+			require.True(t, payload.HasScopes())
+			require.True(t, payload.HasScopes("admin:users:read"))
+			require.True(t, payload.HasScopes("admin:users:write", "admin:users:read"))
+			require.False(t, payload.HasScopes("admin:users:read", "admin:users:delete"))
+		})
+	}
+}
+
+func TestPayloadClientID(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute, WithClientID("billing-service"))
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "billing-service", payload.ClientID)
+		})
+	}
+}
+
+func TestPayloadActor(t *testing.T) {
+
+	actor := &Actor{Subject: "orders-service", ClientID: "orders", Actor: &Actor{Subject: "gateway"}}
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute, WithActor(actor))
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, actor, payload.Actor)
+		})
+	}
+}
+
+func TestPayloadCertificateKey(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute, WithCertificate("x5t"), WithDPoPKey("jkt"))
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "x5t", payload.CertificateKey())
+			require.Equal(t, "jkt", payload.DPoPKey())
+		})
+	}
+}
+
+func TestPayloadFingerprint(t *testing.T) {
+
+	for name, maker := range newTestMakers(t) {
+		t.Run(name, func(t *testing.T) {
+			token, err := maker.CreateToken(uuid.New().String(), time.Minute, WithFingerprint("hash"))
+			require.NoError(t, err)
+
+			payload, err := maker.VerifyToken(token)
+			require.NoError(t, err)
+			require.Equal(t, "hash", payload.Fingerprint)
+		})
+	}
+}
+
+func TestPayloadIsClient(t *testing.T) {
+	payload := newTestPayload(t, uuid.New().String(), time.Minute, WithClientID("client"))
+	require.False(t, payload.IsClient())
+
+	payload = newTestPayload(t, "", time.Minute, WithSubject("client"), WithClientID("client"))
+	require.True(t, payload.IsClient())
+
+	payload = newTestPayload(t, "", time.Minute)
+	require.False(t, payload.IsClient())
+}
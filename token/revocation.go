@@ -0,0 +1,155 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	db "github.com/brkss/go-auth/db/sqlc"
+)
+
+var ErrRevokedToken = errors.New("this token has been revoked")
+
+// RevocationStore remember revoked tokens until they would have expired anyway
+type RevocationStore interface {
+	// Revoke invalidate a single token by its payload id
+	Revoke(ctx context.Context, payload *Payload) error
+	// RevokeUser invalidate every token of a user issued up to before,
+	// the entry is kept until expiresAt when those tokens are all expired
+	RevokeUser(ctx context.Context, userId string, before time.Time, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, payload *Payload) (bool, error)
+	// DeleteExpired drop the entries nobody needs anymore
+	DeleteExpired(ctx context.Context) error
+}
+
+// ExpiredDeleter is a store whose entries become useless once tokens expire
+type ExpiredDeleter interface {
+	DeleteExpired(ctx context.Context) error
+}
+
+// RunRevocationCleanup call DeleteExpired every interval until ctx is done
+func RunRevocationCleanup(ctx context.Context, store ExpiredDeleter, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := store.DeleteExpired(ctx)
+			if err != nil {
+				log.Println("cannot delete expired entries :", err)
+			}
+		}
+	}
+}
+
+type userRevocation struct {
+	before    time.Time
+	expiresAt time.Time
+}
+
+// MemoryRevocationStore keeps revocations in process, meant for tests and single instance setups
+type MemoryRevocationStore struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]userRevocation
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{
+		tokens: make(map[string]time.Time),
+		users:  make(map[string]userRevocation),
+	}
+}
+
+func (store *MemoryRevocationStore) Revoke(ctx context.Context, payload *Payload) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	store.tokens[payload.ID] = payload.ExpiredAt
+	return nil
+}
+
+func (store *MemoryRevocationStore) RevokeUser(ctx context.Context, userId string, before time.Time, expiresAt time.Time) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	if current, ok := store.users[userId]; ok && current.expiresAt.After(expiresAt) {
+		expiresAt = current.expiresAt
+	}
+	store.users[userId] = userRevocation{before: before, expiresAt: expiresAt}
+	return nil
+}
+
+func (store *MemoryRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	if _, ok := store.tokens[payload.ID]; ok {
+		return true, nil
+	}
+	revocation, ok := store.users[payload.UserId]
+	return ok && !payload.IssuedAt.After(revocation.before), nil
+}
+
+func (store *MemoryRevocationStore) DeleteExpired(ctx context.Context) error {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	now := time.Now()
+	for id, expiresAt := range store.tokens {
+		if expiresAt.Before(now) {
+			delete(store.tokens, id)
+		}
+	}
+	for userId, revocation := range store.users {
+		if revocation.expiresAt.Before(now) {
+			delete(store.users, userId)
+		}
+	}
+	return nil
+}
+
+// PostgresRevocationStore share revocations between every instance through db.Store
+type PostgresRevocationStore struct {
+	store db.Store
+}
+
+func NewPostgresRevocationStore(store db.Store) *PostgresRevocationStore {
+	return &PostgresRevocationStore{store: store}
+}
+
+func (revocations *PostgresRevocationStore) Revoke(ctx context.Context, payload *Payload) error {
+	return revocations.store.RevokeToken(ctx, db.RevokeTokenParams{
+		ID:        payload.ID,
+		ExpiresAt: payload.ExpiredAt,
+	})
+}
+
+func (revocations *PostgresRevocationStore) RevokeUser(ctx context.Context, userId string, before time.Time, expiresAt time.Time) error {
+	return revocations.store.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		UserID:        userId,
+		RevokedBefore: before,
+		ExpiresAt:     expiresAt,
+	})
+}
+
+func (revocations *PostgresRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	return revocations.store.IsTokenRevoked(ctx, db.IsTokenRevokedParams{
+		ID:       payload.ID,
+		UserID:   payload.UserId,
+		IssuedAt: payload.IssuedAt,
+	})
+}
+
+func (revocations *PostgresRevocationStore) DeleteExpired(ctx context.Context) error {
+	err := revocations.store.DeleteExpiredRevokedTokens(ctx)
+	if err != nil {
+		return err
+	}
+	return revocations.store.DeleteExpiredRevokedUsers(ctx)
+}
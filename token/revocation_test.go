@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mockdb "github.com/brkss/go-auth/db/mock"
+	db "github.com/brkss/go-auth/db/sqlc"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRevokeToken(t *testing.T) {
+
+	store := NewMemoryRevocationStore()
+	payload1 := newTestPayload(t, uuid.New().String(), time.Minute)
+	payload2 := newTestPayload(t, payload1.UserId, time.Minute)
+
+	require.NoError(t, store.Revoke(context.Background(), payload1))
+
+	revoked, err := store.IsRevoked(context.Background(), payload1)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	revoked, err = store.IsRevoked(context.Background(), payload2)
+	require.NoError(t, err)
+	require.False(t, revoked)
+}
+
+func TestMemoryRevokeUser(t *testing.T) {
+
+	store := NewMemoryRevocationStore()
+	before := newTestPayload(t, uuid.New().String(), time.Minute)
+	other := newTestPayload(t, uuid.New().String(), time.Minute)
+
+	now := time.Now()
+	require.NoError(t, store.RevokeUser(context.Background(), before.UserId, now, now.Add(time.Minute)))
+
+	after := newTestPayload(t, before.UserId, time.Minute)
+	after.IssuedAt = now.Add(time.Second)
+
+	for payload, expected := range map[*Payload]bool{before: true, other: false, after: false} {
+		revoked, err := store.IsRevoked(context.Background(), payload)
+		require.NoError(t, err)
+		require.Equal(t, expected, revoked)
+	}
+}
+
+func TestMemoryDeleteExpired(t *testing.T) {
+
+	store := NewMemoryRevocationStore()
+	expired := newTestPayload(t, uuid.New().String(), -time.Minute)
+	valid := newTestPayload(t, uuid.New().String(), time.Minute)
+
+	require.NoError(t, store.Revoke(context.Background(), expired))
+	require.NoError(t, store.Revoke(context.Background(), valid))
+	require.NoError(t, store.RevokeUser(context.Background(), expired.UserId, time.Now(), time.Now().Add(-time.Second)))
+	require.NoError(t, store.RevokeUser(context.Background(), valid.UserId, time.Now(), time.Now().Add(time.Minute)))
+
+	require.NoError(t, store.DeleteExpired(context.Background()))
+
+	require.Len(t, store.tokens, 1)
+	require.Contains(t, store.tokens, valid.ID)
+	require.Len(t, store.users, 1)
+	require.Contains(t, store.users, valid.UserId)
+}
+
+func TestRunRevocationCleanup(t *testing.T) {
+
+	store := NewMemoryRevocationStore()
+	require.NoError(t, store.Revoke(context.Background(), newTestPayload(t, uuid.New().String(), -time.Minute)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		RunRevocationCleanup(ctx, store, time.Millisecond)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		store.mu.RLock()
+		defer store.mu.RUnlock()
+		return len(store.tokens) == 0
+	}, time.Second, time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestPostgresRevocationStore(t *testing.T) {
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	store := mockdb.NewMockStore(ctrl)
+	revocations := NewPostgresRevocationStore(store)
+	payload := newTestPayload(t, uuid.New().String(), time.Minute)
+	now := time.Now()
+
+	store.EXPECT().RevokeToken(gomock.Any(), gomock.Eq(db.RevokeTokenParams{ID: payload.ID, ExpiresAt: payload.ExpiredAt})).
+		Times(1).
+		Return(nil)
+	store.EXPECT().RevokeUserTokens(gomock.Any(), gomock.Eq(db.RevokeUserTokensParams{UserID: payload.UserId, RevokedBefore: now, ExpiresAt: now.Add(time.Minute)})).
+		Times(1).
+		Return(nil)
+	store.EXPECT().IsTokenRevoked(gomock.Any(), gomock.Eq(db.IsTokenRevokedParams{ID: payload.ID, UserID: payload.UserId, IssuedAt: payload.IssuedAt})).
+		Times(1).
+		Return(true, nil)
+	store.EXPECT().DeleteExpiredRevokedTokens(gomock.Any()).
+		Times(1).
+		Return(nil)
+	store.EXPECT().DeleteExpiredRevokedUsers(gomock.Any()).
+		Times(1).
+		Return(nil)
+
+	require.NoError(t, revocations.Revoke(context.Background(), payload))
+	require.NoError(t, revocations.RevokeUser(context.Background(), payload.UserId, now, now.Add(time.Minute)))
+
+	revoked, err := revocations.IsRevoked(context.Background(), payload)
+	require.NoError(t, err)
+	require.True(t, revoked)
+
+	require.NoError(t, revocations.DeleteExpired(context.Background()))
+}
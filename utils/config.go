@@ -1,7 +1,13 @@
 package utils
 
 import (
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -11,10 +17,180 @@ import (
 type Config struct {
 	DBSource 			string
 	DBDriver 			string
+	// TokenSymtricKey is the 32 bytes paseto key, v4.local tokens are encrypted with
+	// BLAKE2b-256 keyed with it over "go-auth paseto v4.local key" and not the key itself
 	TokenSymtricKey 	string 
 	TokenDuration  		time.Duration	
+	RefreshTokenDuration time.Duration
+	// TokenMaker select the token implementation: "paseto" (v4.local, default), "paseto-public" (v4.public), "jwt"
+	// "opaque" (random handles looked up in the database) or "macaroon" (attenuable with caveats)
+	TokenMaker 			string
+	// TokenPrivateKey hex encoded Ed25519 seed used by the public token maker
+	TokenPrivateKey 	string
+	// TokenAlgorithm is the jwt signing algorithm: HS256, RS256, ES256 or EdDSA
+	TokenAlgorithm 		string
+	// TokenPrivateKeyFile PKCS#8 PEM file holding the jwt asymmetric signing key
+	TokenPrivateKeyFile string
+	// TokenKeyringFile json keyring used by the paseto makers instead of a single key, see token.LoadKeyring
+	TokenKeyringFile 	string
+	// RevocationBackend is where revoked tokens are kept: "postgres" (default) or "memory"
+	RevocationBackend 	string
+	RevocationCleanupInterval time.Duration
+	// TokenIssuer is set as iss of issued tokens and required on verification when not empty
+	TokenIssuer 		string
+	// TokenAudience is the aud of issued tokens, protected routes require one of them
+	TokenAudience 		[]string
+	// TokenLeeway tolerated clock skew when checking exp and nbf
+	TokenLeeway 		time.Duration
+	// TokenAcceptV2 keep accepting v2.local tokens issued before the move to v4.local,
+	// turn it off once the token_paseto_v2_accepted metric stops growing
+	TokenAcceptV2 		bool
+	// TokenV2GraceUntil stops v2.local acceptance at that time when it's set
+	TokenV2GraceUntil 	time.Time
+	// TLSCertFile and TLSKeyFile turn on https
+	TLSCertFile 		string
+	TLSKeyFile 			string
+	// TLSClientCAFile signs the client certificates tokens can be bound to (RFC 8705)
+	TLSClientCAFile 	string
+	// TokenCacheSize is how many verified tokens authMiddleware remembers, 0 disables the cache
+	TokenCacheSize 		int
+	// TokenCacheTTL bounds how long a cached token is trusted without verifying it again
+	TokenCacheTTL 		time.Duration
+	// OIDCIssuer is the https url of the OpenID provider, iss of id tokens and base of the discovery endpoints
+	OIDCIssuer 			string
+	// OIDCAlgorithm and OIDCPrivateKeyFile sign id tokens (RS256, ES256 or EdDSA), OpenID Connect is off without a key
+	OIDCAlgorithm 		string
+	OIDCPrivateKeyFile 	string
+	// ClientRegistrationToken is the initial access token letting anyone holding it register clients,
+	// without it only admins can
+	ClientRegistrationToken string
+	// UpstreamProviders are the external OpenID providers users can log in with
+	UpstreamProviders 	[]UpstreamProvider
+	// TrustedProxies are the addresses or CIDRs of the reverse proxies allowed to set X-Forwarded-For,
+	// the client ip is the peer address when it's empty
+	TrustedProxies 		[]string
+	// PublicURL is the origin clients reach the server at, like https://auth.example.com, behind a TLS
+	// terminating proxy it replaces the request scheme and host in DPoP htu and the urls sent to clients
+	PublicURL 			string
 }
 
+// UpstreamProvider is an external OpenID provider users log in with at /login/{Name}
+type UpstreamProvider struct {
+	Name 			string
+	// Issuer is the https url the discovery document is fetched from
+	Issuer 			string
+	ClientID 		string
+	// ClientSecret is empty for providers registering this server as a public client
+	ClientSecret 	string
+	// RedirectURL is the /login/{Name}/callback url registered at the provider
+	RedirectURL 	string
+	// Scopes are requested next to openid, users can't be created without email
+	Scopes 			[]string
+}
+
+// upstreamNamePattern keep provider names usable in urls and usernames
+var upstreamNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// getUpstreamProviders read the UPSTREAM_<NAME>_* variables of every provider listed in UPSTREAM_PROVIDERS
+func getUpstreamProviders() ([]UpstreamProvider, error) {
+	var providers []UpstreamProvider
+	for _, name := range getList("UPSTREAM_PROVIDERS") {
+		if !upstreamNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid upstream provider name %q", name)
+		}
+		prefix := "UPSTREAM_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		provider := UpstreamProvider{
+			Name: name,
+			Issuer: os.Getenv(prefix + "ISSUER"),
+			ClientID: os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL: os.Getenv(prefix + "REDIRECT_URL"),
+			Scopes: getList(prefix + "SCOPES"),
+		}
+		if !strings.HasPrefix(provider.Issuer, "https://") || provider.ClientID == "" || provider.RedirectURL == "" {
+			return nil, fmt.Errorf("upstream provider %s needs an https issuer, a client id and a redirect url", name)
+		}
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = []string{"email", "profile"}
+		}
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// getList split an optional comma separated variable
+func getList(key string) []string {
+	var list []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value != "" {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
+// getTrustedProxies read TRUSTED_PROXIES, a comma separated list of ips and CIDRs
+func getTrustedProxies() ([]string, error) {
+	proxies := getList("TRUSTED_PROXIES")
+	for _, proxy := range proxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", proxy)
+		}
+	}
+	return proxies, nil
+}
+
+// getPublicURL read PUBLIC_URL, an http(s) origin without path, the trailing slash is dropped
+func getPublicURL() (string, error) {
+	value := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if value == "" {
+		return "", nil
+	}
+	uri, err := url.Parse(value)
+	if err != nil || (uri.Scheme != "https" && uri.Scheme != "http") || uri.Host == "" ||
+		uri.Path != "" || uri.RawQuery != "" || uri.Fragment != "" || uri.User != nil {
+		return "", fmt.Errorf("PUBLIC_URL must be an http(s) origin like https://auth.example.com")
+	}
+	return value, nil
+}
+
+// getDuration parse an optional duration variable, returning fallback when it's not set
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+
+// getBool parse an optional boolean variable, returning fallback when it's not set
+func getBool(key string, fallback bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// getInt parse an optional integer variable, returning fallback when it's not set
+func getInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// getTime parse an optional RFC 3339 time variable
+func getTime(key string) (time.Time, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
 
 func LoadConfig()(*Config, error){
 
@@ -26,12 +202,77 @@ func LoadConfig()(*Config, error){
 	if err != nil {
 		return nil, err
 	}
+	refreshDuration, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_DURATION"))
+	if err != nil {
+		return nil, err
+	}
+	cleanupInterval, err := getDuration("REVOCATION_CLEANUP_INTERVAL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+	leeway, err := getDuration("TOKEN_LEEWAY", 0)
+	if err != nil {
+		return nil, err
+	}
+	acceptV2, err := getBool("TOKEN_ACCEPT_V2", true)
+	if err != nil {
+		return nil, err
+	}
+	v2GraceUntil, err := getTime("TOKEN_V2_GRACE_UNTIL")
+	if err != nil {
+		return nil, err
+	}
+	cacheSize, err := getInt("TOKEN_CACHE_SIZE", 0)
+	if err != nil {
+		return nil, err
+	}
+	cacheTTL, err := getDuration("TOKEN_CACHE_TTL", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	upstreamProviders, err := getUpstreamProviders()
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := getTrustedProxies()
+	if err != nil {
+		return nil, err
+	}
+	publicURL, err := getPublicURL()
+	if err != nil {
+		return nil, err
+	}
 	
 	config := &Config{
 		DBSource: os.Getenv("DB_SOURCE"),
 		DBDriver: os.Getenv("DB_DRIVER"),
 		TokenSymtricKey: os.Getenv("TOKEN_SYMETRIC_KEY"),
 		TokenDuration: duration,
+		RefreshTokenDuration: refreshDuration,
+		TokenMaker: os.Getenv("TOKEN_MAKER"),
+		TokenPrivateKey: os.Getenv("TOKEN_PRIVATE_KEY"),
+		TokenAlgorithm: os.Getenv("TOKEN_ALGORITHM"),
+		TokenPrivateKeyFile: os.Getenv("TOKEN_PRIVATE_KEY_FILE"),
+		TokenKeyringFile: os.Getenv("TOKEN_KEYRING_FILE"),
+		RevocationBackend: os.Getenv("REVOCATION_BACKEND"),
+		RevocationCleanupInterval: cleanupInterval,
+		TokenIssuer: os.Getenv("TOKEN_ISSUER"),
+		TokenAudience: getList("TOKEN_AUDIENCE"),
+		TokenLeeway: leeway,
+		TokenAcceptV2: acceptV2,
+		TokenV2GraceUntil: v2GraceUntil,
+		TLSCertFile: os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile: os.Getenv("TLS_KEY_FILE"),
+		TLSClientCAFile: os.Getenv("TLS_CLIENT_CA_FILE"),
+		TokenCacheSize: cacheSize,
+		TokenCacheTTL: cacheTTL,
+		OIDCIssuer: os.Getenv("OIDC_ISSUER"),
+		OIDCAlgorithm: os.Getenv("OIDC_ALGORITHM"),
+		OIDCPrivateKeyFile: os.Getenv("OIDC_PRIVATE_KEY_FILE"),
+		ClientRegistrationToken: os.Getenv("CLIENT_REGISTRATION_TOKEN"),
+		UpstreamProviders: upstreamProviders,
+		TrustedProxies: trustedProxies,
+		PublicURL: publicURL,
 	}
 	return config, nil
 
@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUpstreamProviders(t *testing.T) {
+
+	t.Setenv("UPSTREAM_PROVIDERS", "google, corp-sso")
+	t.Setenv("UPSTREAM_GOOGLE_ISSUER", "https://accounts.google.com")
+	t.Setenv("UPSTREAM_GOOGLE_CLIENT_ID", "google-client")
+	t.Setenv("UPSTREAM_GOOGLE_CLIENT_SECRET", "google-secret")
+	t.Setenv("UPSTREAM_GOOGLE_REDIRECT_URL", "https://auth.example.com/login/google/callback")
+	t.Setenv("UPSTREAM_CORP_SSO_ISSUER", "https://sso.example.com/realms/corp")
+	t.Setenv("UPSTREAM_CORP_SSO_CLIENT_ID", "corp-client")
+	t.Setenv("UPSTREAM_CORP_SSO_REDIRECT_URL", "https://auth.example.com/login/corp-sso/callback")
+	t.Setenv("UPSTREAM_CORP_SSO_SCOPES", "email,groups")
+
+	providers, err := getUpstreamProviders()
+	require.NoError(t, err)
+	require.Equal(t, []UpstreamProvider{
+		{
+			Name:         "google",
+			Issuer:       "https://accounts.google.com",
+			ClientID:     "google-client",
+			ClientSecret: "google-secret",
+			RedirectURL:  "https://auth.example.com/login/google/callback",
+			Scopes:       []string{"email", "profile"},
+		},
+		{
+			Name:        "corp-sso",
+			Issuer:      "https://sso.example.com/realms/corp",
+			ClientID:    "corp-client",
+			RedirectURL: "https://auth.example.com/login/corp-sso/callback",
+			Scopes:      []string{"email", "groups"},
+		},
+	}, providers)
+
+	t.Run("HttpIssuer", func(t *testing.T) {
+		t.Setenv("UPSTREAM_GOOGLE_ISSUER", "http://accounts.google.com")
+		_, err := getUpstreamProviders()
+		require.Error(t, err)
+	})
+
+	t.Run("MissingClientID", func(t *testing.T) {
+		t.Setenv("UPSTREAM_CORP_SSO_CLIENT_ID", "")
+		_, err := getUpstreamProviders()
+		require.Error(t, err)
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		t.Setenv("UPSTREAM_PROVIDERS", "Corp.SSO")
+		_, err := getUpstreamProviders()
+		require.Error(t, err)
+	})
+
+	t.Run("None", func(t *testing.T) {
+		t.Setenv("UPSTREAM_PROVIDERS", "")
+		providers, err := getUpstreamProviders()
+		require.NoError(t, err)
+		require.Empty(t, providers)
+	})
+}
+
+func TestGetTrustedProxies(t *testing.T) {
+
+	t.Setenv("TRUSTED_PROXIES", "10.0.0.1, 172.16.0.0/12,::1")
+	proxies, err := getTrustedProxies()
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.1", "172.16.0.0/12", "::1"}, proxies)
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "10.0.0.1,proxy.internal")
+		_, err := getTrustedProxies()
+		require.Error(t, err)
+	})
+
+	t.Run("None", func(t *testing.T) {
+		t.Setenv("TRUSTED_PROXIES", "")
+		proxies, err := getTrustedProxies()
+		require.NoError(t, err)
+		require.Empty(t, proxies)
+	})
+}
+
+func TestGetPublicURL(t *testing.T) {
+
+	t.Setenv("PUBLIC_URL", "https://auth.example.com/")
+	publicURL, err := getPublicURL()
+	require.NoError(t, err)
+	require.Equal(t, "https://auth.example.com", publicURL)
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, value := range []string{"auth.example.com", "ftp://auth.example.com", "https://auth.example.com/auth", "https://auth.example.com?a=b"} {
+			t.Setenv("PUBLIC_URL", value)
+			_, err := getPublicURL()
+			require.Error(t, err, value)
+		}
+	})
+
+	t.Run("None", func(t *testing.T) {
+		t.Setenv("PUBLIC_URL", "")
+		publicURL, err := getPublicURL()
+		require.NoError(t, err)
+		require.Empty(t, publicURL)
+	})
+}
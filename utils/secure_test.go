@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandomSecureToken(t *testing.T) {
+
+	token1, err := RandomSecureToken(32)
+	require.NoError(t, err)
+	require.Len(t, token1, 43)
+
+	token2, err := RandomSecureToken(32)
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+}
+
+func TestHashToken(t *testing.T) {
+
+	token, err := RandomSecureToken(32)
+	require.NoError(t, err)
+
+	hash := HashToken(token)
+	require.Len(t, hash, 64)
+	require.Equal(t, hash, HashToken(token))
+	require.NotEqual(t, hash, HashToken(token+"a"))
+}